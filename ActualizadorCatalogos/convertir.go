@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"../XMLStructures/Catalogos"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Conversión de hojas del catCFDI a catálogos
+*
+*
+****************************************************************************************************************************************/
+
+// Hoja Hoja de un libro, como filas de celdas de texto.
+type Hoja struct {
+	Nombre string
+	Filas  [][]string
+}
+
+// parteHoja Sufijo de las hojas en que el SAT divide los catálogos grandes, p.ej. c_CodigoPostal_Parte_1.
+var parteHoja = regexp.MustCompile(`_Parte_\d+$`)
+
+// anchoClave Número de dígitos de las claves numéricas que Excel puede guardar como número, perdiendo los ceros a la izquierda.
+var anchoClave = map[string]int{
+	catalogos.FormaPago:     2,
+	catalogos.TipoRelacion:  2,
+	catalogos.RegimenFiscal: 3,
+	catalogos.Impuesto:      3,
+	catalogos.CodigoPostal:  5,
+	catalogos.ClaveProdServ: 8,
+}
+
+// nombresColumna Nombre con que se guardan en Clave.Datos las columnas cuyo nombre normalizado no es descriptivo.
+var nombresColumna = map[string]string{
+	"RangoOFijo":                "Tipo",
+	"ValorMinimo":               "Minimo",
+	"ValorMaximo":               "Maximo",
+	"LocalOFederal":             "Ambito",
+	"DescripcionDelHusoHorario": "HusoHorario",
+}
+
+// agruparHojas Une las hojas divididas en partes y devuelve las filas de cada catálogo, sin repetir los encabezados de cada parte.
+func agruparHojas(hojas []Hoja) map[string][]Hoja {
+	grupos := map[string][]Hoja{}
+	for _, h := range hojas {
+		nombre := parteHoja.ReplaceAllString(strings.TrimSpace(h.Nombre), "")
+		grupos[nombre] = append(grupos[nombre], h)
+	}
+	return grupos
+}
+
+// convertir Construye el catálogo nombre a partir de sus hojas. Las hojas comienzan con renglones de título; el encabezado es el primer renglón que contiene la columna de fecha de inicio de vigencia y puede ir seguido de un renglón de subencabezados (p.ej. Física y Moral en c_UsoCFDI).
+func convertir(nombre, version string, hojas []Hoja) (*catalogos.Catalogo, error) {
+	c := &catalogos.Catalogo{Nombre: nombre, Version: version, Completo: true}
+	for _, h := range hojas {
+		encabezado, datos, err := separarEncabezado(h.Filas)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.Nombre, err)
+		}
+		secuencial := normalizar(encabezado[0]) != normalizar(nombre)
+		// La descripción es la columna Nombre cuando existe (c_ClaveUnidad tiene ambas) y si no la columna Descripción.
+		descripcion, nombreColumna := -1, -1
+		for j, titulo := range encabezado {
+			switch normalizar(titulo) {
+			case "Descripcion":
+				descripcion = j
+			case "Nombre":
+				nombreColumna = j
+			}
+		}
+		if nombreColumna >= 0 {
+			descripcion = nombreColumna
+		}
+		for _, fila := range datos {
+			if len(fila) == 0 || strings.TrimSpace(fila[0]) == "" {
+				continue
+			}
+			k := catalogos.Clave{}
+			if secuencial {
+				k.Clave = strconv.Itoa(len(c.Claves) + 1)
+			} else {
+				k.Clave = completarClave(nombre, strings.TrimSpace(fila[0]))
+			}
+			for j := 0; j < len(fila) && j < len(encabezado); j++ {
+				valor := strings.TrimSpace(fila[j])
+				if j == 0 && !secuencial || valor == "" {
+					continue
+				}
+				columna := normalizar(encabezado[j])
+				switch {
+				case j == descripcion:
+					k.Descripcion = valor
+				case strings.HasPrefix(columna, "FechaInicio") || strings.HasPrefix(columna, "FechaDeInicio"):
+					k.InicioVigencia = fecha(valor)
+				case strings.HasPrefix(columna, "FechaFin") || strings.HasPrefix(columna, "FechaDeFin"):
+					k.FinVigencia = fecha(valor)
+				case columna == "Fisica" || columna == "PersonaFisica":
+					k.Fisica = esSi(valor)
+				case columna == "Moral" || columna == "PersonaMoral":
+					k.Moral = esSi(valor)
+				default:
+					if alias, ok := nombresColumna[columna]; ok {
+						columna = alias
+					}
+					if k.Datos == nil {
+						k.Datos = map[string]string{}
+					}
+					k.Datos[columna] = valor
+				}
+			}
+			c.Claves = append(c.Claves, k)
+		}
+	}
+	return c, nil
+}
+
+// separarEncabezado Devuelve el encabezado de la hoja, combinado con los subencabezados si los hay, y los renglones de datos.
+func separarEncabezado(filas [][]string) ([]string, [][]string, error) {
+	for i, fila := range filas {
+		esEncabezado := false
+		for _, celda := range fila {
+			columna := normalizar(celda)
+			esEncabezado = esEncabezado || strings.HasPrefix(columna, "FechaInicio") || strings.HasPrefix(columna, "FechaDeInicio")
+		}
+		if !esEncabezado {
+			continue
+		}
+		encabezado := append([]string(nil), fila...)
+		datos := filas[i+1:]
+		if len(datos) > 0 && len(datos[0]) > 0 && strings.TrimSpace(datos[0][0]) == "" {
+			for j, sub := range datos[0] {
+				if strings.TrimSpace(sub) == "" {
+					continue
+				}
+				for len(encabezado) <= j {
+					encabezado = append(encabezado, "")
+				}
+				encabezado[j] = sub
+			}
+			datos = datos[1:]
+		}
+		return encabezado, datos, nil
+	}
+	return nil, nil, fmt.Errorf("no se encontró el renglón de encabezados")
+}
+
+// sinAcentos Sustituye las vocales acentuadas y la eñe por su letra base.
+var sinAcentos = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n", "Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N")
+
+// normalizar Convierte un título de columna a CamelCase sin acentos ni el prefijo c_, p.ej. "Porcentaje variación" → PorcentajeVariacion y "c_Estado" → Estado.
+func normalizar(titulo string) string {
+	titulo = strings.TrimPrefix(strings.TrimSpace(titulo), "c_")
+	var b strings.Builder
+	mayuscula := true
+	for _, r := range sinAcentos.Replace(titulo) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if mayuscula {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			mayuscula = false
+		default:
+			mayuscula = true
+		}
+	}
+	return b.String()
+}
+
+// completarClave Restituye los ceros a la izquierda de las claves numéricas que Excel guardó como número.
+func completarClave(nombre, clave string) string {
+	ancho, ok := anchoClave[nombre]
+	if !ok || len(clave) >= ancho || strings.Trim(clave, "0123456789") != "" {
+		return clave
+	}
+	return strings.Repeat("0", ancho-len(clave)) + clave
+}
+
+// fecha Convierte una fecha de Excel (número de serie o dd/mm/aaaa) al formato AAAA-MM-DD.
+func fecha(valor string) string {
+	if n, err := strconv.ParseFloat(valor, 64); err == nil {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(n)).Format(catalogos.FormatoFecha)
+	}
+	for _, formato := range []string{"02/01/2006", "2/1/2006", catalogos.FormatoFecha, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(formato, valor); err == nil {
+			return t.Format(catalogos.FormatoFecha)
+		}
+	}
+	return valor
+}
+
+// esSi Indica si valor es afirmativo (Sí, Si, X).
+func esSi(valor string) bool {
+	v := normalizar(valor)
+	return v == "Si" || v == "SI" || v == "X"
+}
+
+// Diferencias Claves agregadas, eliminadas y modificadas entre dos versiones de un catálogo.
+type Diferencias struct {
+	Agregadas     []catalogos.Clave
+	Eliminadas    []catalogos.Clave
+	Modificadas   []catalogos.Clave
+	TotalAnterior int
+	TotalNuevo    int
+}
+
+// comparar Devuelve las diferencias de nuevo respecto de anterior, que puede ser nil.
+func comparar(anterior, nuevo *catalogos.Catalogo) Diferencias {
+	d := Diferencias{TotalNuevo: len(nuevo.Claves)}
+	previas := map[string]catalogos.Clave{}
+	if anterior != nil {
+		d.TotalAnterior = len(anterior.Claves)
+		for _, k := range anterior.Claves {
+			previas[k.Clave] = k
+		}
+	}
+	for _, k := range nuevo.Claves {
+		previa, ok := previas[k.Clave]
+		switch {
+		case !ok:
+			d.Agregadas = append(d.Agregadas, k)
+		case !igualClave(previa, k):
+			d.Modificadas = append(d.Modificadas, k)
+		}
+		delete(previas, k.Clave)
+	}
+	for _, k := range previas {
+		d.Eliminadas = append(d.Eliminadas, k)
+	}
+	sort.Slice(d.Eliminadas, func(i, j int) bool { return d.Eliminadas[i].Clave < d.Eliminadas[j].Clave })
+	return d
+}
+
+// igualClave Indica si a y b tienen los mismos datos.
+func igualClave(a, b catalogos.Clave) bool {
+	if a.Descripcion != b.Descripcion || a.InicioVigencia != b.InicioVigencia || a.FinVigencia != b.FinVigencia || a.Fisica != b.Fisica || a.Moral != b.Moral || len(a.Datos) != len(b.Datos) {
+		return false
+	}
+	for k, v := range a.Datos {
+		if b.Datos[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"../XMLStructures/Catalogos"
+)
+
+// Los libros de testdata reproducen la estructura del catCFDI del SAT en pocas filas: renglones de título antes del encabezado, subencabezados (Física y Moral en c_UsoCFDI, Descripción del Huso Horario en c_CodigoPostal), c_CodigoPostal dividido en hojas _Parte_N, claves numéricas que Excel guardó como número sin ceros a la izquierda, fechas como número de serie y una hoja que no se actualiza (c_Exportacion). catCFDI.xls usa registros LABELSST, LABEL, RK, MULRK y NUMBER y una SST partida con CONTINUE; catCFDI.xlsx cadenas compartidas, con formato y en línea.
+var librosPrueba = []string{"catCFDI.xls", "catCFDI.xlsx"}
+
+// catalogosEsperados Catálogos que se obtienen de los libros de testdata con versión 2026-01-01.
+var catalogosEsperados = map[string]*catalogos.Catalogo{
+	catalogos.FormaPago: {Nombre: catalogos.FormaPago, Version: "2026-01-01", Completo: true, Claves: []catalogos.Clave{
+		{Clave: "01", Descripcion: "Efectivo", InicioVigencia: "2017-01-01", Datos: map[string]string{"Bancarizado": "No"}},
+		{Clave: "02", Descripcion: "Cheque nominativo", InicioVigencia: "2017-01-01", Datos: map[string]string{"Bancarizado": "Sí"}},
+		{Clave: "32", Descripcion: "Nueva forma", InicioVigencia: "2024-01-01", Datos: map[string]string{"Bancarizado": "No"}},
+	}},
+	catalogos.UsoCFDI: {Nombre: catalogos.UsoCFDI, Version: "2026-01-01", Completo: true, Claves: []catalogos.Clave{
+		{Clave: "G01", Descripcion: "Adquisición de mercancias", InicioVigencia: "2017-01-01", Fisica: true, Moral: true},
+		{Clave: "D01", Descripcion: "Honorarios médicos, dentales y gastos hospitalarios.", InicioVigencia: "2017-01-01", Fisica: true},
+	}},
+	catalogos.CodigoPostal: {Nombre: catalogos.CodigoPostal, Version: "2026-01-01", Completo: true, Claves: []catalogos.Clave{
+		{Clave: "01000", InicioVigencia: "2017-01-01", Datos: map[string]string{"Estado": "CMX", "Municipio": "010", "HusoHorario": "Tiempo del Centro"}},
+		{Clave: "22000", InicioVigencia: "2017-01-01", Datos: map[string]string{"Estado": "BCN", "Municipio": "004", "HusoHorario": "Tiempo del Pacífico"}},
+	}},
+	catalogos.TasaOCuota: {Nombre: catalogos.TasaOCuota, Version: "2026-01-01", Completo: true, Claves: []catalogos.Clave{
+		{Clave: "1", InicioVigencia: "2017-01-01", Datos: map[string]string{"Tipo": "Fijo", "Maximo": "0.16", "Impuesto": "IVA", "Factor": "Tasa", "Traslado": "Sí", "Retencion": "No"}},
+		{Clave: "2", InicioVigencia: "2017-01-01", Datos: map[string]string{"Tipo": "Rango", "Minimo": "0", "Maximo": "0.35", "Impuesto": "ISR", "Factor": "Tasa", "Traslado": "No", "Retencion": "Sí"}},
+	}},
+}
+
+func TestConvertirLibros(t *testing.T) {
+	for _, libro := range librosPrueba {
+		t.Run(libro, func(t *testing.T) {
+			hojas, err := leerLibro(filepath.Join("testdata", libro))
+			if err != nil {
+				t.Fatal(err)
+			}
+			grupos := agruparHojas(hojas)
+			if len(grupos[catalogos.CodigoPostal]) != 2 {
+				t.Errorf("c_CodigoPostal debe unir 2 partes, se agruparon %d", len(grupos[catalogos.CodigoPostal]))
+			}
+			if _, ok := grupos["c_Exportacion"]; !ok {
+				t.Error("no se leyó la hoja c_Exportacion")
+			}
+			for nombre, esperado := range catalogosEsperados {
+				c, err := convertir(nombre, "2026-01-01", grupos[nombre])
+				if err != nil {
+					t.Fatalf("%s: %v", nombre, err)
+				}
+				if !reflect.DeepEqual(c, esperado) {
+					t.Errorf("%s:\nse obtuvo   %+v\nse esperaba %+v", nombre, c.Claves, esperado.Claves)
+				}
+			}
+		})
+	}
+}
+
+func TestActualizar(t *testing.T) {
+	directorio := t.TempDir()
+	anterior := &catalogos.Catalogo{Nombre: catalogos.FormaPago, Version: "2022-01-21", Claves: []catalogos.Clave{
+		{Clave: "01", Descripcion: "Efectivo", InicioVigencia: "2017-01-01", Datos: map[string]string{"Bancarizado": "No"}},
+		{Clave: "02", Descripcion: "Cheque", InicioVigencia: "2017-01-01", Datos: map[string]string{"Bancarizado": "Sí"}},
+		{Clave: "99", Descripcion: "Por definir", InicioVigencia: "2017-01-01"},
+	}}
+	contenido, err := codificar(anterior)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivos := map[string][]byte{catalogos.FormaPago: contenido, catalogos.Moneda: []byte(`{"nombre": "c_Moneda", "claves": []}`)}
+	for nombre, datos := range archivos {
+		if err := os.WriteFile(filepath.Join(directorio, nombre+".json"), datos, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var reporte bytes.Buffer
+	if err := actualizar(filepath.Join("testdata", "catCFDI.xlsx"), directorio, "2026-01-01", false, 20, &reporte); err != nil {
+		t.Fatal(err)
+	}
+	for _, linea := range []string{
+		"c_FormaPago: 3 → 3 claves (+1 -1 ~1)",
+		"  + 32 Nueva forma",
+		"  - 99 Por definir",
+		"  ~ 02 Cheque nominativo",
+		"c_Moneda: no se encontró en el libro, se conserva sin cambios",
+		"hojas omitidas: c_CodigoPostal, c_Exportacion, c_TasaOCuota, c_UsoCFDI",
+	} {
+		if !strings.Contains(reporte.String(), linea+"\n") {
+			t.Errorf("el reporte no contiene %q:\n%s", linea, reporte.String())
+		}
+	}
+	escrito, err := os.ReadFile(filepath.Join(directorio, catalogos.FormaPago+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := catalogos.Leer(escrito)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _ := catalogos.Leer(mustCodificar(t, catalogosEsperados[catalogos.FormaPago]))
+	if !reflect.DeepEqual(c, c2) {
+		t.Errorf("c_FormaPago escrito:\n%s", escrito)
+	}
+	moneda, _ := os.ReadFile(filepath.Join(directorio, catalogos.Moneda+".json"))
+	if !bytes.Equal(moneda, archivos[catalogos.Moneda]) {
+		t.Errorf("c_Moneda cambió: %s", moneda)
+	}
+}
+
+func TestLeerLibroDesconocido(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "nota.xls")
+	if err := os.WriteFile(ruta, []byte("esto no es un libro"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leerLibro(ruta); err == nil || !strings.Contains(err.Error(), "no es un libro xls ni xlsx") {
+		t.Errorf("leerLibro devolvió %v", err)
+	}
+}
+
+func TestNormalizar(t *testing.T) {
+	casos := map[string]string{
+		"c_Estado":                     "Estado",
+		"Porcentaje variación":         "PorcentajeVariacion",
+		"Fecha inicio de vigencia":     "FechaInicioDeVigencia",
+		"Descripción del Huso Horario": "DescripcionDelHusoHorario",
+		" Retención ":                  "Retencion",
+	}
+	for titulo, esperado := range casos {
+		if got := normalizar(titulo); got != esperado {
+			t.Errorf("normalizar(%q) = %q, se esperaba %q", titulo, got, esperado)
+		}
+	}
+	for valor, esperado := range map[string]string{"42736": "2017-01-01", "01/01/2017": "2017-01-01", "2017-01-01": "2017-01-01", "1/2/2017": "2017-02-01"} {
+		if got := fecha(valor); got != esperado {
+			t.Errorf("fecha(%q) = %q, se esperaba %q", valor, got, esperado)
+		}
+	}
+}
+
+// mustCodificar Codifica c o detiene la prueba.
+func mustCodificar(t *testing.T, c *catalogos.Catalogo) []byte {
+	t.Helper()
+	datos, err := codificar(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return datos
+}
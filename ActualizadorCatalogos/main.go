@@ -0,0 +1,156 @@
+// ActualizadorCatalogos Regenera los catálogos incluidos en el paquete catalogos a partir del libro catCFDI publicado por el SAT.
+//
+// Uso:
+//
+//	go run ./ActualizadorCatalogos -libro catCFDI.xls [-datos XMLStructures/Catalogos/datos] [-version AAAA-MM-DD] [-n]
+//
+// Sólo se regeneran los catálogos que ya existen en el directorio de datos; las demás hojas del libro se listan como omitidas. Al terminar imprime, por catálogo, las claves agregadas, eliminadas y modificadas respecto de los datos anteriores. Con -n sólo imprime el reporte sin escribir nada.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"../XMLStructures/Catalogos"
+)
+
+func main() {
+	libro := flag.String("libro", "", "ruta del libro catCFDI descargado del SAT (xls o xlsx)")
+	directorio := flag.String("datos", filepath.Join("XMLStructures", "Catalogos", "datos"), "directorio con los archivos JSON de los catálogos")
+	version := flag.String("version", "", "versión de los catálogos; por omisión la fecha de modificación del libro")
+	simulacion := flag.Bool("n", false, "sólo mostrar las diferencias, sin escribir los catálogos")
+	detalle := flag.Int("detalle", 20, "máximo de claves a listar por tipo de cambio en cada catálogo")
+	flag.Parse()
+	if *libro == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := actualizar(*libro, *directorio, *version, *simulacion, *detalle, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ActualizadorCatalogos:", err)
+		os.Exit(1)
+	}
+}
+
+// actualizar Lee el libro, convierte cada catálogo existente en directorio y escribe el reporte de diferencias en w.
+func actualizar(libro, directorio, version string, simulacion bool, detalle int, w io.Writer) error {
+	info, err := os.Stat(libro)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = info.ModTime().Format(catalogos.FormatoFecha)
+	}
+	hojas, err := leerLibro(libro)
+	if err != nil {
+		return err
+	}
+	grupos := agruparHojas(hojas)
+	existentes, err := filepath.Glob(filepath.Join(directorio, "c_*.json"))
+	if err != nil {
+		return err
+	}
+	if len(existentes) == 0 {
+		return fmt.Errorf("no hay catálogos en %s", directorio)
+	}
+	incluidos := map[string]bool{}
+	for _, archivo := range existentes {
+		nombre := strings.TrimSuffix(filepath.Base(archivo), ".json")
+		incluidos[nombre] = true
+		hojasCatalogo, ok := grupos[nombre]
+		if !ok {
+			fmt.Fprintf(w, "%s: no se encontró en el libro, se conserva sin cambios\n", nombre)
+			continue
+		}
+		nuevo, err := convertir(nombre, version, hojasCatalogo)
+		if err != nil {
+			return err
+		}
+		contenido, err := codificar(nuevo)
+		if err != nil {
+			return err
+		}
+		// Se vuelve a leer para detectar claves repetidas antes de escribir.
+		if _, err := catalogos.Leer(contenido); err != nil {
+			return fmt.Errorf("%s: %w", nombre, err)
+		}
+		var anterior *catalogos.Catalogo
+		if previo, err := os.ReadFile(archivo); err == nil {
+			anterior, _ = catalogos.Leer(previo)
+		}
+		reportar(w, nombre, comparar(anterior, nuevo), detalle)
+		if simulacion {
+			continue
+		}
+		if err := os.WriteFile(archivo, contenido, 0644); err != nil {
+			return err
+		}
+	}
+	var omitidas []string
+	for nombre := range grupos {
+		if !incluidos[nombre] && strings.HasPrefix(nombre, "c_") {
+			omitidas = append(omitidas, nombre)
+		}
+	}
+	if len(omitidas) > 0 {
+		sort.Strings(omitidas)
+		fmt.Fprintf(w, "hojas omitidas: %s\n", strings.Join(omitidas, ", "))
+	}
+	return nil
+}
+
+// leerLibro Lee las hojas del libro según su formato, reconocido por su contenido y no por la extensión.
+func leerLibro(ruta string) ([]Hoja, error) {
+	f, err := os.Open(ruta)
+	if err != nil {
+		return nil, err
+	}
+	cabecera := make([]byte, 8)
+	_, err = io.ReadFull(f, cabecera)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+	switch {
+	case bytes.Equal(cabecera, firmaCFB):
+		return leerXLS(ruta)
+	case bytes.HasPrefix(cabecera, []byte("PK\x03\x04")):
+		return leerXLSX(ruta)
+	}
+	return nil, fmt.Errorf("%s: el archivo no es un libro xls ni xlsx", ruta)
+}
+
+// codificar Escribe el catálogo en el formato de datos/.
+func codificar(c *catalogos.Catalogo) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// reportar Escribe en w el resumen de diferencias de un catálogo y hasta detalle claves de cada tipo.
+func reportar(w io.Writer, nombre string, d Diferencias, detalle int) {
+	fmt.Fprintf(w, "%s: %d → %d claves (+%d -%d ~%d)\n", nombre, d.TotalAnterior, d.TotalNuevo, len(d.Agregadas), len(d.Eliminadas), len(d.Modificadas))
+	listar := func(signo string, claves []catalogos.Clave) {
+		for i, k := range claves {
+			if i == detalle {
+				fmt.Fprintf(w, "  %s ... y %d más\n", signo, len(claves)-detalle)
+				return
+			}
+			fmt.Fprintf(w, "  %s %s %s\n", signo, k.Clave, k.Descripcion)
+		}
+	}
+	listar("+", d.Agregadas)
+	listar("-", d.Eliminadas)
+	listar("~", d.Modificadas)
+}
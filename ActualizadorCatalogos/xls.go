@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"unicode/utf16"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Lectura de libros xls (BIFF8 dentro de un archivo compuesto OLE)
+*
+*
+****************************************************************************************************************************************/
+
+// ErrXLSCifrado Indica que el libro está protegido con contraseña.
+var ErrXLSCifrado = errors.New("xls: el libro está cifrado; guárdelo sin contraseña")
+
+// firmaCFB Primeros bytes de un archivo compuesto OLE.
+var firmaCFB = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Valores especiales de las cadenas de sectores.
+const (
+	sectorFinCadena = 0xFFFFFFFE
+	sectorLibre     = 0xFFFFFFFF
+)
+
+// leerXLS Lee todas las hojas de un libro xls de Excel 97-2003.
+func leerXLS(ruta string) ([]Hoja, error) {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, err
+	}
+	libro, err := flujoCFB(datos, "Workbook")
+	if err != nil {
+		if libro, err = flujoCFB(datos, "Book"); err != nil {
+			return nil, err
+		}
+	}
+	return leerBIFF8(libro)
+}
+
+// flujoCFB Devuelve el contenido del flujo nombre del archivo compuesto datos.
+func flujoCFB(datos []byte, nombre string) ([]byte, error) {
+	if len(datos) < 512 || string(datos[:8]) != string(firmaCFB) {
+		return nil, errors.New("xls: el archivo no es un documento compuesto OLE")
+	}
+	le := binary.LittleEndian
+	tamSector := 1 << le.Uint16(datos[0x1E:])
+	tamMini := 1 << le.Uint16(datos[0x20:])
+	primerDirectorio := le.Uint32(datos[0x30:])
+	corteMini := le.Uint32(datos[0x38:])
+	primerMiniFAT := le.Uint32(datos[0x3C:])
+	primerDIFAT := le.Uint32(datos[0x44:])
+	numDIFAT := le.Uint32(datos[0x48:])
+
+	sector := func(n uint32) []byte {
+		inicio := (int(n) + 1) * tamSector
+		if inicio < 0 || inicio+tamSector > len(datos) {
+			return nil
+		}
+		return datos[inicio : inicio+tamSector]
+	}
+
+	// La tabla de asignación (FAT) se forma con los sectores listados en el DIFAT.
+	var sectoresFAT []uint32
+	for i := 0; i < 109; i++ {
+		s := le.Uint32(datos[0x4C+4*i:])
+		if s != sectorLibre {
+			sectoresFAT = append(sectoresFAT, s)
+		}
+	}
+	for s, i := primerDIFAT, uint32(0); s != sectorFinCadena && s != sectorLibre && i < numDIFAT; i++ {
+		b := sector(s)
+		if b == nil {
+			return nil, errors.New("xls: DIFAT dañado")
+		}
+		for j := 0; j < tamSector/4-1; j++ {
+			if f := le.Uint32(b[4*j:]); f != sectorLibre {
+				sectoresFAT = append(sectoresFAT, f)
+			}
+		}
+		s = le.Uint32(b[tamSector-4:])
+	}
+	var fat []uint32
+	for _, s := range sectoresFAT {
+		b := sector(s)
+		if b == nil {
+			return nil, errors.New("xls: FAT dañada")
+		}
+		for j := 0; j < tamSector/4; j++ {
+			fat = append(fat, le.Uint32(b[4*j:]))
+		}
+	}
+	cadena := func(inicio uint32) ([]byte, error) {
+		var flujo []byte
+		for s, n := inicio, 0; s != sectorFinCadena; n++ {
+			b := sector(s)
+			if b == nil || int(s) >= len(fat) || n > len(fat) {
+				return nil, errors.New("xls: cadena de sectores dañada")
+			}
+			flujo = append(flujo, b...)
+			s = fat[s]
+		}
+		return flujo, nil
+	}
+
+	directorio, err := cadena(primerDirectorio)
+	if err != nil {
+		return nil, err
+	}
+	var raiz, buscado []byte
+	for i := 0; i+128 <= len(directorio); i += 128 {
+		entrada := directorio[i : i+128]
+		largo := int(le.Uint16(entrada[64:]))
+		if largo < 2 || largo > 64 {
+			continue
+		}
+		unidades := make([]uint16, largo/2-1)
+		for j := range unidades {
+			unidades[j] = le.Uint16(entrada[2*j:])
+		}
+		switch tipo := entrada[66]; {
+		case tipo == 5:
+			raiz = entrada
+		case tipo == 2 && string(utf16.Decode(unidades)) == nombre:
+			buscado = entrada
+		}
+	}
+	if buscado == nil {
+		return nil, fmt.Errorf("xls: no existe el flujo %s", nombre)
+	}
+	inicio := le.Uint32(buscado[116:])
+	tamano := int(le.Uint32(buscado[120:]))
+	if uint32(tamano) >= corteMini {
+		flujo, err := cadena(inicio)
+		if err != nil || len(flujo) < tamano {
+			return nil, errors.New("xls: flujo truncado")
+		}
+		return flujo[:tamano], nil
+	}
+
+	// Los flujos pequeños viven en el miniflujo del nodo raíz, indexado por la MiniFAT.
+	if raiz == nil {
+		return nil, errors.New("xls: falta el nodo raíz")
+	}
+	miniflujo, err := cadena(le.Uint32(raiz[116:]))
+	if err != nil {
+		return nil, err
+	}
+	bytesMiniFAT, err := cadena(primerMiniFAT)
+	if err != nil {
+		return nil, err
+	}
+	var flujo []byte
+	for s, n := inicio, 0; s != sectorFinCadena && len(flujo) < tamano; n++ {
+		a := int(s) * tamMini
+		if a+tamMini > len(miniflujo) || int(s)*4+4 > len(bytesMiniFAT) || n > len(bytesMiniFAT)/4 {
+			return nil, errors.New("xls: miniflujo dañado")
+		}
+		flujo = append(flujo, miniflujo[a:a+tamMini]...)
+		s = le.Uint32(bytesMiniFAT[4*s:])
+	}
+	if len(flujo) < tamano {
+		return nil, errors.New("xls: flujo truncado")
+	}
+	return flujo[:tamano], nil
+}
+
+// Tipos de registro BIFF8 que se interpretan.
+const (
+	registroFormula    = 0x0006
+	registroEOF        = 0x000A
+	registroFilePass   = 0x002F
+	registroContinue   = 0x003C
+	registroBoundSheet = 0x0085
+	registroMulRK      = 0x00BD
+	registroSST        = 0x00FC
+	registroLabelSST   = 0x00FD
+	registroNumber     = 0x0203
+	registroLabel      = 0x0204
+	registroBoolErr    = 0x0205
+	registroString     = 0x0207
+	registroRK         = 0x027E
+	registroBOF        = 0x0809
+)
+
+// registroBIFF Registro del flujo Workbook con sus registros CONTINUE.
+type registroBIFF struct {
+	tipo     uint16
+	posicion int      // Posición del registro en el flujo.
+	partes   [][]byte // Datos del registro seguidos de los de cada CONTINUE.
+}
+
+// leerBIFF8 Interpreta el flujo Workbook y devuelve sus hojas en el orden del libro.
+func leerBIFF8(flujo []byte) ([]Hoja, error) {
+	le := binary.LittleEndian
+	var registros []registroBIFF
+	for i := 0; i+4 <= len(flujo); {
+		tipo := le.Uint16(flujo[i:])
+		largo := int(le.Uint16(flujo[i+2:]))
+		if i+4+largo > len(flujo) {
+			return nil, errors.New("xls: registro truncado")
+		}
+		datos := flujo[i+4 : i+4+largo]
+		if tipo == registroContinue && len(registros) > 0 {
+			ultimo := &registros[len(registros)-1]
+			ultimo.partes = append(ultimo.partes, datos)
+		} else {
+			registros = append(registros, registroBIFF{tipo: tipo, posicion: i, partes: [][]byte{datos}})
+		}
+		i += 4 + largo
+	}
+
+	var hojas []Hoja
+	indicePorPosicion := map[int]int{}
+	var sst []string
+	actual := -1
+	var filas [][]string
+	cerrar := func() {
+		if actual >= 0 {
+			hojas[actual].Filas = filas
+		}
+		actual, filas = -1, nil
+	}
+	poner := func(fila, columna int, valor string) {
+		if actual < 0 {
+			return
+		}
+		for len(filas) <= fila {
+			filas = append(filas, nil)
+		}
+		for len(filas[fila]) <= columna {
+			filas[fila] = append(filas[fila], "")
+		}
+		filas[fila][columna] = valor
+	}
+	var formulaPendiente []int
+	for _, r := range registros {
+		d := r.partes[0]
+		switch r.tipo {
+		case registroFilePass:
+			return nil, ErrXLSCifrado
+		case registroBOF:
+			if i, ok := indicePorPosicion[r.posicion]; ok {
+				actual, filas = i, nil
+			}
+		case registroEOF:
+			cerrar()
+		case registroBoundSheet:
+			if len(d) < 8 {
+				return nil, errors.New("xls: BOUNDSHEET inválido")
+			}
+			nombre, err := (&lectorBIFF{partes: [][]byte{d[6:]}}).cadena(1)
+			if err != nil {
+				return nil, err
+			}
+			indicePorPosicion[int(le.Uint32(d))] = len(hojas)
+			hojas = append(hojas, Hoja{Nombre: nombre})
+		case registroSST:
+			l := &lectorBIFF{partes: r.partes}
+			l.saltar(4)
+			unicas, err := l.u32()
+			if err != nil {
+				return nil, err
+			}
+			sst = make([]string, 0, unicas)
+			for i := uint32(0); i < unicas; i++ {
+				s, err := l.cadena(2)
+				if err != nil {
+					return nil, fmt.Errorf("xls: SST: %w", err)
+				}
+				sst = append(sst, s)
+			}
+		case registroLabelSST:
+			if len(d) < 10 {
+				continue
+			}
+			if i := int(le.Uint32(d[6:])); i < len(sst) {
+				poner(int(le.Uint16(d)), int(le.Uint16(d[2:])), sst[i])
+			}
+		case registroLabel:
+			if len(d) < 8 {
+				continue
+			}
+			s, err := (&lectorBIFF{partes: [][]byte{d[6:]}}).cadena(2)
+			if err != nil {
+				return nil, err
+			}
+			poner(int(le.Uint16(d)), int(le.Uint16(d[2:])), s)
+		case registroNumber:
+			if len(d) < 14 {
+				continue
+			}
+			poner(int(le.Uint16(d)), int(le.Uint16(d[2:])), formatoNumero(math.Float64frombits(le.Uint64(d[6:]))))
+		case registroRK:
+			if len(d) < 10 {
+				continue
+			}
+			poner(int(le.Uint16(d)), int(le.Uint16(d[2:])), formatoNumero(valorRK(le.Uint32(d[6:]))))
+		case registroMulRK:
+			if len(d) < 6 {
+				continue
+			}
+			fila, columna := int(le.Uint16(d)), int(le.Uint16(d[2:]))
+			for i := 4; i+6 <= len(d)-2; i += 6 {
+				poner(fila, columna, formatoNumero(valorRK(le.Uint32(d[i+2:]))))
+				columna++
+			}
+		case registroBoolErr:
+			if len(d) < 8 || d[7] != 0 {
+				continue
+			}
+			valor := "FALSO"
+			if d[6] != 0 {
+				valor = "VERDADERO"
+			}
+			poner(int(le.Uint16(d)), int(le.Uint16(d[2:])), valor)
+		case registroFormula:
+			if len(d) < 14 {
+				continue
+			}
+			fila, columna := int(le.Uint16(d)), int(le.Uint16(d[2:]))
+			resultado := d[6:14]
+			if le.Uint16(resultado[6:]) != 0xFFFF {
+				poner(fila, columna, formatoNumero(math.Float64frombits(le.Uint64(resultado))))
+				continue
+			}
+			if resultado[0] == 0 {
+				// El texto resultante llega en el registro STRING siguiente.
+				formulaPendiente = []int{fila, columna}
+			}
+		case registroString:
+			if formulaPendiente == nil {
+				continue
+			}
+			s, err := (&lectorBIFF{partes: r.partes}).cadena(2)
+			if err != nil {
+				return nil, err
+			}
+			poner(formulaPendiente[0], formulaPendiente[1], s)
+			formulaPendiente = nil
+		}
+	}
+	cerrar()
+	return hojas, nil
+}
+
+// valorRK Decodifica un número en formato RK.
+func valorRK(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
+
+// formatoNumero Escribe f sin exponente ni ceros sobrantes.
+func formatoNumero(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// lectorBIFF Lee datos que pueden continuar en registros CONTINUE. Al cruzar a la siguiente parte a mitad de una cadena, la nueva parte comienza con el indicador de compresión de los caracteres restantes.
+type lectorBIFF struct {
+	partes [][]byte
+	parte  int
+	pos    int
+}
+
+// disponible Avanza a la siguiente parte si la actual se agotó y devuelve si quedan datos.
+func (l *lectorBIFF) disponible() bool {
+	for l.parte < len(l.partes) && l.pos >= len(l.partes[l.parte]) {
+		l.parte++
+		l.pos = 0
+	}
+	return l.parte < len(l.partes)
+}
+
+// byte Lee un byte.
+func (l *lectorBIFF) byte() (byte, error) {
+	if !l.disponible() {
+		return 0, errors.New("datos truncados")
+	}
+	b := l.partes[l.parte][l.pos]
+	l.pos++
+	return b, nil
+}
+
+// u16 Lee un entero de 16 bits.
+func (l *lectorBIFF) u16() (uint16, error) {
+	a, err := l.byte()
+	if err != nil {
+		return 0, err
+	}
+	b, err := l.byte()
+	return uint16(a) | uint16(b)<<8, err
+}
+
+// u32 Lee un entero de 32 bits.
+func (l *lectorBIFF) u32() (uint32, error) {
+	a, err := l.u16()
+	if err != nil {
+		return 0, err
+	}
+	b, err := l.u16()
+	return uint32(a) | uint32(b)<<16, err
+}
+
+// saltar Descarta n bytes.
+func (l *lectorBIFF) saltar(n int) error {
+	for ; n > 0; n-- {
+		if _, err := l.byte(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cadena Lee una cadena Unicode de BIFF8 cuyo número de caracteres ocupa anchoLargo bytes (1 o 2).
+func (l *lectorBIFF) cadena(anchoLargo int) (string, error) {
+	var largo int
+	if anchoLargo == 1 {
+		b, err := l.byte()
+		if err != nil {
+			return "", err
+		}
+		largo = int(b)
+	} else {
+		n, err := l.u16()
+		if err != nil {
+			return "", err
+		}
+		largo = int(n)
+	}
+	opciones, err := l.byte()
+	if err != nil {
+		return "", err
+	}
+	var corridas, extendido int
+	if opciones&0x08 != 0 {
+		n, err := l.u16()
+		if err != nil {
+			return "", err
+		}
+		corridas = int(n)
+	}
+	if opciones&0x04 != 0 {
+		n, err := l.u32()
+		if err != nil {
+			return "", err
+		}
+		extendido = int(n)
+	}
+	anchos := opciones&0x01 != 0
+	unidades := make([]uint16, 0, largo)
+	for len(unidades) < largo {
+		if l.parte >= len(l.partes) || l.pos >= len(l.partes[l.parte]) {
+			// La cadena continúa en el siguiente registro CONTINUE, que repite el indicador de compresión.
+			if !l.disponible() {
+				return "", errors.New("cadena truncada")
+			}
+			opciones, _ = l.byte()
+			anchos = opciones&0x01 != 0
+		}
+		if anchos {
+			u, err := l.u16()
+			if err != nil {
+				return "", err
+			}
+			unidades = append(unidades, u)
+		} else {
+			b, err := l.byte()
+			if err != nil {
+				return "", err
+			}
+			unidades = append(unidades, uint16(b))
+		}
+	}
+	if err := l.saltar(4*corridas + extendido); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(unidades)), nil
+}
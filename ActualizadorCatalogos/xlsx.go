@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Lectura de libros xlsx (Office Open XML)
+*
+*
+****************************************************************************************************************************************/
+
+// leerXLSX Lee todas las hojas de un libro xlsx.
+func leerXLSX(ruta string) ([]Hoja, error) {
+	z, err := zip.OpenReader(ruta)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	archivos := map[string]*zip.File{}
+	for _, f := range z.File {
+		archivos[f.Name] = f
+	}
+
+	var libro struct {
+		Hojas []struct {
+			Nombre string `xml:"name,attr"`
+			ID     string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := leerXMLZip(archivos, "xl/workbook.xml", &libro); err != nil {
+		return nil, err
+	}
+	var relaciones struct {
+		Relaciones []struct {
+			ID      string `xml:"Id,attr"`
+			Destino string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := leerXMLZip(archivos, "xl/_rels/workbook.xml.rels", &relaciones); err != nil {
+		return nil, err
+	}
+	destinos := map[string]string{}
+	for _, r := range relaciones.Relaciones {
+		destino := r.Destino
+		if strings.HasPrefix(destino, "/") {
+			destino = strings.TrimPrefix(destino, "/")
+		} else {
+			destino = path.Join("xl", destino)
+		}
+		destinos[r.ID] = destino
+	}
+
+	var compartidas []string
+	if _, ok := archivos["xl/sharedStrings.xml"]; ok {
+		if compartidas, err = leerCadenasCompartidas(archivos["xl/sharedStrings.xml"]); err != nil {
+			return nil, err
+		}
+	}
+
+	hojas := make([]Hoja, 0, len(libro.Hojas))
+	for _, h := range libro.Hojas {
+		f, ok := archivos[destinos[h.ID]]
+		if !ok {
+			return nil, fmt.Errorf("xlsx: no se encontró la hoja %s (%s)", h.Nombre, destinos[h.ID])
+		}
+		filas, err := leerHojaXLSX(f, compartidas)
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: hoja %s: %w", h.Nombre, err)
+		}
+		hojas = append(hojas, Hoja{Nombre: h.Nombre, Filas: filas})
+	}
+	return hojas, nil
+}
+
+// leerXMLZip Decodifica el archivo nombre del libro en v.
+func leerXMLZip(archivos map[string]*zip.File, nombre string, v interface{}) error {
+	f, ok := archivos[nombre]
+	if !ok {
+		return fmt.Errorf("xlsx: falta %s", nombre)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// leerCadenasCompartidas Lee la tabla de cadenas compartidas. Cada cadena es la concatenación de sus fragmentos de texto, sin la guía fonética.
+func leerCadenasCompartidas(f *zip.File) ([]string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	d := xml.NewDecoder(r)
+	var cadenas []string
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return cadenas, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		inicio, ok := t.(xml.StartElement)
+		if !ok || inicio.Name.Local != "si" {
+			continue
+		}
+		var si struct {
+			T    string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		}
+		if err := d.DecodeElement(&si, &inicio); err != nil {
+			return nil, err
+		}
+		cadenas = append(cadenas, si.T+strings.Join(si.Runs, ""))
+	}
+}
+
+// leerHojaXLSX Lee las filas de una hoja. Las celdas vacías se representan con cadenas vacías.
+func leerHojaXLSX(f *zip.File, compartidas []string) ([][]string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	d := xml.NewDecoder(r)
+	var filas [][]string
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return filas, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		inicio, ok := t.(xml.StartElement)
+		if !ok || inicio.Name.Local != "row" {
+			continue
+		}
+		var fila struct {
+			Numero int `xml:"r,attr"`
+			Celdas []struct {
+				Referencia string   `xml:"r,attr"`
+				Tipo       string   `xml:"t,attr"`
+				Valor      string   `xml:"v"`
+				Texto      string   `xml:"is>t"`
+				Runs       []string `xml:"is>r>t"`
+			} `xml:"c"`
+		}
+		if err := d.DecodeElement(&fila, &inicio); err != nil {
+			return nil, err
+		}
+		numero := fila.Numero
+		if numero == 0 {
+			numero = len(filas) + 1
+		}
+		for len(filas) < numero {
+			filas = append(filas, nil)
+		}
+		var valores []string
+		for i, c := range fila.Celdas {
+			columna := i
+			if c.Referencia != "" {
+				columna = indiceColumna(c.Referencia)
+			}
+			valor := c.Valor
+			switch c.Tipo {
+			case "s":
+				n, err := strconv.Atoi(c.Valor)
+				if err != nil || n < 0 || n >= len(compartidas) {
+					return nil, fmt.Errorf("celda %s: índice de cadena compartida inválido %q", c.Referencia, c.Valor)
+				}
+				valor = compartidas[n]
+			case "inlineStr":
+				valor = c.Texto + strings.Join(c.Runs, "")
+			}
+			for len(valores) <= columna {
+				valores = append(valores, "")
+			}
+			valores[columna] = valor
+		}
+		filas[numero-1] = valores
+	}
+}
+
+// indiceColumna Devuelve la columna, contando desde cero, de una referencia como AB12.
+func indiceColumna(referencia string) int {
+	n := 0
+	for _, r := range referencia {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
@@ -0,0 +1,124 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sync"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Addendas
+*
+*
+****************************************************************************************************************************************/
+
+var (
+	addendasMu          sync.RWMutex
+	addendasRegistradas = map[xml.Name]func() interface{}{}
+)
+
+// RegistrarAddenda Registra el tipo con que se decodifica el nodo name (espacio de nombres y nombre local) cuando aparece como hijo de cfdi:Addenda. nuevo debe devolver un puntero a un valor vacío del tipo; las etiquetas xml del tipo deben usar nombres locales, sin prefijo.
+func RegistrarAddenda(name xml.Name, nuevo func() interface{}) {
+	addendasMu.Lock()
+	defer addendasMu.Unlock()
+	addendasRegistradas[name] = nuevo
+}
+
+// addendaRegistrada Devuelve la función que crea el tipo registrado para name.
+func addendaRegistrada(name xml.Name) (func() interface{}, bool) {
+	addendasMu.RLock()
+	defer addendasMu.RUnlock()
+	nuevo, ok := addendasRegistradas[name]
+	return nuevo, ok
+}
+
+// MarshalXML Escribe el contenido crudo de la addenda sin cambios o, si está vacío, cada uno de sus elementos tipados.
+func (a CFDIAddenda) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "cfdi:Addenda"}
+	if len(a.Contenido) > 0 {
+		start.Attr = append(start.Attr, a.Namespaces...)
+		crudo := struct {
+			Contenido []byte `xml:",innerxml"`
+		}{a.Contenido}
+		return e.EncodeElement(crudo, start)
+	}
+	tipado := struct {
+		Elementos []interface{}
+	}{a.Elementos}
+	return e.EncodeElement(tipado, start)
+}
+
+// UnmarshalXML Omite el contenido del nodo. Unmarshal lo llena después a partir del documento original, para conservarlo byte por byte.
+func (a *CFDIAddenda) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	a.XMLName = start.Name
+	return d.Skip()
+}
+
+// leerAddenda Localiza el nodo cfdi:Addenda hijo del nodo raíz de data y devuelve su contenido original junto con los elementos de tipos registrados. Devuelve nil si el documento no tiene addenda.
+func leerAddenda(data []byte) (*CFDIAddenda, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var addenda *CFDIAddenda
+	var namespacesRaiz []xml.Attr
+	inicio := int64(-1)
+	profundidad := 0
+	for {
+		antes := d.InputOffset()
+		t, err := d.Token()
+		if err == io.EOF {
+			return addenda, nil
+		}
+		if err != nil {
+			return nil, &ErrorLectura{Ruta: "/cfdi:Comprobante/cfdi:Addenda", Err: err}
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			profundidad++
+			switch {
+			case profundidad == 1:
+				namespacesRaiz = declaracionesHeredables(nil, v.Attr)
+			case profundidad == 2 && nombreCanonico(v.Name).Local == "cfdi:Addenda":
+				addenda = &CFDIAddenda{
+					XMLName:    xml.Name{Local: "cfdi:Addenda"},
+					Namespaces: declaracionesHeredables(append([]xml.Attr(nil), namespacesRaiz...), v.Attr),
+				}
+				inicio = d.InputOffset()
+			case profundidad == 3 && inicio >= 0:
+				nuevo, ok := addendaRegistrada(v.Name)
+				if !ok {
+					continue
+				}
+				elemento := nuevo()
+				linea, _ := d.InputPos()
+				if err := d.DecodeElement(elemento, &v); err != nil {
+					return nil, &ErrorLectura{Ruta: "/cfdi:Comprobante/cfdi:Addenda/" + v.Name.Local, Linea: linea, Err: err}
+				}
+				addenda.Elementos = append(addenda.Elementos, elemento)
+				profundidad--
+			}
+		case xml.EndElement:
+			if profundidad == 2 && inicio >= 0 {
+				addenda.Contenido = append([]byte(nil), data[inicio:antes]...)
+				inicio = -1
+			}
+			profundidad--
+		}
+	}
+}
+
+// declaracionesHeredables Agrega a namespaces las declaraciones de espacio de nombres de attr, salvo las que el Encoder ya escribe en el nodo raíz, en la forma en que se escriben (xmlns:prefijo o xmlns).
+func declaracionesHeredables(namespaces []xml.Attr, attr []xml.Attr) []xml.Attr {
+	for _, a := range attr {
+		switch {
+		case a.Name.Space == "xmlns" && a.Name.Local == "cfdi" && a.Value == NamespaceCFDI:
+		case a.Name.Space == "xmlns" && a.Name.Local == "xsi" && a.Value == NamespaceXSI:
+		case a.Name.Space == "xmlns":
+			namespaces = append(namespaces, xml.Attr{Name: xml.Name{Local: "xmlns:" + a.Name.Local}, Value: a.Value})
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			namespaces = append(namespaces, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: a.Value})
+		}
+	}
+	return namespaces
+}
@@ -0,0 +1,154 @@
+package xmlstructures
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// addendaCruda Contenido de una addenda con comentarios, CDATA, comillas simples, referencias de caracteres, espacios significativos y un prefijo declarado en el nodo raíz.
+const addendaCruda = `
+		<!-- Datos del portal del cliente -->
+		<ped:Pedido ped:Numero='PO-0042' Planta="Tijuana &amp; Mexicali" >
+			<ped:Nota><![CDATA[entregar en "andén" <3>]]></ped:Nota>
+			<ped:Referencia>  &#x41;BC  </ped:Referencia>
+		</ped:Pedido>
+		<Proveedor xmlns="urn:proveedor" Numero="98765"/>
+	`
+
+// pedidoPrueba Tipo de addenda registrado en las pruebas.
+type pedidoPrueba struct {
+	XMLName  xml.Name `xml:"urn:prueba:pedidos Pedido"`
+	Numero   string   `xml:"Numero,attr"`
+	Planta   string   `xml:"Planta,attr,omitempty"`
+	Partidas []struct {
+		Linea    int    `xml:"Linea,attr"`
+		Material string `xml:"Material,attr"`
+	} `xml:"Partida"`
+}
+
+func init() {
+	RegistrarAddenda(xml.Name{Space: "urn:prueba:pedidos", Local: "Pedido"}, func() interface{} { return new(pedidoPrueba) })
+}
+
+// conAddenda Inserta una cfdi:Addenda con contenido antes del cierre del nodo raíz de datos.
+func conAddenda(t *testing.T, datos []byte, contenido string) []byte {
+	t.Helper()
+	texto := string(datos)
+	cierre := strings.LastIndex(texto, "</cfdi:Comprobante>")
+	if cierre < 0 {
+		t.Fatal("el documento no cierra cfdi:Comprobante")
+	}
+	return []byte(texto[:cierre] + "<cfdi:Addenda>" + contenido + "</cfdi:Addenda>" + texto[cierre:])
+}
+
+func TestAddendaCruda(t *testing.T) {
+	datos, err := Marshal(comprobantePrueba(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	datos = []byte(strings.Replace(string(datos), "<cfdi:Comprobante ", `<cfdi:Comprobante xmlns:ped="urn:prueba:portal" `, 1))
+	datos = conAddenda(t, datos, addendaCruda)
+	var c Comprobante
+	if err := Unmarshal(datos, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Addenda == nil || string(c.Addenda.Contenido) != addendaCruda {
+		t.Fatalf("el contenido leído difiere del original: %q", c.Addenda)
+	}
+	if len(c.Addenda.Elementos) != 0 {
+		t.Errorf("se decodificaron elementos no registrados: %+v", c.Addenda.Elementos)
+	}
+	salida, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esperado := `<cfdi:Addenda xmlns:ped="urn:prueba:portal">` + addendaCruda + `</cfdi:Addenda>`; !strings.Contains(string(salida), esperado) {
+		t.Errorf("la addenda no se escribió byte por byte:\n%s", salida)
+	}
+	var otra Comprobante
+	if err := Unmarshal(salida, &otra); err != nil {
+		t.Fatal(err)
+	}
+	if string(otra.Addenda.Contenido) != addendaCruda {
+		t.Errorf("el contenido cambió al leerlo de nuevo: %q", otra.Addenda.Contenido)
+	}
+}
+
+func TestAddendaRegistrada(t *testing.T) {
+	datos, err := Marshal(comprobantePrueba(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	datos = conAddenda(t, datos, `<p:Pedido xmlns:p="urn:prueba:pedidos" Numero="PO-7"><p:Partida Linea="1" Material="TORNILLO"/><p:Partida Linea="2" Material="TUERCA"/></p:Pedido><Otro xmlns="urn:otro"/>`)
+	var c Comprobante
+	if err := Unmarshal(datos, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Addenda == nil || len(c.Addenda.Elementos) != 1 {
+		t.Fatalf("se esperaba un elemento tipado, se leyó %+v", c.Addenda)
+	}
+	pedido, ok := c.Addenda.Elementos[0].(*pedidoPrueba)
+	if !ok || pedido.Numero != "PO-7" || len(pedido.Partidas) != 2 || pedido.Partidas[1].Material != "TUERCA" {
+		t.Fatalf("pedido leído %+v", c.Addenda.Elementos[0])
+	}
+
+	// Sin Contenido, el Encoder escribe los elementos tipados.
+	pedido.Planta = "Tijuana"
+	c.Addenda = &CFDIAddenda{Elementos: []interface{}{pedido}}
+	salida, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var otra Comprobante
+	if err := Unmarshal(salida, &otra); err != nil {
+		t.Fatal(err)
+	}
+	if otra.Addenda == nil || len(otra.Addenda.Elementos) != 1 {
+		t.Fatalf("no se leyó el elemento escrito:\n%s", salida)
+	}
+	if leido := otra.Addenda.Elementos[0].(*pedidoPrueba); leido.Numero != "PO-7" || leido.Planta != "Tijuana" || len(leido.Partidas) != 2 {
+		t.Errorf("pedido escrito y leído %+v", leido)
+	}
+}
+
+func TestAddendaFueraDelSello(t *testing.T) {
+	sinAddenda := firmadoPrueba(t)
+	cadena, err := sinAddenda.CadenaOriginal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	datos, err := Marshal(sinAddenda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Comprobante
+	if err := Unmarshal(conAddenda(t, datos, `<Portal xmlns="urn:portal" Total="1.00">||3.3|cadena|</Portal>`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Addenda == nil {
+		t.Fatal("no se leyó la addenda")
+	}
+	if otra, err := c.CadenaOriginal(); err != nil || otra != cadena {
+		t.Errorf("la addenda cambió la cadena original (err %v):\n  %s\n  %s", err, cadena, otra)
+	}
+	r, err := VerificarSello(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.SelloValido {
+		t.Errorf("la addenda invalidó el sello: %v", r.Problemas())
+	}
+	csd := csdPrueba(t, "des3.key")
+	sellado := c
+	if err := csd.Sellar(&sellado); err != nil {
+		t.Fatal(err)
+	}
+	sinAddenda.Sello = ""
+	if err := csd.Sellar(&sinAddenda); err != nil {
+		t.Fatal(err)
+	}
+	if sellado.Sello != sinAddenda.Sello {
+		t.Error("Sellar produjo un sello distinto con y sin addenda")
+	}
+}
@@ -0,0 +1,258 @@
+package xmlstructures
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Facturación con aplicación de anticipo
+*
+*
+****************************************************************************************************************************************/
+
+// Datos con que se expiden los comprobantes de un anticipo según el procedimiento de aplicación de anticipo con CFDI de egreso de la guía de llenado del SAT.
+const (
+	ClaveProdServAnticipo         = "84111506"                     // Servicios de facturación, para el anticipo y para su aplicación.
+	ClaveUnidadAnticipo           = "ACT"                          // Actividad.
+	DescripcionAnticipo           = "Anticipo del bien o servicio" // Descripción del concepto del CFDI del anticipo.
+	DescripcionAplicacionAnticipo = "Aplicación de anticipo"       // Descripción del concepto del CFDI de egreso.
+	UsoCFDIAnticipo               = "P01"                          // Por definir: el uso se conoce hasta la factura de la operación.
+	TipoRelacionAnticipo          = "07"                           // CFDI por aplicación de anticipo.
+	FormaPagoAplicacionAnticipo   = "30"                           // Aplicación de anticipos.
+)
+
+// Errores del procedimiento de anticipos.
+var (
+	ErrNoEsAnticipo        = errors.New("el comprobante no es el CFDI de un anticipo")
+	ErrFacturaSinAnticipo  = errors.New("la factura no relaciona el anticipo con el tipo de relación 07")
+	ErrRelacionDistinta    = errors.New("la factura ya relaciona otros comprobantes con un tipo de relación distinto de 07")
+	ErrOperacionDistinta   = errors.New("la factura no tiene el mismo emisor, receptor y moneda que el anticipo")
+	ErrSaldoAnticipo       = errors.New("el importe excede el saldo del anticipo o el valor de la factura")
+	ErrEgresoSinAplicacion = errors.New("el egreso no es la aplicación de anticipo de la factura")
+	ErrFormaPagoAnticipo   = errors.New("el CFDI del anticipo debe indicar la forma en que se recibió, distinta de 99")
+)
+
+// Anticipo Seguimiento de un anticipo recibido y de su saldo por aplicar. Se crea con NuevoAnticipo a partir del CFDI del anticipo ya timbrado y puede guardarse tal cual junto con los comprobantes.
+//
+// El procedimiento completo es:
+//  1. ComprobanteAnticipo genera el CFDI de ingreso por el anticipo, que se sella y timbra.
+//  2. NuevoAnticipo inicia el seguimiento con el CFDI timbrado.
+//  3. RelacionarFactura relaciona con el anticipo la factura por el valor total de la operación, que se sella y timbra.
+//  4. Aplicacion genera el CFDI de egreso que aplica el anticipo a la factura, que se sella y timbra.
+//  5. Registrar descuenta el egreso timbrado del saldo.
+//
+// Los pasos 3 a 5 se repiten mientras quede saldo.
+type Anticipo struct {
+	UUID         string               // Folio fiscal del CFDI del anticipo.
+	RFCEmisor    string               // RFC del emisor del anticipo.
+	RFCReceptor  string               // RFC del cliente que entregó el anticipo.
+	Moneda       string               // Moneda del anticipo.
+	Valor        Decimal              // Valor del anticipo antes de impuestos.
+	Impuestos    *CFDIImpuestosInner  // Impuestos del anticipo, sin bases ni importes; se repiten en cada aplicación.
+	Aplicaciones []AplicacionAnticipo // Aplicaciones registradas, en orden.
+}
+
+// AplicacionAnticipo Aplicación de una parte del anticipo a una factura.
+type AplicacionAnticipo struct {
+	UUIDFactura string  // Folio fiscal de la factura por el valor total de la operación.
+	UUIDEgreso  string  // Folio fiscal del CFDI de egreso que aplica el anticipo.
+	Importe     Decimal // Valor aplicado antes de impuestos.
+}
+
+// ComprobanteAnticipo Devuelve el borrador del CFDI de ingreso por un anticipo de valor antes de impuestos, con los impuestos dados (p.ej. el traslado de IVA). De plantilla se toman Version, Emisor, Receptor, Moneda, TipoCambio y LugarExpedicion; formaPago es la clave de c_FormaPago con que se recibió el anticipo, que no puede ser 99 porque el anticipo ya está pagado. El UsoCFDI es UsoCFDIAnticipo, el MetodoPago PUE y los importes se calculan con CalcularTotales. Queda sin Fecha ni sello.
+func ComprobanteAnticipo(plantilla Comprobante, formaPago string, valor Decimal, impuestos *CFDIImpuestosInner) (Comprobante, error) {
+	if formaPago == "" || formaPago == "99" {
+		return Comprobante{}, ErrFormaPagoAnticipo
+	}
+	c := Comprobante{
+		Version:           plantilla.Version,
+		FormaPago:         formaPago,
+		Moneda:            plantilla.Moneda,
+		TipoCambio:        plantilla.TipoCambio,
+		TipoDeComprobante: "I",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   plantilla.LugarExpedicion,
+		Emisor:            plantilla.Emisor,
+		Receptor:          plantilla.Receptor,
+	}
+	c.Receptor.UsoCFDI = UsoCFDIAnticipo
+	c.Conceptos.Conceptos = []CFDIConcepto{conceptoAnticipo(DescripcionAnticipo, valor, impuestos)}
+	if err := c.CalcularTotales(); err != nil {
+		return Comprobante{}, err
+	}
+	return c, nil
+}
+
+// NuevoAnticipo Inicia el seguimiento del anticipo con su CFDI timbrado. Devuelve ErrNoEsAnticipo si c no es un comprobante de ingreso con un solo concepto ClaveProdServAnticipo, o ErrSinTimbre si no ha sido timbrado.
+func NuevoAnticipo(c Comprobante) (*Anticipo, error) {
+	if c.TipoDeComprobante != "I" || len(c.Conceptos.Conceptos) != 1 || c.Conceptos.Conceptos[0].ClaveProdServ != ClaveProdServAnticipo {
+		return nil, ErrNoEsAnticipo
+	}
+	t := c.Timbre()
+	if t == nil {
+		return nil, ErrSinTimbre
+	}
+	concepto := c.Conceptos.Conceptos[0]
+	return &Anticipo{
+		UUID:        t.UUID,
+		RFCEmisor:   c.Emisor.RFC,
+		RFCReceptor: c.Receptor.RFC,
+		Moneda:      c.Moneda,
+		Valor:       importeNeto(concepto),
+		Impuestos:   impuestosSinImportes(concepto.Impuestos),
+	}, nil
+}
+
+// Aplicado Devuelve el valor del anticipo ya aplicado, antes de impuestos.
+func (a *Anticipo) Aplicado() Decimal {
+	suma := NewDecimal(0, a.Valor.Escala)
+	for _, ap := range a.Aplicaciones {
+		suma = suma.Add(ap.Importe)
+	}
+	return suma
+}
+
+// AplicadoFactura Devuelve el valor del anticipo ya aplicado a la factura uuidFactura, antes de impuestos.
+func (a *Anticipo) AplicadoFactura(uuidFactura string) Decimal {
+	suma := NewDecimal(0, a.Valor.Escala)
+	for _, ap := range a.Aplicaciones {
+		if strings.EqualFold(ap.UUIDFactura, uuidFactura) {
+			suma = suma.Add(ap.Importe)
+		}
+	}
+	return suma
+}
+
+// Saldo Devuelve el valor del anticipo que falta por aplicar, antes de impuestos.
+func (a *Anticipo) Saldo() Decimal {
+	return a.Valor.Sub(a.Aplicado())
+}
+
+// RelacionarFactura Relaciona el anticipo con la factura por el valor total de la operación, antes de sellarla. Una factura puede relacionar varios anticipos, pero sólo con TipoRelacionAnticipo.
+func (a *Anticipo) RelacionarFactura(factura *Comprobante) error {
+	if err := a.mismaOperacion(*factura); err != nil {
+		return err
+	}
+	switch {
+	case factura.Relacionados == nil || len(factura.Relacionados.CfdiRelacionado) == 0:
+		factura.Relacionados = &CFDIRelacionados{TipoRelacion: TipoRelacionAnticipo}
+	case factura.Relacionados.TipoRelacion != TipoRelacionAnticipo:
+		return ErrRelacionDistinta
+	case factura.Relaciona(TipoRelacionAnticipo, a.UUID):
+		return nil
+	}
+	factura.Relacionados.CfdiRelacionado = append(factura.Relacionados.CfdiRelacionado, CFDIRelacionado{UUID: a.UUID})
+	return nil
+}
+
+// Aplicacion Devuelve el borrador del CFDI de egreso que aplica importe, antes de impuestos, del anticipo a la factura timbrada. El egreso relaciona la factura con TipoRelacionAnticipo, repite los impuestos del anticipo, usa FormaPagoAplicacionAnticipo, MetodoPago PUE y UsoCFDINotaCredito y copia emisor, receptor, moneda y lugar de expedición de la factura; queda sin Fecha ni sello.
+//
+// importe no puede exceder el saldo del anticipo ni, sumado a lo ya aplicado de este anticipo a la misma factura, el valor de la factura antes de impuestos. Las aplicaciones de otros anticipos a la factura no se conocen aquí y deben revisarse aparte. El saldo no cambia hasta que el egreso timbrado se registra con Registrar.
+func (a *Anticipo) Aplicacion(factura Comprobante, importe Decimal) (Comprobante, error) {
+	if err := a.revisarFactura(factura); err != nil {
+		return Comprobante{}, err
+	}
+	if importe.Sign() <= 0 {
+		return Comprobante{}, fmt.Errorf("%w: importe %s", ErrSaldoAnticipo, importe)
+	}
+	if err := a.revisarImporte(factura, importe); err != nil {
+		return Comprobante{}, err
+	}
+	egreso := Comprobante{
+		Version:           factura.Version,
+		FormaPago:         FormaPagoAplicacionAnticipo,
+		Moneda:            factura.Moneda,
+		TipoCambio:        factura.TipoCambio,
+		TipoDeComprobante: "E",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   factura.LugarExpedicion,
+		Relacionados:      &CFDIRelacionados{TipoRelacion: TipoRelacionAnticipo, CfdiRelacionado: []CFDIRelacionado{{UUID: factura.Timbre().UUID}}},
+		Emisor:            factura.Emisor,
+		Receptor:          factura.Receptor,
+	}
+	egreso.Receptor.UsoCFDI = UsoCFDINotaCredito
+	egreso.Conceptos.Conceptos = []CFDIConcepto{conceptoAnticipo(DescripcionAplicacionAnticipo, importe, a.Impuestos)}
+	if err := egreso.CalcularTotales(); err != nil {
+		return Comprobante{}, err
+	}
+	return egreso, nil
+}
+
+// Registrar Descuenta del saldo el CFDI de egreso timbrado que aplica el anticipo a la factura, con los mismos límites que Aplicacion. Si el egreso ya estaba registrado no hace nada.
+func (a *Anticipo) Registrar(factura, egreso Comprobante) error {
+	if err := a.revisarFactura(factura); err != nil {
+		return err
+	}
+	tf, te := factura.Timbre(), egreso.Timbre()
+	if te == nil {
+		return ErrSinTimbre
+	}
+	if egreso.TipoDeComprobante != "E" || !egreso.Relaciona(TipoRelacionAnticipo, tf.UUID) || len(egreso.Conceptos.Conceptos) != 1 || egreso.Conceptos.Conceptos[0].ClaveProdServ != ClaveProdServAnticipo {
+		return ErrEgresoSinAplicacion
+	}
+	for _, ap := range a.Aplicaciones {
+		if strings.EqualFold(ap.UUIDEgreso, te.UUID) {
+			return nil
+		}
+	}
+	importe := importeNeto(egreso.Conceptos.Conceptos[0])
+	if err := a.revisarImporte(factura, importe); err != nil {
+		return err
+	}
+	a.Aplicaciones = append(a.Aplicaciones, AplicacionAnticipo{UUIDFactura: tf.UUID, UUIDEgreso: te.UUID, Importe: importe})
+	return nil
+}
+
+// revisarFactura Revisa que la factura esté timbrada, corresponda a la misma operación que el anticipo y lo relacione.
+func (a *Anticipo) revisarFactura(factura Comprobante) error {
+	if factura.Timbre() == nil {
+		return ErrSinTimbre
+	}
+	if err := a.mismaOperacion(factura); err != nil {
+		return err
+	}
+	if !factura.Relaciona(TipoRelacionAnticipo, a.UUID) {
+		return ErrFacturaSinAnticipo
+	}
+	return nil
+}
+
+// revisarImporte Revisa que importe no exceda el saldo del anticipo ni, sumado a lo ya aplicado a la factura, el valor de la factura antes de impuestos.
+func (a *Anticipo) revisarImporte(factura Comprobante, importe Decimal) error {
+	valorFactura := factura.SubTotal
+	if factura.Descuento != nil {
+		valorFactura = valorFactura.Sub(*factura.Descuento)
+	}
+	aplicado := a.AplicadoFactura(factura.Timbre().UUID).Add(importe)
+	if importe.Cmp(a.Saldo()) > 0 || aplicado.Cmp(valorFactura) > 0 {
+		return fmt.Errorf("%w: importe %s, saldo %s, aplicado a la factura %s de %s", ErrSaldoAnticipo, importe, a.Saldo(), aplicado, valorFactura)
+	}
+	return nil
+}
+
+// mismaOperacion Revisa que la factura sea de ingreso y tenga el emisor, el receptor y la moneda del anticipo.
+func (a *Anticipo) mismaOperacion(factura Comprobante) error {
+	if factura.TipoDeComprobante != "I" {
+		return ErrNoEsIngreso
+	}
+	if !strings.EqualFold(factura.Emisor.RFC, a.RFCEmisor) || !strings.EqualFold(factura.Receptor.RFC, a.RFCReceptor) || factura.Moneda != a.Moneda {
+		return ErrOperacionDistinta
+	}
+	return nil
+}
+
+// conceptoAnticipo Devuelve el concepto de servicios de facturación por valor con los impuestos dados.
+func conceptoAnticipo(descripcion string, valor Decimal, impuestos *CFDIImpuestosInner) CFDIConcepto {
+	return CFDIConcepto{
+		ClaveProdServ: ClaveProdServAnticipo,
+		Cantidad:      NewDecimal(1, 0),
+		ClaveUnidad:   ClaveUnidadAnticipo,
+		Descripcion:   descripcion,
+		ValorUnitario: valor,
+		Impuestos:     impuestosSinImportes(impuestos),
+	}
+}
@@ -0,0 +1,82 @@
+package xmlstructures
+
+import (
+	"errors"
+	"testing"
+)
+
+// timbrarPrueba Agrega a c un Timbre Fiscal Digital con uuid, en lugar de timbrarlo con un PAC.
+func timbrarPrueba(c *Comprobante, uuid string) {
+	c.AgregarComplemento(&CFDITimbre{Version: "1.1", UUID: uuid})
+}
+
+func TestComprobanteAnticipoFormaPago(t *testing.T) {
+	plantilla := facturaTimbrada(t)
+	for _, formaPago := range []string{"", "99"} {
+		if _, err := ComprobanteAnticipo(plantilla, formaPago, NewDecimal(1000, 0), nil); err != ErrFormaPagoAnticipo {
+			t.Errorf("FormaPago %q: %v", formaPago, err)
+		}
+	}
+	c, err := ComprobanteAnticipo(plantilla, "04", NewDecimal(1000, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FormaPago != "04" || c.Receptor.UsoCFDI != UsoCFDIAnticipo || c.MetodoPago != "PUE" {
+		t.Errorf("anticipo FormaPago=%s UsoCFDI=%s MetodoPago=%s", c.FormaPago, c.Receptor.UsoCFDI, c.MetodoPago)
+	}
+}
+
+func TestAplicacionAnticipo(t *testing.T) {
+	// La factura de testdata/ingreso.xml vale 1900.00 antes de impuestos; el anticipo de 3000.00 alcanza para más.
+	factura := facturaTimbrada(t)
+	anticipo, err := ComprobanteAnticipo(factura, "03", NewDecimal(300000, 2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timbrarPrueba(&anticipo, "11111111-1111-4111-8111-111111111111")
+	a, err := NuevoAnticipo(anticipo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factura.Relacionados = nil
+	if err := a.RelacionarFactura(&factura); err != nil {
+		t.Fatal(err)
+	}
+
+	egreso, err := a.Aplicacion(factura, NewDecimal(120000, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if egreso.Receptor.UsoCFDI != UsoCFDINotaCredito || egreso.FormaPago != FormaPagoAplicacionAnticipo || !egreso.Relaciona(TipoRelacionAnticipo, factura.Timbre().UUID) {
+		t.Errorf("egreso UsoCFDI=%s FormaPago=%s Relacionados=%+v", egreso.Receptor.UsoCFDI, egreso.FormaPago, egreso.Relacionados)
+	}
+	timbrarPrueba(&egreso, "22222222-2222-4222-8222-222222222222")
+	if err := a.Registrar(factura, egreso); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1200 + 800 excede el valor de la factura aunque el anticipo aún tenga saldo.
+	if _, err := a.Aplicacion(factura, NewDecimal(80000, 2)); !errors.Is(err, ErrSaldoAnticipo) {
+		t.Errorf("se esperaba ErrSaldoAnticipo, se obtuvo %v", err)
+	}
+	segundo, err := a.Aplicacion(factura, NewDecimal(70000, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timbrarPrueba(&segundo, "33333333-3333-4333-8333-333333333333")
+	otro := segundo
+	otro.Complemento = nil
+	timbrarPrueba(&otro, "44444444-4444-4444-8444-444444444444")
+	if err := a.Registrar(factura, segundo); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Registrar(factura, otro); !errors.Is(err, ErrSaldoAnticipo) {
+		t.Errorf("registrar un egreso que excede la factura: %v", err)
+	}
+	if aplicado := a.AplicadoFactura(factura.Timbre().UUID); aplicado.Cmp(NewDecimal(190000, 2)) != 0 {
+		t.Errorf("aplicado a la factura %s", aplicado)
+	}
+	if saldo := a.Saldo(); saldo.Cmp(NewDecimal(110000, 2)) != 0 {
+		t.Errorf("saldo %s", saldo)
+	}
+}
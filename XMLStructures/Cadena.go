@@ -0,0 +1,240 @@
+package xmlstructures
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Cadena original
+*
+*
+****************************************************************************************************************************************/
+
+// ErrComplementoSinCadena Indica que el comprobante incluye un complemento, distinto del Timbre Fiscal Digital, que no implementa ComplementoCadena ni tiene plantilla en plantillasCadena, por lo que no es posible saber qué aporta a la cadena original.
+var ErrComplementoSinCadena = errors.New("el complemento no implementa ComplementoCadena")
+
+// ErrorCadena Error producido al generar la cadena original. Indica el nodo que no pudo incluirse.
+type ErrorCadena struct {
+	Nodo string // Ruta del nodo, p.ej. /cfdi:Comprobante/cfdi:Complemento/pago10:Pagos.
+	Err  error  // Error original.
+}
+
+func (e *ErrorCadena) Error() string {
+	return fmt.Sprintf("xmlstructures: %s: %v", e.Nodo, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorCadena) Unwrap() error { return e.Err }
+
+// ComplementoCadena Interfaz que implementan los complementos que forman parte de la cadena original. EscribirCadena agrega los atributos del complemento con Requerido y Opcional, en el orden que define la plantilla XSLT que el SAT publica para ese complemento.
+type ComplementoCadena interface {
+	EscribirCadena(c *Cadena)
+}
+
+// Cadena Acumula los campos de una cadena original. Reproduce las plantillas Requerido y Opcional de los XSLT del SAT: cada campo se antepone con | y su valor se normaliza como lo hace normalize-space de XPath.
+type Cadena struct {
+	b strings.Builder
+}
+
+// Requerido Agrega un atributo requerido. Se escribe aunque esté vacío.
+func (c *Cadena) Requerido(valor string) {
+	c.b.WriteByte('|')
+	c.b.WriteString(normalizarEspacios(valor))
+}
+
+// Opcional Agrega un atributo opcional. Un valor vacío equivale a un atributo ausente, igual que en la serialización del modelo, y no se escribe.
+func (c *Cadena) Opcional(valor string) {
+	if valor != "" {
+		c.Requerido(valor)
+	}
+}
+
+// RequeridoDecimal Agrega un importe requerido con los decimales con que se escribe en el XML.
+func (c *Cadena) RequeridoDecimal(d Decimal) {
+	c.Requerido(d.String())
+}
+
+// OpcionalDecimal Agrega un importe opcional; nil equivale a un atributo ausente.
+func (c *Cadena) OpcionalDecimal(d *Decimal) {
+	if d != nil {
+		c.Requerido(d.String())
+	}
+}
+
+// String Devuelve la cadena original completa, delimitada por || al inicio y al final.
+func (c *Cadena) String() string {
+	return "|" + c.b.String() + "||"
+}
+
+// normalizarEspacios Elimina los espacios en blanco XML (espacio, tabulador, retorno de carro y salto de línea) al inicio y al final de s y reduce cada secuencia interior a un solo espacio, como normalize-space de XPath. Otros espacios Unicode, como el espacio duro, se conservan.
+func normalizarEspacios(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	pendiente := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', '\r', '\n':
+			pendiente = b.Len() > 0
+		default:
+			if pendiente {
+				b.WriteByte(' ')
+				pendiente = false
+			}
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// CadenaOriginal Devuelve la cadena original del comprobante según cadenaoriginal_3_3.xslt del SAT. Los importes se toman con los decimales con que Marshal los escribe, de modo que la cadena corresponde al XML serializado. No incluye Sello, Certificado, el Timbre Fiscal Digital ni la Addenda. Los complementos que no implementan ComplementoCadena, incluidos los leídos como *ComplementoDesconocido, se agregan con la plantilla de su espacio de nombres en plantillasCadena (implocal, nomina12, cce11, donat, divisas, leyendasFisc, iedu e ine); si no la tienen devuelve un *ErrorCadena.
+func (c Comprobante) CadenaOriginal() (string, error) {
+	return cadenaOriginal(ajustarDecimales(omitirNodosVacios(valoresPrefijados(c))))
+}
+
+// cadenaOriginal Genera la cadena original con los valores de c tal como están, sin ajustar decimales ni omitir nodos vacíos. Es la que corresponde a un comprobante leído de un documento, cuyos valores ya son los del XML sellado.
+func cadenaOriginal(c Comprobante) (string, error) {
+	cad := &Cadena{}
+	cad.Requerido(c.Version)
+	cad.Opcional(c.Serie)
+	cad.Opcional(c.Folio)
+	cad.Requerido(c.Fecha)
+	cad.Opcional(c.FormaPago)
+	cad.Requerido(c.NoCertificado)
+	cad.Opcional(c.CondicionesDePago)
+	cad.RequeridoDecimal(c.SubTotal)
+	cad.OpcionalDecimal(c.Descuento)
+	cad.Requerido(c.Moneda)
+	cad.OpcionalDecimal(c.TipoCambio)
+	cad.RequeridoDecimal(c.Total)
+	cad.Requerido(c.TipoDeComprobante)
+	cad.Opcional(c.MetodoPago)
+	cad.Requerido(c.LugarExpedicion)
+	cad.Opcional(c.Confirmacion)
+
+	if c.Relacionados != nil {
+		cad.Requerido(c.Relacionados.TipoRelacion)
+		for _, r := range c.Relacionados.CfdiRelacionado {
+			cad.Requerido(r.UUID)
+		}
+	}
+
+	cad.Requerido(c.Emisor.RFC)
+	cad.Opcional(c.Emisor.Nombre)
+	cad.Requerido(c.Emisor.RegimenFiscal)
+
+	cad.Requerido(c.Receptor.RFC)
+	cad.Opcional(c.Receptor.Nombre)
+	cad.Opcional(c.Receptor.ResidenciaFiscal)
+	cad.Opcional(c.Receptor.NumRegIDTrib)
+	cad.Requerido(c.Receptor.UsoCFDI)
+
+	for i, concepto := range c.Conceptos.Conceptos {
+		if err := cadenaConcepto(cad, concepto, fmt.Sprintf("/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[%d]", i+1)); err != nil {
+			return "", err
+		}
+	}
+
+	if c.Impuestos != nil {
+		if c.Impuestos.Retenciones != nil {
+			for _, r := range c.Impuestos.Retenciones.Retenciones {
+				cad.Requerido(r.Impuesto)
+				cad.RequeridoDecimal(r.Importe)
+			}
+		}
+		cad.OpcionalDecimal(c.Impuestos.TotalImpuestosRetenidos)
+		if c.Impuestos.Traslados != nil {
+			for _, t := range c.Impuestos.Traslados.Traslados {
+				cad.Requerido(t.Impuesto)
+				cad.Requerido(t.TipoFactor)
+				cad.RequeridoDecimal(t.TasaOCuota)
+				cad.RequeridoDecimal(t.Importe)
+			}
+		}
+		cad.OpcionalDecimal(c.Impuestos.TotalImpuestosTrasladados)
+	}
+
+	if c.Complemento != nil {
+		if err := cadenaComplementos(cad, c.Complemento.Complementos, "/cfdi:Comprobante/cfdi:Complemento"); err != nil {
+			return "", err
+		}
+	}
+	return cad.String(), nil
+}
+
+// cadenaConcepto Agrega a cad los campos del concepto y de sus nodos hijos.
+func cadenaConcepto(cad *Cadena, concepto CFDIConcepto, ruta string) error {
+	cad.Requerido(concepto.ClaveProdServ)
+	cad.Opcional(concepto.NoIdentificacion)
+	cad.RequeridoDecimal(concepto.Cantidad)
+	cad.Requerido(concepto.ClaveUnidad)
+	cad.Opcional(concepto.Unidad)
+	cad.Requerido(concepto.Descripcion)
+	cad.RequeridoDecimal(concepto.ValorUnitario)
+	cad.RequeridoDecimal(concepto.Importe)
+	cad.OpcionalDecimal(concepto.Descuento)
+	if concepto.Impuestos != nil {
+		if concepto.Impuestos.Traslados != nil {
+			for _, t := range concepto.Impuestos.Traslados.Traslados {
+				cad.RequeridoDecimal(t.Base)
+				cad.Requerido(t.Impuesto)
+				cad.Requerido(t.TipoFactor)
+				cad.OpcionalDecimal(t.TasaOCuota)
+				cad.OpcionalDecimal(t.Importe)
+			}
+		}
+		if concepto.Impuestos.Retenciones != nil {
+			for _, r := range concepto.Impuestos.Retenciones.Retenciones {
+				cad.RequeridoDecimal(r.Base)
+				cad.Requerido(r.Impuesto)
+				cad.Requerido(r.TipoFactor)
+				cad.RequeridoDecimal(r.TasaOCuota)
+				cad.RequeridoDecimal(r.Importe)
+			}
+		}
+	}
+	for _, a := range concepto.InformacionAduanera {
+		cad.Requerido(a.NumeroPedimento)
+	}
+	if concepto.CuentaPredial != nil {
+		cad.Requerido(concepto.CuentaPredial.Numero)
+	}
+	if concepto.ComplementoConcepto != nil {
+		if err := cadenaComplementos(cad, concepto.ComplementoConcepto.Complementos, ruta+"/cfdi:ComplementoConcepto"); err != nil {
+			return err
+		}
+	}
+	for _, p := range concepto.Parte {
+		cad.Requerido(p.ClaveProdServ)
+		cad.Opcional(p.NoIdentificacion)
+		cad.RequeridoDecimal(p.Cantidad)
+		cad.Opcional(p.Unidad)
+		cad.Requerido(p.Descripcion)
+		cad.OpcionalDecimal(p.ValorUnitario)
+		cad.OpcionalDecimal(p.Importe)
+		for _, a := range p.InformacionAduanera {
+			cad.Requerido(a.NumeroPedimento)
+		}
+	}
+	return nil
+}
+
+// cadenaComplementos Agrega a cad la sección de cada complemento, en orden de documento. El Timbre Fiscal Digital no forma parte de la cadena original del comprobante.
+func cadenaComplementos(cad *Cadena, complementos []interface{}, ruta string) error {
+	for _, v := range complementos {
+		switch complemento := v.(type) {
+		case CFDITimbre, *CFDITimbre:
+			continue
+		case ComplementoCadena:
+			complemento.EscribirCadena(cad)
+			continue
+		}
+		if err := cadenaSinInterfaz(cad, v, ruta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
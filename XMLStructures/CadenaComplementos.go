@@ -0,0 +1,162 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Cadena original de complementos sin tipo Go
+*
+*
+****************************************************************************************************************************************/
+
+// Espacios de nombres de los complementos cuya cadena original se genera con plantillasCadena.
+const (
+	NamespaceImpLocal         = "http://www.sat.gob.mx/implocal"
+	NamespaceNomina12         = "http://www.sat.gob.mx/nomina12"
+	NamespaceComercioExterior = "http://www.sat.gob.mx/ComercioExterior11"
+	NamespaceDonatarias       = "http://www.sat.gob.mx/donat"
+	NamespaceDivisas          = "http://www.sat.gob.mx/divisas"
+	NamespaceLeyendasFiscales = "http://www.sat.gob.mx/leyendasFiscales"
+	NamespaceInstEducativas   = "http://www.sat.gob.mx/iedu"
+	NamespaceINE              = "http://www.sat.gob.mx/ine"
+)
+
+// plantillasCadena Atributos que aporta a la cadena original cada nodo de los complementos más comunes, en el orden de la plantilla XSLT que el SAT publica para cada uno (implocal.xslt, nomina12.xslt, ComercioExterior11.xslt, donat11.xslt, Divisas.xslt, leyendasFisc.xslt, iedu.xslt e ine11.xslt). Un nombre terminado en ? se escribe con la plantilla Opcional y los demás con Requerido. Esas plantillas recorren los nodos hijos en el orden del esquema, que en un documento válido es el orden de documento. TestPlantillasCadenaXSLT compara cada lista con la hoja correspondiente de testdata/xslt.
+var plantillasCadena = map[string]map[string][]string{
+	NamespaceImpLocal: {
+		"ImpuestosLocales":   {"version", "TotaldeRetenciones", "TotaldeTraslados"},
+		"RetencionesLocales": {"ImpLocRetenido", "TasadeRetencion", "Importe"},
+		"TrasladosLocales":   {"ImpLocTrasladado", "TasadeTraslado", "Importe"},
+	},
+	NamespaceNomina12: {
+		"Nomina":                   {"Version", "TipoNomina", "FechaPago", "FechaInicialPago", "FechaFinalPago", "NumDiasPagados", "TotalPercepciones?", "TotalDeducciones?", "TotalOtrosPagos?"},
+		"Emisor":                   {"Curp?", "RegistroPatronal?", "RfcPatronOrigen?"},
+		"EntidadSNCF":              {"OrigenRecurso", "MontoRecursoPropio?"},
+		"Receptor":                 {"Curp", "NumSeguridadSocial?", "FechaInicioRelLaboral?", "Antigüedad?", "TipoContrato", "Sindicalizado?", "TipoJornada?", "TipoRegimen", "NumEmpleado", "Departamento?", "Puesto?", "RiesgoPuesto?", "PeriodicidadPago", "Banco?", "CuentaBancaria?", "SalarioBaseCotApor?", "SalarioDiarioIntegrado?", "ClaveEntFed"},
+		"SubContratacion":          {"RfcLabora", "PorcentajeTiempo"},
+		"Percepciones":             {"TotalSueldos?", "TotalSeparacionIndemnizacion?", "TotalJubilacionPensionRetiro?", "TotalGravado", "TotalExento"},
+		"Percepcion":               {"TipoPercepcion", "Clave", "Concepto", "ImporteGravado", "ImporteExento"},
+		"AccionesOTitulos":         {"ValorMercado", "PrecioAlOtorgarse"},
+		"HorasExtra":               {"Dias", "TipoHoras", "HorasExtra", "ImportePagado"},
+		"JubilacionPensionRetiro":  {"TotalUnaExhibicion?", "TotalParcialidad?", "MontoDiario?", "IngresoAcumulable", "IngresoNoAcumulable"},
+		"SeparacionIndemnizacion":  {"TotalPagado", "NumAñosServicio", "UltimoSueldoMensOrd", "IngresoAcumulable", "IngresoNoAcumulable"},
+		"Deducciones":              {"TotalOtrasDeducciones?", "TotalImpuestosRetenidos?"},
+		"Deduccion":                {"TipoDeduccion", "Clave", "Concepto", "Importe"},
+		"OtrosPagos":               {},
+		"OtroPago":                 {"TipoOtroPago", "Clave", "Concepto", "Importe"},
+		"SubsidioAlEmpleo":         {"SubsidioCausado"},
+		"CompensacionSaldosAFavor": {"SaldoAFavor", "Año", "RemanenteSalFav"},
+		"Incapacidades":            {},
+		"Incapacidad":              {"DiasIncapacidad", "TipoIncapacidad", "ImporteMonetario?"},
+	},
+	NamespaceComercioExterior: {
+		"ComercioExterior":         {"Version", "MotivoTraslado?", "TipoOperacion", "ClaveDePedimento?", "CertificadoOrigen?", "NumCertificadoOrigen?", "NumeroExportadorConfiable?", "Incoterm?", "Subdivision?", "Observaciones?", "TipoCambioUSD?", "TotalUSD?"},
+		"Emisor":                   {"Curp?"},
+		"Domicilio":                {"Calle", "NumeroExterior?", "NumeroInterior?", "Colonia?", "Localidad?", "Referencia?", "Municipio?", "Estado", "Pais", "CodigoPostal"},
+		"Propietario":              {"NumRegIdTrib", "ResidenciaFiscal"},
+		"Receptor":                 {"NumRegIdTrib?"},
+		"Destinatario":             {"NumRegIdTrib?", "Nombre?"},
+		"Mercancias":               {},
+		"Mercancia":                {"NoIdentificacion", "FraccionArancelaria?", "CantidadAduana?", "UnidadAduana?", "ValorUnitarioAduana?", "ValorDolares"},
+		"DescripcionesEspecificas": {"Marca", "Modelo?", "SubModelo?", "NumeroSerie?"},
+	},
+	NamespaceDonatarias: {
+		"Donatarias": {"version", "noAutorizacion", "fechaAutorizacion", "leyenda"},
+	},
+	NamespaceDivisas: {
+		"Divisas": {"version", "tipoOperacion"},
+	},
+	NamespaceLeyendasFiscales: {
+		"LeyendasFiscales": {"version"},
+		"Leyenda":          {"disposicionFiscal?", "norma?", "textoLeyenda"},
+	},
+	NamespaceInstEducativas: {
+		"instEducativas": {"version", "nombreAlumno", "CURP", "nivelEducativo", "autRVOE", "rfcPago?"},
+	},
+	NamespaceINE: {
+		"INE":          {"Version", "TipoProceso", "TipoComite?", "IdContabilidad?"},
+		"Entidad":      {"ClaveEntidad", "Ambito?"},
+		"Contabilidad": {"IdContabilidad"},
+	},
+}
+
+// cadenaPlantilla Agrega a cad los atributos del complemento contenido en datos, que es el nodo completo con los prefijos declarados en namespaces, según plantillasCadena. Devuelve ErrComplementoSinCadena si algún nodo no tiene plantilla.
+func cadenaPlantilla(cad *Cadena, datos []byte, namespaces []xml.Attr, ruta string) error {
+	var envoltura bytes.Buffer
+	envoltura.WriteString("<raiz")
+	for _, ns := range namespaces {
+		envoltura.WriteString(" " + ns.Name.Local + `="`)
+		xml.EscapeText(&envoltura, []byte(ns.Value))
+		envoltura.WriteString(`"`)
+	}
+	envoltura.WriteString(">")
+	envoltura.Write(datos)
+	envoltura.WriteString("</raiz>")
+	d := xml.NewDecoder(&envoltura)
+	var nodos []string
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ErrorCadena{Nodo: ruta, Err: err}
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			nodos = append(nodos, v.Name.Local)
+			if len(nodos) == 1 {
+				continue
+			}
+			atributos, ok := plantillasCadena[v.Name.Space][v.Name.Local]
+			if !ok {
+				return &ErrorCadena{Nodo: ruta + "/" + strings.Join(nodos[1:], "/"), Err: ErrComplementoSinCadena}
+			}
+			for _, nombre := range atributos {
+				opcional := strings.HasSuffix(nombre, "?")
+				valor, presente := atributoSinPrefijo(v.Attr, strings.TrimSuffix(nombre, "?"))
+				if presente || !opcional {
+					cad.Requerido(valor)
+				}
+			}
+		case xml.EndElement:
+			nodos = nodos[:len(nodos)-1]
+		}
+	}
+}
+
+// atributoSinPrefijo Devuelve el valor del atributo sin espacio de nombres llamado nombre e indica si está presente. Como en la plantilla Opcional del SAT, un atributo presente pero vacío cuenta como presente.
+func atributoSinPrefijo(attr []xml.Attr, nombre string) (string, bool) {
+	for _, a := range attr {
+		if a.Name.Space == "" && a.Name.Local == nombre {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// cadenaSinInterfaz Agrega a cad la cadena de un complemento que no implementa ComplementoCadena: el nodo original de un *ComplementoDesconocido, o la serialización de un tipo registrado con RegistrarComplemento.
+func cadenaSinInterfaz(cad *Cadena, v interface{}, ruta string) error {
+	switch d := v.(type) {
+	case *ComplementoDesconocido:
+		return cadenaPlantilla(cad, d.XML, d.Namespaces, ruta)
+	case ComplementoDesconocido:
+		return cadenaPlantilla(cad, d.XML, d.Namespaces, ruta)
+	}
+	t, ok := complementoPorValor(v)
+	if !ok {
+		return &ErrorCadena{Nodo: ruta, Err: ErrComplementoSinCadena}
+	}
+	datos, err := xml.Marshal(v)
+	if err != nil {
+		return &ErrorCadena{Nodo: ruta, Err: err}
+	}
+	ns := []xml.Attr{{Name: xml.Name{Local: "xmlns:" + t.Prefijo}, Value: t.Namespace}}
+	return cadenaPlantilla(cad, datos, ns, ruta)
+}
@@ -0,0 +1,165 @@
+package xmlstructures
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCadenaOriginalXSLT Compara CadenaOriginal de cada testdata/*.xml con testdata/*.cadena, que es la salida de testdata/xslt/cadenaoriginal_3_3.xslt procesada con libxslt:
+//
+//	xsltproc testdata/xslt/cadenaoriginal_3_3.xslt testdata/nomina.xml > testdata/nomina.cadena
+//
+// Las hojas de testdata/xslt no son las que publica el SAT: siguen su estructura (plantillas Requerido y Opcional de utilerias.xslt, un archivo por complemento) pero se escribieron a mano a partir de los esquemas, y los XML de testdata tampoco son ejemplos del SAT. La prueba sólo demuestra que CadenaOriginal coincide con esas hojas; no sustituye a compararla con las oficiales. Para hacerlo, se reemplazan los archivos de testdata/xslt por los de http://www.sat.gob.mx/sitio_internet/cfd/3/cadenaoriginal_3_3/ y de cada complemento, se cambian los xsl:include con URL absoluta por el nombre del archivo local y se regeneran los .cadena con el comando de arriba; TestPlantillasCadenaXSLT revisa entonces plantillasCadena contra ellas.
+func TestCadenaOriginalXSLT(t *testing.T) {
+	entradas, err := filepath.Glob(filepath.Join("testdata", "*.cadena"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entradas) == 0 {
+		t.Fatal("no hay cadenas en testdata")
+	}
+	for _, entrada := range entradas {
+		entrada := entrada
+		t.Run(filepath.Base(entrada), func(t *testing.T) {
+			esperada, err := os.ReadFile(entrada)
+			if err != nil {
+				t.Fatal(err)
+			}
+			datos, err := os.ReadFile(strings.TrimSuffix(entrada, ".cadena") + ".xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c Comprobante
+			if err := Unmarshal(datos, &c); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			cadena, err := c.CadenaOriginal()
+			if err != nil {
+				t.Fatalf("CadenaOriginal: %v", err)
+			}
+			if cadena != string(esperada) {
+				t.Errorf("la cadena difiere de la XSLT:\n  se esperaba: %s\n  se obtuvo:   %s", esperada, cadena)
+			}
+		})
+	}
+}
+
+func TestCadenaComplementoSinPlantilla(t *testing.T) {
+	c := comprobantePrueba(1)
+	c.Complemento = &CFDIComplemento{Complementos: []interface{}{&ComplementoDesconocido{
+		XML:        []byte(`<otro:Nodo xmlns:otro="urn:ejemplo" Version="1.0"/>`),
+		Namespaces: []xml.Attr{{Name: xml.Name{Local: "xmlns:otro"}, Value: "urn:ejemplo"}},
+	}}}
+	_, err := c.CadenaOriginal()
+	var errCadena *ErrorCadena
+	if !errors.Is(err, ErrComplementoSinCadena) || !errors.As(err, &errCadena) || errCadena.Nodo != "/cfdi:Comprobante/cfdi:Complemento/Nodo" {
+		t.Errorf("se esperaba ErrComplementoSinCadena en el nodo del complemento, se obtuvo %v", err)
+	}
+}
+
+// plantillasXSLT Lee las hojas de testdata/xslt y devuelve, por espacio de nombres y nodo, los atributos que cada xsl:template agrega con Requerido u Opcional, con la notación de plantillasCadena. Las llamadas dentro de un xsl:for-each pertenecen a otro nodo y no se toman.
+func plantillasXSLT(t *testing.T) map[string]map[string][]string {
+	t.Helper()
+	hojas, err := filepath.Glob(filepath.Join("testdata", "xslt", "*.xslt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plantillas := map[string]map[string][]string{}
+	for _, hoja := range hojas {
+		f, err := os.Open(hoja)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := xml.NewDecoder(f)
+		prefijos := map[string]string{}
+		var pila []string
+		var espacio, nodo, llamada string
+		for {
+			token, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", hoja, err)
+			}
+			switch v := token.(type) {
+			case xml.StartElement:
+				pila = append(pila, v.Name.Local)
+				valor := func(nombre string) string {
+					for _, a := range v.Attr {
+						if a.Name.Space == "" && a.Name.Local == nombre {
+							return a.Value
+						}
+					}
+					return ""
+				}
+				switch {
+				case len(pila) == 1:
+					for _, a := range v.Attr {
+						if a.Name.Space == "xmlns" {
+							prefijos[a.Name.Local] = a.Value
+						}
+					}
+				case len(pila) == 2 && v.Name.Local == "template":
+					espacio, nodo = "", ""
+					if partes := strings.SplitN(valor("match"), ":", 2); len(partes) == 2 {
+						espacio, nodo = prefijos[partes[0]], partes[1]
+					}
+					if espacio != "" {
+						if plantillas[espacio] == nil {
+							plantillas[espacio] = map[string][]string{}
+						}
+						plantillas[espacio][nodo] = []string{}
+					}
+				case len(pila) == 3 && v.Name.Local == "call-template":
+					llamada = valor("name")
+				case len(pila) == 4 && v.Name.Local == "with-param" && espacio != "":
+					atributo := strings.TrimPrefix(valor("select"), "./@")
+					if atributo == valor("select") {
+						t.Fatalf("%s: %s:%s llama a %s con %q, que no es un atributo del nodo", hoja, espacio, nodo, llamada, valor("select"))
+					}
+					if llamada == "Opcional" {
+						atributo += "?"
+					}
+					plantillas[espacio][nodo] = append(plantillas[espacio][nodo], atributo)
+				}
+			case xml.EndElement:
+				pila = pila[:len(pila)-1]
+			}
+		}
+		f.Close()
+	}
+	return plantillas
+}
+
+// TestPlantillasCadenaXSLT Compara cada nodo de plantillasCadena con la plantilla de su espacio de nombres en testdata/xslt, en ambos sentidos.
+func TestPlantillasCadenaXSLT(t *testing.T) {
+	xslt := plantillasXSLT(t)
+	for espacio, nodos := range plantillasCadena {
+		hojas, ok := xslt[espacio]
+		if !ok {
+			t.Errorf("testdata/xslt no tiene la hoja de %s", espacio)
+			continue
+		}
+		for nodo, atributos := range nodos {
+			esperados, ok := hojas[nodo]
+			if !ok {
+				t.Errorf("%s: la hoja no tiene plantilla para %s", espacio, nodo)
+				continue
+			}
+			if strings.Join(atributos, " ") != strings.Join(esperados, " ") {
+				t.Errorf("%s %s:\n  plantillasCadena: %v\n  XSLT:             %v", espacio, nodo, atributos, esperados)
+			}
+		}
+		for nodo := range hojas {
+			if _, ok := nodos[nodo]; !ok {
+				t.Errorf("%s: plantillasCadena no tiene el nodo %s de la hoja", espacio, nodo)
+			}
+		}
+	}
+}
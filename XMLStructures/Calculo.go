@@ -0,0 +1,244 @@
+package xmlstructures
+
+import (
+	"errors"
+	"fmt"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Cálculo de importes e impuestos
+*
+*
+****************************************************************************************************************************************/
+
+// Errores devueltos por CalcularTotales, siempre envueltos en un *ErrorCalculo.
+var (
+	ErrDescuentoExcedeImporte = errors.New("el descuento es mayor que el importe del concepto")
+	ErrTasaOCuotaRequerida    = errors.New("TasaOCuota es requerida cuando TipoFactor es Tasa o Cuota")
+	ErrTipoFactor             = errors.New("TipoFactor debe ser Tasa, Cuota o Exento")
+	ErrFueraDeLimites         = errors.New("el importe calculado está fuera de los límites permitidos por el SAT")
+)
+
+// Valores del atributo TipoFactor (catálogo c_TipoFactor).
+const (
+	TipoFactorTasa   = "Tasa"
+	TipoFactorCuota  = "Cuota"
+	TipoFactorExento = "Exento"
+)
+
+// ErrorCalculo Error producido al calcular los importes de un comprobante. Indica el nodo cuyos datos no permiten el cálculo.
+type ErrorCalculo struct {
+	Ruta string // Ruta del nodo, p.ej. /cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[2].
+	Err  error  // Error original.
+}
+
+func (e *ErrorCalculo) Error() string {
+	return fmt.Sprintf("xmlstructures: %s: %v", e.Ruta, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorCalculo) Unwrap() error { return e.Err }
+
+// CalcularTotales Llena todos los importes derivados del comprobante a partir de Cantidad, ValorUnitario y Descuento de cada concepto y de Impuesto, TipoFactor y TasaOCuota de cada uno de sus impuestos:
+//
+//   - Importe de cada concepto y de cada parte que tenga ValorUnitario; el Descuento del concepto se expresa con los mismos decimales.
+//   - Base e Importe de cada traslado y retención de los conceptos. Base es siempre el Importe del concepto menos su Descuento, salvo en los impuestos con BaseFija.
+//   - El nodo Impuestos del comprobante, con los traslados agrupados por Impuesto, TipoFactor y TasaOCuota y las retenciones agrupadas por Impuesto.
+//   - SubTotal, Descuento y Total.
+//
+// Los importes se redondean a los decimales de la moneda, por lo que siempre quedan dentro de los límites inferior y superior que el SAT calcula para cada producto. Si algún dato no permite el cálculo devuelve un *ErrorCalculo y c queda sin cambios.
+func (c *Comprobante) CalcularTotales() error {
+	resultado := *c
+	decimales := DecimalesMoneda(c.Moneda)
+	cero := NewDecimal(0, decimales)
+	subTotal, descuento := cero, cero
+	hayDescuento := false
+	var traslados []CFDITraslado
+	var retenciones []CFDIRetencion
+	conceptos := make([]CFDIConcepto, len(c.Conceptos.Conceptos))
+	for i, concepto := range c.Conceptos.Conceptos {
+		ruta := fmt.Sprintf("/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[%d]", i+1)
+		concepto.Importe = concepto.Cantidad.Mul(concepto.ValorUnitario).Round(decimales)
+		if err := revisarLimitesImporte(concepto.Cantidad, concepto.ValorUnitario, concepto.Importe, ruta); err != nil {
+			return err
+		}
+		base := concepto.Importe
+		if concepto.Descuento != nil {
+			concepto.Descuento = redondearOpcional(concepto.Descuento, decimales)
+			if concepto.Descuento.Cmp(concepto.Importe) > 0 {
+				return &ErrorCalculo{Ruta: ruta, Err: ErrDescuentoExcedeImporte}
+			}
+			base = base.Sub(*concepto.Descuento)
+			descuento = descuento.Add(*concepto.Descuento)
+			hayDescuento = true
+		}
+		subTotal = subTotal.Add(concepto.Importe)
+		if len(concepto.Parte) > 0 {
+			partes := append([]CFDIParte(nil), concepto.Parte...)
+			for j, parte := range partes {
+				if parte.ValorUnitario == nil {
+					continue
+				}
+				importe := parte.Cantidad.Mul(*parte.ValorUnitario).Round(decimales)
+				partes[j].Importe = &importe
+			}
+			concepto.Parte = partes
+		}
+		if concepto.Impuestos != nil {
+			impuestos := *concepto.Impuestos
+			if impuestos.Traslados != nil {
+				lista := *impuestos.Traslados
+				lista.Traslados = append([]CFDIImpuestosTrasladoInner(nil), lista.Traslados...)
+				for j := range lista.Traslados {
+					t := &lista.Traslados[j]
+					rutaImpuesto := fmt.Sprintf("%s/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[%d]", ruta, j+1)
+					if !t.BaseFija {
+						t.Base = base
+					}
+					switch t.TipoFactor {
+					case TipoFactorExento:
+						t.TasaOCuota = nil
+						t.Importe = nil
+						continue
+					case TipoFactorTasa, TipoFactorCuota:
+					default:
+						return &ErrorCalculo{Ruta: rutaImpuesto, Err: ErrTipoFactor}
+					}
+					if t.TasaOCuota == nil {
+						return &ErrorCalculo{Ruta: rutaImpuesto, Err: ErrTasaOCuotaRequerida}
+					}
+					importe := t.Base.Mul(*t.TasaOCuota).Round(decimales)
+					if err := revisarLimitesImpuesto(t.Base, *t.TasaOCuota, importe, rutaImpuesto); err != nil {
+						return err
+					}
+					t.Importe = &importe
+					traslados = acumularTraslado(traslados, CFDITraslado{Impuesto: t.Impuesto, TipoFactor: t.TipoFactor, TasaOCuota: *t.TasaOCuota, Importe: importe})
+				}
+				impuestos.Traslados = &lista
+			}
+			if impuestos.Retenciones != nil {
+				lista := *impuestos.Retenciones
+				lista.Retenciones = append([]CFDIImpuestosRetencionInner(nil), lista.Retenciones...)
+				for j := range lista.Retenciones {
+					r := &lista.Retenciones[j]
+					rutaImpuesto := fmt.Sprintf("%s/cfdi:Impuestos/cfdi:Retenciones/cfdi:Retencion[%d]", ruta, j+1)
+					if !r.BaseFija {
+						r.Base = base
+					}
+					if r.TipoFactor != TipoFactorTasa && r.TipoFactor != TipoFactorCuota {
+						return &ErrorCalculo{Ruta: rutaImpuesto, Err: ErrTipoFactor}
+					}
+					r.Importe = r.Base.Mul(r.TasaOCuota).Round(decimales)
+					if err := revisarLimitesImpuesto(r.Base, r.TasaOCuota, r.Importe, rutaImpuesto); err != nil {
+						return err
+					}
+					retenciones = acumularRetencion(retenciones, CFDIRetencion{Impuesto: r.Impuesto, Importe: r.Importe})
+				}
+				impuestos.Retenciones = &lista
+			}
+			concepto.Impuestos = &impuestos
+		}
+		conceptos[i] = concepto
+	}
+	resultado.Conceptos.Conceptos = conceptos
+	resultado.SubTotal = subTotal
+	resultado.Descuento = nil
+	if hayDescuento {
+		resultado.Descuento = &descuento
+	}
+	total := subTotal.Sub(descuento)
+	resultado.Impuestos = nil
+	if len(traslados) > 0 || len(retenciones) > 0 {
+		impuestos := &CFDIImpuestos{}
+		if len(retenciones) > 0 {
+			suma := cero
+			for _, r := range retenciones {
+				suma = suma.Add(r.Importe)
+			}
+			impuestos.Retenciones = &CFDIRetenciones{Retenciones: retenciones}
+			impuestos.TotalImpuestosRetenidos = &suma
+			total = total.Sub(suma)
+		}
+		if len(traslados) > 0 {
+			suma := cero
+			for _, t := range traslados {
+				suma = suma.Add(t.Importe)
+			}
+			impuestos.Traslados = &CFDITraslados{Traslados: traslados}
+			impuestos.TotalImpuestosTrasladados = &suma
+			total = total.Add(suma)
+		}
+		resultado.Impuestos = impuestos
+	}
+	resultado.Total = total
+	*c = resultado
+	return nil
+}
+
+// acumularTraslado Suma t al traslado de lista con el mismo Impuesto, TipoFactor y TasaOCuota, o lo agrega si no existe.
+func acumularTraslado(lista []CFDITraslado, t CFDITraslado) []CFDITraslado {
+	for i := range lista {
+		if lista[i].Impuesto == t.Impuesto && lista[i].TipoFactor == t.TipoFactor && lista[i].TasaOCuota.Cmp(t.TasaOCuota) == 0 {
+			lista[i].Importe = lista[i].Importe.Add(t.Importe)
+			return lista
+		}
+	}
+	return append(lista, t)
+}
+
+// acumularRetencion Suma r a la retención de lista con el mismo Impuesto, o la agrega si no existe.
+func acumularRetencion(lista []CFDIRetencion, r CFDIRetencion) []CFDIRetencion {
+	for i := range lista {
+		if lista[i].Impuesto == r.Impuesto {
+			lista[i].Importe = lista[i].Importe.Add(r.Importe)
+			return lista
+		}
+	}
+	return append(lista, r)
+}
+
+// LimitesImporte Devuelve los límites inferior y superior que el SAT admite para el Importe de un concepto con la cantidad y valor unitario dados, expresado con decimales dígitos. Cada factor se toma con una tolerancia de media unidad en su último decimal: el inferior se trunca y el superior se redondea hacia arriba.
+func LimitesImporte(cantidad, valorUnitario Decimal, decimales int32) (inferior, superior Decimal) {
+	mitadCantidad := NewDecimal(5, cantidad.Escala+1)
+	mitadValor := NewDecimal(5, valorUnitario.Escala+1)
+	inferior = cantidad.Sub(mitadCantidad).Mul(valorUnitario.Sub(mitadValor)).Truncate(decimales)
+	superior = redondearArriba(cantidad.Add(mitadCantidad).Mul(valorUnitario.Add(mitadValor)), decimales)
+	return inferior, superior
+}
+
+// LimitesImpuesto Devuelve los límites inferior y superior que el SAT admite para el Importe de un traslado o retención con la base y tasa o cuota dadas, expresado con decimales dígitos. La base se toma con una tolerancia de media unidad en su último decimal; la tasa es exacta.
+func LimitesImpuesto(base, tasaOCuota Decimal, decimales int32) (inferior, superior Decimal) {
+	mitad := NewDecimal(5, base.Escala+1)
+	inferior = base.Sub(mitad).Mul(tasaOCuota).Truncate(decimales)
+	superior = redondearArriba(base.Add(mitad).Mul(tasaOCuota), decimales)
+	return inferior, superior
+}
+
+// revisarLimitesImporte Verifica que importe esté dentro de LimitesImporte.
+func revisarLimitesImporte(cantidad, valorUnitario, importe Decimal, ruta string) error {
+	inferior, superior := LimitesImporte(cantidad, valorUnitario, importe.Escala)
+	if importe.Cmp(inferior) < 0 || importe.Cmp(superior) > 0 {
+		return &ErrorCalculo{Ruta: ruta, Err: ErrFueraDeLimites}
+	}
+	return nil
+}
+
+// revisarLimitesImpuesto Verifica que importe esté dentro de LimitesImpuesto.
+func revisarLimitesImpuesto(base, tasaOCuota, importe Decimal, ruta string) error {
+	inferior, superior := LimitesImpuesto(base, tasaOCuota, importe.Escala)
+	if importe.Cmp(inferior) < 0 || importe.Cmp(superior) > 0 {
+		return &ErrorCalculo{Ruta: ruta, Err: ErrFueraDeLimites}
+	}
+	return nil
+}
+
+// redondearArriba Devuelve d con escala decimales redondeando hacia +infinito.
+func redondearArriba(d Decimal, escala int32) Decimal {
+	t := d.Truncate(escala)
+	if t.Cmp(d) < 0 {
+		t = t.Add(NewDecimal(1, escala))
+	}
+	return t.Round(escala)
+}
@@ -0,0 +1,242 @@
+package xmlstructures
+
+import (
+	"errors"
+	"testing"
+)
+
+// trasladoCalculo Devuelve un traslado sin Base ni Importe, para que CalcularTotales los llene.
+func trasladoCalculo(impuesto, tipoFactor, tasaOCuota string) CFDIImpuestosTrasladoInner {
+	t := CFDIImpuestosTrasladoInner{Impuesto: impuesto, TipoFactor: tipoFactor}
+	if tasaOCuota != "" {
+		t.TasaOCuota = pdec(tasaOCuota)
+	}
+	return t
+}
+
+// conceptoCalculo Devuelve un concepto con cantidad, valor unitario, los traslados dados y, si se indican, retenciones con TipoFactor Tasa en pares Impuesto, TasaOCuota.
+func conceptoCalculo(cantidad, valorUnitario string, traslados []CFDIImpuestosTrasladoInner, retenciones ...string) CFDIConcepto {
+	c := CFDIConcepto{ClaveProdServ: "01010101", Cantidad: dec(cantidad), ClaveUnidad: "H87", Descripcion: "Producto", ValorUnitario: dec(valorUnitario)}
+	c.Impuestos = &CFDIImpuestosInner{Traslados: &CFDIImpuestosTrasladosInner{Traslados: traslados}}
+	if len(retenciones) > 0 {
+		c.Impuestos.Retenciones = &CFDIImpuestosRetencionesInner{}
+		for i := 0; i+1 < len(retenciones); i += 2 {
+			c.Impuestos.Retenciones.Retenciones = append(c.Impuestos.Retenciones.Retenciones, CFDIImpuestosRetencionInner{Impuesto: retenciones[i], TipoFactor: TipoFactorTasa, TasaOCuota: dec(retenciones[i+1])})
+		}
+	}
+	return c
+}
+
+// calculoPrueba Devuelve un comprobante en moneda con los conceptos dados, ya calculado.
+func calculoPrueba(t *testing.T, moneda string, conceptos ...CFDIConcepto) Comprobante {
+	t.Helper()
+	c := Comprobante{Version: VersionCFDI, Moneda: moneda, TipoDeComprobante: "I"}
+	c.Conceptos.Conceptos = conceptos
+	if err := c.CalcularTotales(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCalcularTotalesRedondeo(t *testing.T) {
+	casos := []struct {
+		moneda, cantidad, valorUnitario string
+		importe, iva, total             string
+	}{
+		{"MXN", "3", "33.335", "100.01", "16.00", "116.01"}, // 100.005 e IVA 16.0016.
+		{"MXN", "1", "0.125", "0.13", "0.02", "0.15"},       // IVA 0.0208.
+		{"MXN", "0.5", "0.01", "0.01", "0.00", "0.01"},      // 0.005 se redondea hacia afuera del cero.
+		{"MXN", "1.25", "19.99", "24.99", "4.00", "28.99"},  // 24.9875 e IVA 3.9984.
+		{"JPY", "2", "10.50", "21", "3", "24"},              // Sin decimales: IVA 3.36.
+	}
+	for _, caso := range casos {
+		t.Run(caso.moneda+" "+caso.cantidad+"x"+caso.valorUnitario, func(t *testing.T) {
+			c := calculoPrueba(t, caso.moneda, conceptoCalculo(caso.cantidad, caso.valorUnitario, []CFDIImpuestosTrasladoInner{trasladoCalculo("002", TipoFactorTasa, "0.160000")}))
+			concepto := c.Conceptos.Conceptos[0]
+			traslado := concepto.Impuestos.Traslados.Traslados[0]
+			if concepto.Importe.String() != caso.importe || traslado.Base.String() != caso.importe || traslado.Importe.String() != caso.iva {
+				t.Errorf("Importe %s, Base %s e IVA %s; se esperaba %s, %s y %s", concepto.Importe, traslado.Base, traslado.Importe, caso.importe, caso.importe, caso.iva)
+			}
+			if c.SubTotal.String() != caso.importe || c.Total.String() != caso.total || c.Impuestos.TotalImpuestosTrasladados.String() != caso.iva {
+				t.Errorf("SubTotal %s, Total %s y TotalImpuestosTrasladados %s", c.SubTotal, c.Total, c.Impuestos.TotalImpuestosTrasladados)
+			}
+		})
+	}
+}
+
+func TestLimites(t *testing.T) {
+	casos := []struct {
+		nombre             string
+		limites            func() (Decimal, Decimal)
+		inferior, superior string
+	}{
+		// Cada factor se toma con media unidad de tolerancia en su último decimal; el inferior se trunca y el superior se redondea hacia arriba.
+		{"importe 1.5 x 10.25", func() (Decimal, Decimal) { return LimitesImporte(dec("1.5"), dec("10.25"), 2) }, "14.85", "15.90"},        // 1.45 × 10.245 = 14.85525 y 1.55 × 10.255 = 15.89525.
+		{"importe de cantidad entera", func() (Decimal, Decimal) { return LimitesImporte(dec("1"), dec("100.00"), 2) }, "49.99", "150.01"}, // 0.5 × 99.995 y 1.5 × 100.005.
+		{"importe 3.000 x 33.335", func() (Decimal, Decimal) { return LimitesImporte(dec("3.000"), dec("33.335"), 2) }, "99.98", "100.03"}, // 2.9995 × 33.3345 = 99.98683275 y 3.0005 × 33.3355 = 100.02316775.
+		{"importe sin decimales", func() (Decimal, Decimal) { return LimitesImporte(dec("2"), dec("10.50"), 0) }, "15", "27"},              // 1.5 × 10.495 = 15.7425 y 2.5 × 10.505 = 26.2625.
+		// La base se toma con media unidad de tolerancia; la tasa es exacta.
+		{"IVA de 100.00", func() (Decimal, Decimal) { return LimitesImpuesto(dec("100.00"), dec("0.160000"), 2) }, "15.99", "16.01"},        // 99.995 × 0.16 = 15.9992 y 100.005 × 0.16 = 16.0008.
+		{"cuota sobre 450.00", func() (Decimal, Decimal) { return LimitesImpuesto(dec("450.00"), dec("0.500000"), 2) }, "224.99", "225.01"}, // 449.995 × 0.5 y 450.005 × 0.5.
+		{"IVA sin decimales", func() (Decimal, Decimal) { return LimitesImpuesto(dec("21"), dec("0.160000"), 0) }, "3", "4"},                // 20.5 × 0.16 = 3.28 y 21.5 × 0.16 = 3.44.
+	}
+	for _, caso := range casos {
+		inferior, superior := caso.limites()
+		if inferior.String() != caso.inferior || superior.String() != caso.superior {
+			t.Errorf("%s: límites [%s, %s], se esperaba [%s, %s]", caso.nombre, inferior, superior, caso.inferior, caso.superior)
+		}
+	}
+}
+
+func TestCalcularTotalesDentroDeLimites(t *testing.T) {
+	c := calculoPrueba(t, "MXN",
+		conceptoCalculo("3.000", "33.335", []CFDIImpuestosTrasladoInner{trasladoCalculo("002", TipoFactorTasa, "0.160000")}, "001", "0.100000"),
+		conceptoCalculo("0.333333", "999999.99", []CFDIImpuestosTrasladoInner{trasladoCalculo("003", TipoFactorCuota, "43.770000")}, "002", "0.106667"),
+	)
+	for i, concepto := range c.Conceptos.Conceptos {
+		inferior, superior := LimitesImporte(concepto.Cantidad, concepto.ValorUnitario, 2)
+		if concepto.Importe.Cmp(inferior) < 0 || concepto.Importe.Cmp(superior) > 0 {
+			t.Errorf("concepto %d: Importe %s fuera de [%s, %s]", i+1, concepto.Importe, inferior, superior)
+		}
+		for _, tr := range concepto.Impuestos.Traslados.Traslados {
+			inferior, superior := LimitesImpuesto(tr.Base, *tr.TasaOCuota, 2)
+			if tr.Importe.Cmp(inferior) < 0 || tr.Importe.Cmp(superior) > 0 {
+				t.Errorf("concepto %d: traslado %s fuera de [%s, %s]", i+1, tr.Importe, inferior, superior)
+			}
+		}
+		for _, r := range concepto.Impuestos.Retenciones.Retenciones {
+			inferior, superior := LimitesImpuesto(r.Base, r.TasaOCuota, 2)
+			if r.Importe.Cmp(inferior) < 0 || r.Importe.Cmp(superior) > 0 {
+				t.Errorf("concepto %d: retención %s fuera de [%s, %s]", i+1, r.Importe, inferior, superior)
+			}
+		}
+	}
+	for _, e := range Validate(c) {
+		if e.Codigo == "CFDI33149" || e.Codigo == "CFDI33161" || e.Codigo == "CFDI33167" {
+			t.Errorf("Validate reportó %v", e)
+		}
+	}
+}
+
+func TestCalcularTotalesAgrupados(t *testing.T) {
+	iva16 := trasladoCalculo("002", TipoFactorTasa, "0.160000")
+	descontado := conceptoCalculo("2", "250.00", []CFDIImpuestosTrasladoInner{iva16, trasladoCalculo("003", TipoFactorCuota, "0.500000")}, "001", "0.100000")
+	descontado.Descuento = pdec("50")
+	c := calculoPrueba(t, "MXN",
+		conceptoCalculo("1", "1000.00", []CFDIImpuestosTrasladoInner{iva16, trasladoCalculo("003", TipoFactorTasa, "0.265000")}, "001", "0.100000", "002", "0.106667"),
+		descontado,
+		conceptoCalculo("1", "300.00", []CFDIImpuestosTrasladoInner{trasladoCalculo("002", TipoFactorTasa, "0.080000")}),
+		conceptoCalculo("1", "200.00", []CFDIImpuestosTrasladoInner{trasladoCalculo("002", TipoFactorExento, "0.160000")}),
+	)
+	if d := c.Conceptos.Conceptos[1].Descuento; d.String() != "50.00" {
+		t.Errorf("el descuento del concepto no se expresó con los decimales de la moneda: %s", d)
+	}
+	if base := c.Conceptos.Conceptos[1].Impuestos.Traslados.Traslados[0].Base; base.String() != "450.00" {
+		t.Errorf("la base del concepto con descuento es %s, se esperaba 450.00", base)
+	}
+	esperados := []struct{ impuesto, tipoFactor, tasa, importe string }{
+		{"002", TipoFactorTasa, "0.160000", "232.00"}, // 160.00 + 72.00
+		{"003", TipoFactorTasa, "0.265000", "265.00"},
+		{"003", TipoFactorCuota, "0.500000", "225.00"},
+		{"002", TipoFactorTasa, "0.080000", "24.00"},
+	}
+	traslados := c.Impuestos.Traslados.Traslados
+	if len(traslados) != len(esperados) {
+		t.Fatalf("se agruparon %d traslados, se esperaban %d: %+v", len(traslados), len(esperados), traslados)
+	}
+	for i, e := range esperados {
+		if tr := traslados[i]; tr.Impuesto != e.impuesto || tr.TipoFactor != e.tipoFactor || tr.TasaOCuota.String() != e.tasa || tr.Importe.String() != e.importe {
+			t.Errorf("traslado %d: %s %s %s %s, se esperaba %+v", i+1, tr.Impuesto, tr.TipoFactor, tr.TasaOCuota, tr.Importe, e)
+		}
+	}
+	retenciones := c.Impuestos.Retenciones.Retenciones
+	if len(retenciones) != 2 || retenciones[0].Impuesto != "001" || retenciones[0].Importe.String() != "145.00" || retenciones[1].Impuesto != "002" || retenciones[1].Importe.String() != "106.67" {
+		t.Errorf("retenciones agrupadas %+v", retenciones)
+	}
+	if c.Impuestos.TotalImpuestosTrasladados.String() != "746.00" || c.Impuestos.TotalImpuestosRetenidos.String() != "251.67" {
+		t.Errorf("totales de impuestos %s y %s", c.Impuestos.TotalImpuestosTrasladados, c.Impuestos.TotalImpuestosRetenidos)
+	}
+	if c.SubTotal.String() != "2000.00" || c.Descuento.String() != "50.00" || c.Total.String() != "2444.33" {
+		t.Errorf("SubTotal %s, Descuento %s y Total %s; se esperaba 2000.00, 50.00 y 2444.33", c.SubTotal, c.Descuento, c.Total)
+	}
+}
+
+func TestCalcularTotalesExento(t *testing.T) {
+	c := calculoPrueba(t, "MXN", conceptoCalculo("4", "50.00", []CFDIImpuestosTrasladoInner{trasladoCalculo("002", TipoFactorExento, "0.160000")}))
+	tr := c.Conceptos.Conceptos[0].Impuestos.Traslados.Traslados[0]
+	if tr.Base.String() != "200.00" || tr.TasaOCuota != nil || tr.Importe != nil {
+		t.Errorf("traslado exento Base %s, TasaOCuota %v, Importe %v", tr.Base, tr.TasaOCuota, tr.Importe)
+	}
+	if c.Impuestos != nil {
+		t.Errorf("un traslado exento no debe agruparse en el comprobante: %+v", c.Impuestos)
+	}
+	if c.Total.String() != "200.00" {
+		t.Errorf("Total %s", c.Total)
+	}
+}
+
+func TestCalcularTotalesBase(t *testing.T) {
+	fija := trasladoCalculo("002", TipoFactorTasa, "0.160000")
+	fija.Base, fija.BaseFija = dec("150.00"), true // IVA sobre el precio más IEPS.
+	c := calculoPrueba(t, "MXN", conceptoCalculo("1", "100.00", []CFDIImpuestosTrasladoInner{trasladoCalculo("003", TipoFactorTasa, "0.500000"), fija}, "001", "0.100000"))
+
+	c.Conceptos.Conceptos[0].Cantidad = dec("2")
+	if err := c.CalcularTotales(); err != nil {
+		t.Fatal(err)
+	}
+	concepto := c.Conceptos.Conceptos[0]
+	traslados := concepto.Impuestos.Traslados.Traslados
+	if traslados[0].Base.String() != "200.00" || traslados[0].Importe.String() != "100.00" {
+		t.Errorf("el traslado conservó la base anterior: Base %s, Importe %s", traslados[0].Base, traslados[0].Importe)
+	}
+	if r := concepto.Impuestos.Retenciones.Retenciones[0]; r.Base.String() != "200.00" || r.Importe.String() != "20.00" {
+		t.Errorf("la retención conservó la base anterior: Base %s, Importe %s", r.Base, r.Importe)
+	}
+	if traslados[1].Base.String() != "150.00" || traslados[1].Importe.String() != "24.00" {
+		t.Errorf("no se respetó BaseFija: Base %s, Importe %s", traslados[1].Base, traslados[1].Importe)
+	}
+}
+
+func TestCalcularTotalesErrores(t *testing.T) {
+	const concepto = "/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto"
+	casos := []struct {
+		nombre   string
+		cambio   func(c *Comprobante)
+		ruta     string
+		esperado error
+	}{
+		{"descuento mayor que el importe", func(c *Comprobante) { c.Conceptos.Conceptos[1].Descuento = pdec("20.51") }, concepto + "[2]", ErrDescuentoExcedeImporte},
+		{"traslado sin TasaOCuota", func(c *Comprobante) { c.Conceptos.Conceptos[0].Impuestos.Traslados.Traslados[0].TasaOCuota = nil }, concepto + "[1]/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[1]", ErrTasaOCuotaRequerida},
+		{"TipoFactor de traslado", func(c *Comprobante) {
+			c.Conceptos.Conceptos[0].Impuestos.Traslados.Traslados[0].TipoFactor = "Porcentaje"
+		}, concepto + "[1]/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[1]", ErrTipoFactor},
+		{"retención exenta", func(c *Comprobante) {
+			c.Conceptos.Conceptos[1].Impuestos.Retenciones.Retenciones[0].TipoFactor = TipoFactorExento
+		}, concepto + "[2]/cfdi:Impuestos/cfdi:Retenciones/cfdi:Retencion[1]", ErrTipoFactor},
+		// El importe redondeado de factores positivos siempre queda dentro de los límites; con cantidad y valor unitario negativos el intervalo se invierte.
+		{"importe fuera de límites", func(c *Comprobante) {
+			c.Conceptos.Conceptos[0].Cantidad, c.Conceptos.Conceptos[0].ValorUnitario = dec("-1"), dec("-10.00")
+		}, concepto + "[1]", ErrFueraDeLimites},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			c := comprobantePrueba(2)
+			caso.cambio(&c)
+			antes, err := Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.CalcularTotales()
+			var e *ErrorCalculo
+			if !errors.As(err, &e) || !errors.Is(err, caso.esperado) {
+				t.Fatalf("se esperaba %v en un *ErrorCalculo, se obtuvo %v", caso.esperado, err)
+			}
+			if e.Ruta != caso.ruta {
+				t.Errorf("Ruta %s, se esperaba %s", e.Ruta, caso.ruta)
+			}
+			if despues, _ := Marshal(c); string(despues) != string(antes) {
+				t.Errorf("el comprobante cambió a pesar del error:\n%s", diferencia(antes, despues))
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package xmlstructures
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Solicitud de cancelación firmada con el CSD
+*
+*
+****************************************************************************************************************************************/
+
+// NamespaceCancelacion Espacio de nombres de la solicitud y del acuse de cancelación del SAT.
+const NamespaceCancelacion = "http://cancelacfd.sat.gob.mx"
+
+// Algoritmos de la firma XMLDSig. FirmarCancelacion usa los que exige el servicio de cancelación del SAT: C14N inclusiva, firma envolvente y RSA-SHA1; VerificarCancelacion acepta además RSA-SHA256 y SHA-256.
+const (
+	nsXMLDSig                = "http://www.w3.org/2000/09/xmldsig#"
+	algoritmoC14N            = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	algoritmoFirma           = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	algoritmoFirmaSHA256     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algoritmoEnvolvente      = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algoritmoDigestion       = "http://www.w3.org/2000/09/xmldsig#sha1"
+	algoritmoDigestionSHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+// Nombres de los nodos de la firma que se canonicalizan.
+var (
+	nombreFirma       = xml.Name{Space: nsXMLDSig, Local: "Signature"}
+	nombreInfoFirmada = xml.Name{Space: nsXMLDSig, Local: "SignedInfo"}
+)
+
+// funcionesHash Función hash de cada algoritmo de digestión y de firma admitido.
+var funcionesHash = map[string]crypto.Hash{
+	algoritmoFirma:           crypto.SHA1,
+	algoritmoFirmaSHA256:     crypto.SHA256,
+	algoritmoDigestion:       crypto.SHA1,
+	algoritmoDigestionSHA256: crypto.SHA256,
+}
+
+// Errores de la solicitud de cancelación. Al verificarla se devuelven envueltos en un *ErrorVerificacion.
+var (
+	ErrCancelacionSinFolios = errors.New("la solicitud de cancelación no incluye folios")
+	ErrDigestionCancelacion = errors.New("el valor de digestión no corresponde a la solicitud")
+	ErrFirmaCancelacion     = errors.New("la firma no corresponde a la solicitud y al certificado")
+	ErrRFCCancelacion       = errors.New("el RFC del certificado no es el del emisor")
+	ErrAlgoritmoCancelacion = errors.New("algoritmo de firma no soportado")
+)
+
+// FolioCancelacion Comprobante incluido en una solicitud de cancelación.
+type FolioCancelacion struct {
+	UUID             string `xml:"UUID,attr"`                       // Folio fiscal del comprobante.
+	Motivo           string `xml:"Motivo,attr"`                     // Clave del motivo de cancelación: 01 comprobante emitido con errores con relación, 02 sin relación, 03 no se llevó a cabo la operación, 04 operación nominativa en factura global.
+	FolioSustitucion string `xml:"FolioSustitucion,attr,omitempty"` // UUID del comprobante que sustituye al cancelado; requerido con el motivo 01.
+}
+
+// Cancelacion Solicitud de cancelación de comprobantes de un emisor. El SAT la recibe firmada por el emisor con XMLDSig; FirmarCancelacion la firma y VerificarCancelacion la lee y verifica.
+type Cancelacion struct {
+	Fecha       string             // Fecha y hora de la solicitud, AAAA-MM-DDThh:mm:ss.
+	RFCEmisor   string             // RFC del emisor de los comprobantes.
+	Folios      []FolioCancelacion // Comprobantes a cancelar.
+	Certificado *x509.Certificate  // Certificado con que se firmó la solicitud; sólo lo llena VerificarCancelacion.
+}
+
+// FirmarCancelacion Devuelve la solicitud c firmada con el CSD, lista para enviarse al PAC. La firma es XMLDSig envolvente con C14N inclusiva y RSA-SHA1, e incluye el certificado, su emisor en formato RFC 2253 y su número de serie.
+func (s *CSD) FirmarCancelacion(c Cancelacion) ([]byte, error) {
+	if len(c.Folios) == 0 {
+		return nil, ErrCancelacionSinFolios
+	}
+	emisor, err := nombreRFC2253(s.Certificado.RawIssuer)
+	if err != nil {
+		return nil, err
+	}
+	documento := c.documento()
+	canonica, err := canonicalizar(documento, xml.Name{}, nombreFirma)
+	if err != nil {
+		return nil, err
+	}
+	suma := sha1.Sum(canonica)
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(string(documento), "</Cancelacion>"))
+	b.WriteString(`<Signature xmlns="` + nsXMLDSig + `"><SignedInfo>`)
+	b.WriteString(`<CanonicalizationMethod Algorithm="` + algoritmoC14N + `"></CanonicalizationMethod>`)
+	b.WriteString(`<SignatureMethod Algorithm="` + algoritmoFirma + `"></SignatureMethod>`)
+	b.WriteString(`<Reference URI=""><Transforms><Transform Algorithm="` + algoritmoEnvolvente + `"></Transform></Transforms>`)
+	b.WriteString(`<DigestMethod Algorithm="` + algoritmoDigestion + `"></DigestMethod>`)
+	b.WriteString(`<DigestValue>` + base64.StdEncoding.EncodeToString(suma[:]) + `</DigestValue></Reference></SignedInfo>`)
+	b.WriteString("<SignatureValue></SignatureValue>")
+	b.WriteString("<KeyInfo><X509Data><X509IssuerSerial>")
+	b.WriteString("<X509IssuerName>" + textoCanonico(emisor) + "</X509IssuerName>")
+	b.WriteString("<X509SerialNumber>" + s.Certificado.SerialNumber.String() + "</X509SerialNumber>")
+	b.WriteString("</X509IssuerSerial><X509Certificate>" + s.CertificadoBase64() + "</X509Certificate></X509Data></KeyInfo>")
+	b.WriteString("</Signature></Cancelacion>")
+	sinFirma := b.String()
+
+	infoFirmada, err := canonicalizar([]byte(sinFirma), nombreInfoFirmada, xml.Name{})
+	if err != nil {
+		return nil, err
+	}
+	sumaInfo := sha1.Sum(infoFirmada)
+	firma, err := rsa.SignPKCS1v15(rand.Reader, s.Llave, crypto.SHA1, sumaInfo[:])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Replace(sinFirma, "<SignatureValue></SignatureValue>", "<SignatureValue>"+base64.StdEncoding.EncodeToString(firma)+"</SignatureValue>", 1)), nil
+}
+
+// algoritmoFirmaXML Atributo Algorithm de un nodo de la firma.
+type algoritmoFirmaXML struct {
+	Algoritmo string `xml:"Algorithm,attr"`
+}
+
+// cancelacionFirmada Solicitud de cancelación tal como se lee del documento firmado.
+type cancelacionFirmada struct {
+	XMLName       xml.Name           `xml:"Cancelacion"`
+	Fecha         string             `xml:"Fecha,attr"`
+	RFCEmisor     string             `xml:"RfcEmisor,attr"`
+	Folios        []FolioCancelacion `xml:"Folios>Folio"`
+	Canonicalizar algoritmoFirmaXML  `xml:"Signature>SignedInfo>CanonicalizationMethod"`
+	MetodoFirma   algoritmoFirmaXML  `xml:"Signature>SignedInfo>SignatureMethod"`
+	Referencias   []referenciaFirma  `xml:"Signature>SignedInfo>Reference"`
+	ValorFirma    string             `xml:"Signature>SignatureValue"`
+	Certificado   string             `xml:"Signature>KeyInfo>X509Data>X509Certificate"`
+}
+
+// referenciaFirma Nodo Reference de SignedInfo.
+type referenciaFirma struct {
+	URI              string              `xml:"URI,attr"`
+	Transformaciones []algoritmoFirmaXML `xml:"Transforms>Transform"`
+	MetodoDigestion  algoritmoFirmaXML   `xml:"DigestMethod"`
+	ValorDigestion   string              `xml:"DigestValue"`
+}
+
+// VerificarCancelacion Lee una solicitud de cancelación firmada y verifica su firma con el certificado incluido, que además debe pertenecer al emisor. La digestión y SignedInfo se canonicalizan a partir del documento recibido, por lo que se aceptan solicitudes firmadas por cualquier implementación de XMLDSig con una sola referencia al documento completo (URI vacío), firma envolvente, C14N inclusiva, y RSA-SHA1 o RSA-SHA256 con digestión SHA-1 o SHA-256; otros algoritmos devuelven ErrAlgoritmoCancelacion.
+func VerificarCancelacion(datos []byte) (*Cancelacion, error) {
+	var f cancelacionFirmada
+	if err := xml.Unmarshal(datos, &f); err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion", Err: err}
+	}
+	if len(f.Folios) == 0 {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Folios", Err: ErrCancelacionSinFolios}
+	}
+	if err := revisarAlgoritmos(f); err != nil {
+		return nil, err
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(f.Certificado), ""))
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Signature/KeyInfo/X509Data/X509Certificate", Err: err}
+	}
+	certificado, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Signature/KeyInfo/X509Data/X509Certificate", Err: err}
+	}
+	c := &Cancelacion{Fecha: f.Fecha, RFCEmisor: f.RFCEmisor, Folios: f.Folios, Certificado: certificado}
+
+	referencia := f.Referencias[0]
+	canonica, err := canonicalizar(datos, xml.Name{}, nombreFirma)
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion", Err: err}
+	}
+	digestion := funcionesHash[referencia.MetodoDigestion.Algoritmo].New()
+	digestion.Write(canonica)
+	if strings.TrimSpace(referencia.ValorDigestion) != base64.StdEncoding.EncodeToString(digestion.Sum(nil)) {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Signature/SignedInfo/Reference/DigestValue", Err: ErrDigestionCancelacion}
+	}
+	infoFirmada, err := canonicalizar(datos, nombreInfoFirmada, xml.Name{})
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Signature/SignedInfo", Err: err}
+	}
+	hash := funcionesHash[f.MetodoFirma.Algoritmo]
+	sumaInfo := hash.New()
+	sumaInfo.Write(infoFirmada)
+	publica, ok := certificado.PublicKey.(*rsa.PublicKey)
+	firma, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(f.ValorFirma), ""))
+	if !ok || err != nil || rsa.VerifyPKCS1v15(publica, hash, sumaInfo.Sum(nil), firma) != nil {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/Signature/SignatureValue", Err: ErrFirmaCancelacion}
+	}
+	if !strings.EqualFold(RFCCertificado(certificado), strings.TrimSpace(c.RFCEmisor)) {
+		return nil, &ErrorVerificacion{Nodo: "/Cancelacion/@RfcEmisor", Err: ErrRFCCancelacion}
+	}
+	return c, nil
+}
+
+// revisarAlgoritmos Devuelve un *ErrorVerificacion con ErrAlgoritmoCancelacion si la firma usa algoritmos o referencias que VerificarCancelacion no admite.
+func revisarAlgoritmos(f cancelacionFirmada) error {
+	const ruta = "/Cancelacion/Signature/SignedInfo"
+	algoritmo := func(nodo, valor string) error {
+		return &ErrorVerificacion{Nodo: ruta + nodo, Err: fmt.Errorf("%w: %q", ErrAlgoritmoCancelacion, valor)}
+	}
+	if f.Canonicalizar.Algoritmo != algoritmoC14N {
+		return algoritmo("/CanonicalizationMethod", f.Canonicalizar.Algoritmo)
+	}
+	if f.MetodoFirma.Algoritmo != algoritmoFirma && f.MetodoFirma.Algoritmo != algoritmoFirmaSHA256 {
+		return algoritmo("/SignatureMethod", f.MetodoFirma.Algoritmo)
+	}
+	if len(f.Referencias) != 1 || f.Referencias[0].URI != "" {
+		return &ErrorVerificacion{Nodo: ruta + "/Reference", Err: fmt.Errorf("%w: se espera una sola referencia al documento completo", ErrAlgoritmoCancelacion)}
+	}
+	r := f.Referencias[0]
+	if r.MetodoDigestion.Algoritmo != algoritmoDigestion && r.MetodoDigestion.Algoritmo != algoritmoDigestionSHA256 {
+		return algoritmo("/Reference/DigestMethod", r.MetodoDigestion.Algoritmo)
+	}
+	for i, t := range r.Transformaciones {
+		if i == 0 && t.Algoritmo != algoritmoEnvolvente || i == 1 && t.Algoritmo != algoritmoC14N || i > 1 {
+			return algoritmo("/Reference/Transforms/Transform", t.Algoritmo)
+		}
+	}
+	if len(r.Transformaciones) == 0 {
+		return algoritmo("/Reference/Transforms", "")
+	}
+	return nil
+}
+
+// documento Devuelve la solicitud sin firma.
+func (c Cancelacion) documento() []byte {
+	var b strings.Builder
+	b.WriteString(`<Cancelacion xmlns="` + NamespaceCancelacion + `" Fecha="` + atributoCanonico(c.Fecha) + `" RfcEmisor="` + atributoCanonico(c.RFCEmisor) + `"><Folios>`)
+	for _, f := range c.Folios {
+		b.WriteString(`<Folio UUID="` + atributoCanonico(f.UUID) + `" Motivo="` + atributoCanonico(f.Motivo) + `"`)
+		if f.FolioSustitucion != "" {
+			b.WriteString(` FolioSustitucion="` + atributoCanonico(f.FolioSustitucion) + `"`)
+		}
+		b.WriteString("></Folio>")
+	}
+	b.WriteString("</Folios></Cancelacion>")
+	return []byte(b.String())
+}
+
+// Tipos de atributo con nombre corto en RFC 2253, sección 2.3.
+var nombresRFC2253 = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.6":                    "C",
+	"2.5.4.9":                    "STREET",
+	"0.9.2342.19200300.100.1.25": "DC",
+	"0.9.2342.19200300.100.1.1":  "UID",
+}
+
+// atributoNombre AttributeTypeAndValue con el valor sin interpretar.
+type atributoNombre struct {
+	Tipo  asn1.ObjectIdentifier
+	Valor asn1.RawValue
+}
+
+// rdnSET RelativeDistinguishedName; el sufijo SET hace que encoding/asn1 lo lea como SET OF.
+type rdnSET []atributoNombre
+
+// nombreRFC2253 Devuelve el Name codificado en DER en raw con el formato de RFC 2253, que es el que pide XMLDSig para X509IssuerName: los RDN en orden inverso separados por comas, los atributos de un RDN separados por +, los tipos de la sección 2.3 con su nombre corto y los demás (p.ej. el x500UniqueIdentifier o el correo del SAT) como OID con el valor en hexadecimal.
+func nombreRFC2253(raw []byte) (string, error) {
+	var secuencia []rdnSET
+	if resto, err := asn1.Unmarshal(raw, &secuencia); err != nil {
+		return "", err
+	} else if len(resto) > 0 {
+		return "", errors.New("datos sobrantes después del nombre")
+	}
+	var rdns []string
+	for i := len(secuencia) - 1; i >= 0; i-- {
+		var atributos []string
+		for _, a := range secuencia[i] {
+			nombre, ok := nombresRFC2253[a.Tipo.String()]
+			var texto string
+			if ok {
+				_, err := asn1.Unmarshal(a.Valor.FullBytes, &texto)
+				ok = err == nil
+			}
+			if ok {
+				atributos = append(atributos, nombre+"="+escaparRFC2253(texto))
+			} else {
+				atributos = append(atributos, a.Tipo.String()+"=#"+strings.ToUpper(hex.EncodeToString(a.Valor.FullBytes)))
+			}
+		}
+		rdns = append(rdns, strings.Join(atributos, "+"))
+	}
+	return strings.Join(rdns, ","), nil
+}
+
+// escaparRFC2253 Escapa un valor de texto según RFC 2253, sección 2.4.
+func escaparRFC2253(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case strings.ContainsRune(`,+"\<>;`, r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(s)-1 && r == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package xmlstructures
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Archivos de testdata/cancelacion, producidos fuera de este paquete con la implementación de C14N de libxml2 (xmlC14NDocDumpMemory con los conjuntos de nodos XPath de XMLDSig) y openssl dgst -sign con la llave de testdata/csd:
+//
+//   - cancelacion-sha1.xml: solicitud con RSA-SHA1, la firma con prefijo ds:, sangría y las declaraciones xsi y xsd que agregan las bibliotecas de .NET.
+//   - cancelacion-sha256.xml: solicitud con RSA-SHA256 en una sola línea, con la firma en el espacio de nombres por omisión.
+//   - c14n.xml: documento con comentarios, instrucciones de procesamiento, CDATA, referencias de caracteres y declaraciones redundantes; c14n-envolvente.c14n es su forma canónica sin ds:Signature y c14n-signedinfo.c14n la de ds:SignedInfo.
+
+// leerCancelacion Lee un archivo de testdata/cancelacion.
+func leerCancelacion(t *testing.T, nombre string) []byte {
+	t.Helper()
+	datos, err := os.ReadFile(filepath.Join("testdata", "cancelacion", nombre))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return datos
+}
+
+func TestCanonicalizar(t *testing.T) {
+	datos := leerCancelacion(t, "c14n.xml")
+	casos := []struct {
+		archivo          string
+		incluir, excluir xml.Name
+	}{
+		{"c14n-envolvente.c14n", xml.Name{}, nombreFirma},
+		{"c14n-signedinfo.c14n", nombreInfoFirmada, xml.Name{}},
+	}
+	for _, caso := range casos {
+		obtenido, err := canonicalizar(datos, caso.incluir, caso.excluir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if esperado := leerCancelacion(t, caso.archivo); string(obtenido) != string(esperado) {
+			t.Errorf("%s:\n  se esperaba: %q\n  se obtuvo:   %q", caso.archivo, esperado, obtenido)
+		}
+	}
+}
+
+func TestVerificarCancelacionExterna(t *testing.T) {
+	for _, archivo := range []string{"cancelacion-sha1.xml", "cancelacion-sha256.xml"} {
+		t.Run(archivo, func(t *testing.T) {
+			c, err := VerificarCancelacion(leerCancelacion(t, archivo))
+			if err != nil {
+				t.Fatalf("VerificarCancelacion: %v", err)
+			}
+			if c.RFCEmisor != "AAA010101AAA" || len(c.Folios) == 0 || c.Folios[0].UUID != "ED1752FE-E865-4FF2-BFE1-0F552E770DC9" {
+				t.Errorf("solicitud leída %+v", c)
+			}
+		})
+	}
+	c, _ := VerificarCancelacion(leerCancelacion(t, "cancelacion-sha1.xml"))
+	if len(c.Folios) != 2 || c.Folios[1].Motivo != "01" || c.Folios[1].FolioSustitucion != "75D992A1-43D4-4845-A495-D75919C39B90" {
+		t.Errorf("folios %+v", c.Folios)
+	}
+}
+
+func TestVerificarCancelacionAlterada(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		viejo    string
+		nuevo    string
+		esperado error
+	}{
+		{"motivo", `Motivo="02"`, `Motivo="03"`, ErrDigestionCancelacion},
+		{"SignedInfo", `<ds:Reference URI="">`, `<ds:Reference  URI="" >`, nil},
+		{"espacio en SignedInfo", "<ds:SignedInfo>\n", "<ds:SignedInfo> \n", ErrFirmaCancelacion},
+		{"algoritmo", `REC-xml-c14n-20010315" />` + "\n      <ds:SignatureMethod", `xml-exc-c14n#" />` + "\n      <ds:SignatureMethod", ErrAlgoritmoCancelacion},
+		{"RFC", `RfcEmisor="AAA010101AAA"`, `RfcEmisor="EKU9003173C9"`, ErrDigestionCancelacion},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			datos := string(leerCancelacion(t, "cancelacion-sha1.xml"))
+			if !strings.Contains(datos, caso.viejo) {
+				t.Fatalf("la solicitud no contiene %q", caso.viejo)
+			}
+			_, err := VerificarCancelacion([]byte(strings.Replace(datos, caso.viejo, caso.nuevo, 1)))
+			if caso.esperado == nil && err != nil || caso.esperado != nil && !errors.Is(err, caso.esperado) {
+				t.Errorf("se esperaba %v, se obtuvo %v", caso.esperado, err)
+			}
+		})
+	}
+}
+
+func TestFirmarCancelacion(t *testing.T) {
+	csd := csdPrueba(t, "des3.key")
+	solicitud := Cancelacion{Fecha: "2026-10-17T12:05:00", RFCEmisor: "AAA010101AAA", Folios: []FolioCancelacion{
+		{UUID: "ED1752FE-E865-4FF2-BFE1-0F552E770DC9", Motivo: "01", FolioSustitucion: "75D992A1-43D4-4845-A495-D75919C39B90"},
+	}}
+	firmada, err := csd.FirmarCancelacion(solicitud)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := VerificarCancelacion(firmada)
+	if err != nil {
+		t.Fatalf("VerificarCancelacion: %v", err)
+	}
+	if c.Fecha != solicitud.Fecha || len(c.Folios) != 1 || c.Folios[0] != solicitud.Folios[0] {
+		t.Errorf("solicitud leída %+v", c)
+	}
+	emisor := "OU=Matriz,2.5.4.45=#0C1C" + strings.ToUpper(hex.EncodeToString([]byte("AAA010101AAA / PERJ800101AB1"))) + ",CN=EMPRESA PRUEBA"
+	if !strings.Contains(string(firmada), "<X509IssuerName>"+emisor+"</X509IssuerName>") {
+		t.Errorf("X509IssuerName no está en formato RFC 2253, se esperaba %s:\n%s", emisor, firmada)
+	}
+}
+
+func TestNombreRFC2253(t *testing.T) {
+	correo, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("a@b.mx")})
+	nombre := pkix.RDNSequence{
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 6}, Value: "MX"}},
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 10}, Value: "SERVICIO DE ADMINISTRACION TRIBUTARIA"}},
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 9}, Value: "Av. Hidalgo 77, Col. Guerrero"}, {Type: asn1.ObjectIdentifier{2, 5, 4, 17}, Value: "06370"}},
+		{{Type: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}, Value: asn1.RawValue{FullBytes: correo}}},
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 3}, Value: "#AC <pruebas> "}},
+	}
+	// DER ordena los miembros de un RDN con varios valores, por lo que el código postal queda antes que la calle.
+	der, err := asn1.Marshal(nombre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obtenido, err := nombreRFC2253(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperado := `CN=\#AC \<pruebas\>\ ,1.2.840.113549.1.9.1=#16066140622E6D78,2.5.4.17=#13053036333730+STREET=Av. Hidalgo 77\, Col. Guerrero,O=SERVICIO DE ADMINISTRACION TRIBUTARIA,C=MX`
+	if obtenido != esperado {
+		t.Errorf("se esperaba\n  %s\nse obtuvo\n  %s", esperado, obtenido)
+	}
+}
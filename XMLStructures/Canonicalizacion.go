@@ -0,0 +1,246 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Forma canónica C14N 1.0 para firmas XMLDSig
+*
+*
+****************************************************************************************************************************************/
+
+// nsXML Espacio de nombres del prefijo xml, que nunca se declara.
+const nsXML = "http://www.w3.org/XML/1998/namespace"
+
+// marcoC14N Estado de cada elemento abierto al canonicalizar.
+type marcoC14N struct {
+	nombre       string            // Nombre con prefijo tal como aparece en el documento.
+	enAlcance    map[string]string // Prefijo → espacio de nombres en alcance en el elemento; "" es el espacio por omisión.
+	atributosXML []xml.Attr        // Atributos xml:* en alcance, que hereda el ápice de un subconjunto.
+	salida       bool              // El elemento pertenece al subconjunto que se escribe.
+	excluido     bool              // El elemento o un ancestro es el elemento excluido.
+	renderizado  map[string]string // Declaraciones en efecto en la salida dentro del elemento; nil si no se escribió.
+}
+
+// canonicalizar Devuelve la forma canónica C14N 1.0 inclusiva sin comentarios (https://www.w3.org/TR/2001/REC-xml-c14n-20010315) de un subconjunto de datos. Si incluir no está vacío, el subconjunto es el primer elemento con ese nombre y sus descendientes, y el ápice declara todos los espacios de nombres en alcance y hereda los atributos xml:* de sus ancestros; si está vacío es el documento completo. Los elementos llamados excluir y sus descendientes se omiten, como en la transformación de firma envolvente.
+//
+// encoding/xml ya resuelve referencias de caracteres y secciones CDATA y normaliza los fines de línea; no normaliza los espacios dentro de los valores de atributos ni expande entidades de una DTD, por lo que los documentos que dependen de ello no se canonicalizan correctamente.
+func canonicalizar(datos []byte, incluir, excluir xml.Name) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(datos))
+	var (
+		b         bytes.Buffer
+		pila      []*marcoC14N
+		raizVista bool
+		listo     bool
+	)
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return b.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var padre *marcoC14N
+		if len(pila) > 0 {
+			padre = pila[len(pila)-1]
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			marco := &marcoC14N{nombre: nombreConPrefijo(v.Name), enAlcance: map[string]string{}}
+			var atributos []xml.Attr
+			if padre != nil {
+				for p, ns := range padre.enAlcance {
+					marco.enAlcance[p] = ns
+				}
+				marco.atributosXML = padre.atributosXML
+				marco.excluido = padre.excluido
+			}
+			for _, a := range v.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					marco.enAlcance[a.Name.Local] = a.Value
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					marco.enAlcance[""] = a.Value
+				default:
+					atributos = append(atributos, a)
+				}
+			}
+			nombre := xml.Name{Space: marco.enAlcance[v.Name.Space], Local: v.Name.Local}
+			marco.excluido = marco.excluido || nombre == excluir
+			apice := false
+			switch {
+			case marco.excluido:
+			case padre != nil && padre.salida:
+				marco.salida = true
+			case incluir.Local == "" && padre == nil, !listo && nombre == incluir:
+				marco.salida, apice, listo = true, true, true
+			}
+			if marco.salida {
+				escribirInicioC14N(&b, marco, padre, atributos, apice && padre != nil)
+			}
+			for _, a := range atributos {
+				if a.Name.Space == "xml" {
+					marco.atributosXML = append(append([]xml.Attr(nil), marco.atributosXML...), a)
+				}
+			}
+			pila = append(pila, marco)
+			raizVista = true
+		case xml.EndElement:
+			if padre == nil {
+				return nil, &xml.SyntaxError{Msg: "etiqueta de cierre sin elemento abierto"}
+			}
+			if padre.salida {
+				b.WriteString("</" + padre.nombre + ">")
+			}
+			pila = pila[:len(pila)-1]
+		case xml.CharData:
+			if padre != nil && padre.salida {
+				b.WriteString(textoCanonico(string(v)))
+			}
+		case xml.ProcInst:
+			switch {
+			case v.Target == "xml":
+			case padre != nil && padre.salida:
+				b.WriteString(instruccionC14N(v))
+			case padre == nil && incluir.Local == "" && !raizVista:
+				b.WriteString(instruccionC14N(v) + "\n")
+			case padre == nil && incluir.Local == "":
+				b.WriteString("\n" + instruccionC14N(v))
+			}
+		}
+	}
+}
+
+// escribirInicioC14N Escribe la etiqueta de inicio del elemento marco con las declaraciones de espacios de nombres que no están ya en efecto en la salida del padre y los atributos en el orden de C14N. heredarXML indica que el elemento es el ápice de un subconjunto y recibe los atributos xml:* de sus ancestros.
+func escribirInicioC14N(b *bytes.Buffer, marco, padre *marcoC14N, atributos []xml.Attr, heredarXML bool) {
+	var previo map[string]string
+	if padre != nil && padre.salida {
+		previo = padre.renderizado
+	}
+	marco.renderizado = map[string]string{}
+	for p, ns := range previo {
+		marco.renderizado[p] = ns
+	}
+	var prefijos []string
+	for p, ns := range marco.enAlcance {
+		if p == "xml" {
+			continue
+		}
+		anterior, ok := previo[p]
+		if p == "" && ns == "" && anterior == "" || ok && anterior == ns {
+			continue
+		}
+		prefijos = append(prefijos, p)
+		marco.renderizado[p] = ns
+	}
+	sort.Strings(prefijos)
+
+	if heredarXML {
+		for _, heredado := range marco.atributosXML {
+			presente := false
+			for _, a := range atributos {
+				presente = presente || a.Name == heredado.Name
+			}
+			if !presente {
+				atributos = append(atributos, heredado)
+			}
+		}
+	}
+	espacio := func(a xml.Attr) string {
+		switch a.Name.Space {
+		case "":
+			return ""
+		case "xml":
+			return nsXML
+		}
+		return marco.enAlcance[a.Name.Space]
+	}
+	atributos = append([]xml.Attr(nil), atributos...)
+	sort.SliceStable(atributos, func(i, j int) bool {
+		if ei, ej := espacio(atributos[i]), espacio(atributos[j]); ei != ej {
+			return ei < ej
+		}
+		return atributos[i].Name.Local < atributos[j].Name.Local
+	})
+
+	b.WriteString("<" + marco.nombre)
+	for _, p := range prefijos {
+		nombre := "xmlns"
+		if p != "" {
+			nombre += ":" + p
+		}
+		b.WriteString(" " + nombre + `="` + atributoCanonico(marco.enAlcance[p]) + `"`)
+	}
+	for _, a := range atributos {
+		b.WriteString(" " + nombreConPrefijo(a.Name) + `="` + atributoCanonico(a.Value) + `"`)
+	}
+	b.WriteString(">")
+}
+
+// nombreConPrefijo Devuelve el nombre de un token leído con RawToken tal como aparece en el documento.
+func nombreConPrefijo(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// instruccionC14N Devuelve la forma canónica de una instrucción de procesamiento.
+func instruccionC14N(p xml.ProcInst) string {
+	if len(p.Inst) == 0 {
+		return "<?" + p.Target + "?>"
+	}
+	return "<?" + p.Target + " " + strings.TrimLeft(string(p.Inst), " \t\n") + "?>"
+}
+
+// atributoCanonico Escapa el valor de un atributo según C14N.
+func atributoCanonico(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '"':
+			b.WriteString("&quot;")
+		case '\t':
+			b.WriteString("&#x9;")
+		case '\n':
+			b.WriteString("&#xA;")
+		case '\r':
+			b.WriteString("&#xD;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// textoCanonico Escapa el contenido de texto de un elemento según C14N.
+func textoCanonico(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '\r':
+			b.WriteString("&#xD;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,330 @@
+// Package catalogos Catálogos del SAT (catCFDI) usados por el CFDI 3.3, incluidos en el binario con su versión y las fechas de vigencia de cada clave.
+//
+// Los datos viven en datos/, un archivo JSON por catálogo, y se regeneran a partir del libro catCFDI publicado por el SAT con el comando ActualizadorCatalogos. Los catálogos marcados como incompletos (c_ClaveProdServ, c_ClaveUnidad y c_CodigoPostal) sólo contienen las claves de uso más común; en ellos una clave ausente no significa que sea inválida y deben regenerarse con ActualizadorCatalogos para tenerlos completos. c_Moneda y c_Pais están completos, pero se tomaron de ISO 4217 e ISO 3166-1, en los que se basan, y no de un libro catCFDI: no tienen Version ni la columna PorcentajeVariacion, y todas sus claves son vigentes desde 2017-01-01.
+package catalogos
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // ZonaHoraria no debe depender de la base de zonas del sistema.
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Catálogos del SAT
+*
+*
+****************************************************************************************************************************************/
+
+// Nombres de los catálogos incluidos.
+const (
+	FormaPago         = "c_FormaPago"
+	MetodoPago        = "c_MetodoPago"
+	TipoDeComprobante = "c_TipoDeComprobante"
+	TipoRelacion      = "c_TipoRelacion"
+	UsoCFDI           = "c_UsoCFDI"
+	RegimenFiscal     = "c_RegimenFiscal"
+	Impuesto          = "c_Impuesto"
+	TipoFactor        = "c_TipoFactor"
+	TasaOCuota        = "c_TasaOCuota"
+	Moneda            = "c_Moneda"
+	Pais              = "c_Pais"
+	ClaveUnidad       = "c_ClaveUnidad"
+	ClaveProdServ     = "c_ClaveProdServ"
+	CodigoPostal      = "c_CodigoPostal"
+)
+
+// FormatoFecha Formato de InicioVigencia y FinVigencia.
+const FormatoFecha = "2006-01-02"
+
+// Clave Renglón de un catálogo.
+type Clave struct {
+	Clave          string            `json:"clave"`                 // Clave tal como se escribe en el comprobante.
+	Descripcion    string            `json:"descripcion"`           // Descripción oficial de la clave.
+	InicioVigencia string            `json:"inicioVigencia"`        // Fecha desde la que la clave puede usarse, AAAA-MM-DD.
+	FinVigencia    string            `json:"finVigencia,omitempty"` // Fecha a partir de la cual la clave deja de poder usarse, AAAA-MM-DD; vacía si sigue vigente.
+	Fisica         bool              `json:"fisica,omitempty"`      // Aplica a personas físicas (c_UsoCFDI y c_RegimenFiscal).
+	Moral          bool              `json:"moral,omitempty"`       // Aplica a personas morales (c_UsoCFDI y c_RegimenFiscal).
+	Datos          map[string]string `json:"datos,omitempty"`       // Columnas propias del catálogo, p.ej. Decimales y PorcentajeVariacion en c_Moneda o Estado en c_CodigoPostal.
+}
+
+// Vigente Indica si la clave puede usarse en un comprobante expedido en fecha. Una fecha cero no se revisa.
+func (k Clave) Vigente(fecha time.Time) bool {
+	if fecha.IsZero() {
+		return true
+	}
+	dia := fecha.Format(FormatoFecha)
+	if k.InicioVigencia != "" && dia < k.InicioVigencia {
+		return false
+	}
+	return k.FinVigencia == "" || dia < k.FinVigencia
+}
+
+// Catalogo Catálogo completo con su versión.
+type Catalogo struct {
+	Nombre   string  `json:"nombre"`            // Nombre del catálogo, p.ej. c_UsoCFDI.
+	Version  string  `json:"version,omitempty"` // Fecha de publicación del catCFDI del que provienen los datos; vacía si no se generó con ActualizadorCatalogos.
+	Completo bool    `json:"completo"`          // Indica si el catálogo contiene todas las claves publicadas por el SAT.
+	Claves   []Clave `json:"claves"`            // Claves en el orden del catálogo original.
+
+	indice map[string]int
+}
+
+// Buscar Devuelve la clave del catálogo.
+func (c *Catalogo) Buscar(clave string) (Clave, bool) {
+	i, ok := c.indice[clave]
+	if !ok {
+		return Clave{}, false
+	}
+	return c.Claves[i], true
+}
+
+// Vigente Indica si clave existe en el catálogo y puede usarse en fecha.
+func (c *Catalogo) Vigente(clave string, fecha time.Time) bool {
+	k, ok := c.Buscar(clave)
+	return ok && k.Vigente(fecha)
+}
+
+// Admite Igual que Vigente, pero en un catálogo incompleto una clave ausente se considera válida.
+func (c *Catalogo) Admite(clave string, fecha time.Time) bool {
+	k, ok := c.Buscar(clave)
+	if !ok {
+		return !c.Completo
+	}
+	return k.Vigente(fecha)
+}
+
+//go:embed datos/*.json
+var datos embed.FS
+
+var (
+	cargarUnaVez sync.Once
+	catalogos    map[string]*Catalogo
+)
+
+// cargar Lee los catálogos incluidos. Un archivo mal formado es un error de compilación del paquete, por lo que provoca pánico.
+func cargar() {
+	catalogos = map[string]*Catalogo{}
+	archivos, err := datos.ReadDir("datos")
+	if err != nil {
+		panic(err)
+	}
+	for _, archivo := range archivos {
+		contenido, err := datos.ReadFile(path.Join("datos", archivo.Name()))
+		if err != nil {
+			panic(err)
+		}
+		c, err := Leer(contenido)
+		if err != nil {
+			panic(fmt.Sprintf("catalogos: %s: %v", archivo.Name(), err))
+		}
+		catalogos[c.Nombre] = c
+	}
+}
+
+// Leer Interpreta un catálogo en el formato JSON de datos/.
+func Leer(contenido []byte) (*Catalogo, error) {
+	c := &Catalogo{}
+	if err := json.Unmarshal(contenido, c); err != nil {
+		return nil, err
+	}
+	c.indice = make(map[string]int, len(c.Claves))
+	for i, k := range c.Claves {
+		if _, repetida := c.indice[k.Clave]; repetida {
+			return nil, fmt.Errorf("clave %q repetida en %s", k.Clave, c.Nombre)
+		}
+		c.indice[k.Clave] = i
+	}
+	return c, nil
+}
+
+// Obtener Devuelve el catálogo nombre, p.ej. Obtener(UsoCFDI).
+func Obtener(nombre string) (*Catalogo, bool) {
+	cargarUnaVez.Do(cargar)
+	c, ok := catalogos[nombre]
+	return c, ok
+}
+
+// Nombres Devuelve los nombres de los catálogos incluidos, ordenados.
+func Nombres() []string {
+	cargarUnaVez.Do(cargar)
+	nombres := make([]string, 0, len(catalogos))
+	for nombre := range catalogos {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// Descripcion Devuelve la descripción de clave en el catálogo nombre, o una cadena vacía si no existe.
+func Descripcion(nombre, clave string) string {
+	c, ok := Obtener(nombre)
+	if !ok {
+		return ""
+	}
+	k, _ := c.Buscar(clave)
+	return k.Descripcion
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Reglas que dependen de los catálogos
+*
+*
+****************************************************************************************************************************************/
+
+// Persona Tipo de persona de un contribuyente.
+type Persona int
+
+// Tipos de persona, según la longitud del RFC.
+const (
+	PersonaDesconocida Persona = iota
+	PersonaFisica              // RFC de 13 caracteres, incluidos los genéricos XAXX010101000 y XEXX010101000.
+	PersonaMoral               // RFC de 12 caracteres.
+)
+
+// TipoPersona Deduce el tipo de persona a partir de la longitud del RFC.
+func TipoPersona(rfc string) Persona {
+	switch len([]rune(rfc)) {
+	case 13:
+		return PersonaFisica
+	case 12:
+		return PersonaMoral
+	}
+	return PersonaDesconocida
+}
+
+// AplicaA Indica si la clave puede usarse para el tipo de persona p.
+func (k Clave) AplicaA(p Persona) bool {
+	switch p {
+	case PersonaFisica:
+		return k.Fisica
+	case PersonaMoral:
+		return k.Moral
+	}
+	return false
+}
+
+// UsoCFDIPermitido Indica si el uso existe en c_UsoCFDI y corresponde al tipo de persona del receptor con RFC rfc.
+func UsoCFDIPermitido(uso, rfc string) bool {
+	return aplicaClave(UsoCFDI, uso, rfc)
+}
+
+// RegimenFiscalPermitido Indica si el régimen existe en c_RegimenFiscal y corresponde al tipo de persona del emisor con RFC rfc.
+func RegimenFiscalPermitido(regimen, rfc string) bool {
+	return aplicaClave(RegimenFiscal, regimen, rfc)
+}
+
+// aplicaClave Indica si clave existe en el catálogo nombre y aplica al tipo de persona de rfc.
+func aplicaClave(nombre, clave, rfc string) bool {
+	c, ok := Obtener(nombre)
+	if !ok {
+		return false
+	}
+	k, ok := c.Buscar(clave)
+	return ok && k.AplicaA(TipoPersona(rfc))
+}
+
+// DecimalesMoneda Devuelve los decimales que c_Moneda asigna a moneda, si la moneda está en el catálogo.
+func DecimalesMoneda(moneda string) (int32, bool) {
+	c, ok := Obtener(Moneda)
+	if !ok {
+		return 0, false
+	}
+	k, ok := c.Buscar(moneda)
+	if !ok {
+		return 0, false
+	}
+	var decimales int32
+	if _, err := fmt.Sscan(k.Datos["Decimales"], &decimales); err != nil {
+		return 0, false
+	}
+	return decimales, true
+}
+
+// TasaOCuotaValida Indica si valor es una tasa o cuota permitida por c_TasaOCuota para el impuesto (ISR, IVA o IEPS, o su clave 001, 002 o 003) y tipo de factor dados, como traslado o como retención según traslado. Los renglones de tipo Fijo exigen el valor exacto y los de tipo Rango cualquier valor entre su mínimo y su máximo.
+func TasaOCuotaValida(impuesto, tipoFactor string, traslado bool, valor string, fecha time.Time) bool {
+	c, ok := Obtener(TasaOCuota)
+	if !ok {
+		return false
+	}
+	if nombre := Descripcion(Impuesto, impuesto); nombre != "" {
+		impuesto = nombre
+	}
+	v, ok := new(big.Rat).SetString(valor)
+	if !ok {
+		return false
+	}
+	columna := "Retencion"
+	if traslado {
+		columna = "Traslado"
+	}
+	for _, k := range c.Claves {
+		if k.Datos["Impuesto"] != impuesto || k.Datos["Factor"] != tipoFactor || k.Datos[columna] != "Sí" || !k.Vigente(fecha) {
+			continue
+		}
+		maximo, ok := new(big.Rat).SetString(k.Datos["Maximo"])
+		if !ok {
+			continue
+		}
+		if k.Datos["Tipo"] == "Fijo" {
+			if v.Cmp(maximo) == 0 {
+				return true
+			}
+			continue
+		}
+		minimo, ok := new(big.Rat).SetString(k.Datos["Minimo"])
+		if ok && v.Cmp(minimo) >= 0 && v.Cmp(maximo) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// zonasHorarias Zona IANA de cada huso horario de c_CodigoPostal. Un renglón con Estado sólo aplica a ese estado; el primer renglón que coincide gana. Los husos «en Frontera» siguen el horario de verano de Estados Unidos.
+var zonasHorarias = []struct {
+	huso   string // Texto contenido en la columna HusoHorario, sin distinguir mayúsculas.
+	estado string // Clave de c_Estado, o vacío para cualquiera.
+	zona   string
+}{
+	{"noroeste", "", "America/Tijuana"},
+	{"pacífico en frontera", "BCN", "America/Tijuana"},
+	{"pacífico en frontera", "CHH", "America/Ciudad_Juarez"},
+	{"pacífico", "BCN", "America/Tijuana"},
+	{"pacífico", "SON", "America/Hermosillo"},
+	{"pacífico", "", "America/Mazatlan"},
+	{"centro en frontera", "CHH", "America/Ojinaga"},
+	{"centro en frontera", "", "America/Matamoros"},
+	{"centro", "", "America/Mexico_City"},
+	{"sureste", "", "America/Cancun"},
+}
+
+// ZonaHoraria Devuelve la zona horaria del código postal según la columna HusoHorario de c_CodigoPostal, o false si el código postal no está en el catálogo o no tiene huso horario.
+func ZonaHoraria(codigoPostal string) (*time.Location, bool) {
+	c, ok := Obtener(CodigoPostal)
+	if !ok {
+		return nil, false
+	}
+	k, ok := c.Buscar(codigoPostal)
+	if !ok {
+		return nil, false
+	}
+	huso := strings.ToLower(k.Datos["HusoHorario"])
+	for _, z := range zonasHorarias {
+		if !strings.Contains(huso, z.huso) || z.estado != "" && z.estado != k.Datos["Estado"] {
+			continue
+		}
+		zona, err := time.LoadLocation(z.zona)
+		return zona, err == nil
+	}
+	return nil, false
+}
@@ -0,0 +1,102 @@
+package catalogos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestZonaHoraria(t *testing.T) {
+	casos := []struct {
+		cp     string
+		fecha  string
+		offset int // Horas respecto de UTC en fecha.
+	}{
+		{"01000", "2026-10-17T12:00:00", -6},
+		{"01000", "2021-07-01T12:00:00", -5}, // Horario de verano, vigente hasta 2022.
+		{"22000", "2026-07-01T12:00:00", -7},
+		{"22000", "2026-12-01T12:00:00", -8},
+		{"83000", "2026-07-01T12:00:00", -7},
+		{"82000", "2026-07-01T12:00:00", -7},
+		{"77500", "2026-12-01T12:00:00", -5},
+		{"88500", "2026-07-01T12:00:00", -5},
+		{"88500", "2026-12-01T12:00:00", -6},
+	}
+	for _, c := range casos {
+		zona, ok := ZonaHoraria(c.cp)
+		if !ok {
+			t.Errorf("%s: sin zona horaria", c.cp)
+			continue
+		}
+		fecha, err := time.ParseInLocation("2006-01-02T15:04:05", c.fecha, zona)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, segundos := fecha.Zone(); segundos != c.offset*3600 {
+			t.Errorf("%s en %s: UTC%+d, se esperaba UTC%+d", c.cp, c.fecha, segundos/3600, c.offset)
+		}
+	}
+	if _, ok := ZonaHoraria("99999"); ok {
+		t.Error("un código postal ausente devolvió zona horaria")
+	}
+}
+
+func TestTasaOCuotaCompleto(t *testing.T) {
+	c, ok := Obtener(TasaOCuota)
+	if !ok || !c.Completo {
+		t.Fatal("c_TasaOCuota debe estar completo")
+	}
+	fecha := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	casos := []struct {
+		impuesto, factor string
+		traslado         bool
+		valor            string
+		valida           bool
+	}{
+		{"002", "Tasa", true, "0.160000", true},
+		{"002", "Tasa", true, "0.080000", true},
+		{"002", "Tasa", true, "0.150000", false},
+		{"002", "Tasa", false, "0.106666", true},
+		{"001", "Tasa", false, "0.100000", true},
+		{"001", "Tasa", true, "0.100000", false},
+		{"003", "Tasa", true, "0.265000", true},
+		{"003", "Cuota", true, "5.000000", true},
+		{"IEPS", "Tasa", true, "0.030000", false},
+	}
+	for _, c := range casos {
+		if got := TasaOCuotaValida(c.impuesto, c.factor, c.traslado, c.valor, fecha); got != c.valida {
+			t.Errorf("TasaOCuotaValida(%s, %s, %v, %s) = %v", c.impuesto, c.factor, c.traslado, c.valor, got)
+		}
+	}
+}
+
+func TestMonedaPaisCompletos(t *testing.T) {
+	fecha := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for _, nombre := range []string{Moneda, Pais} {
+		c, ok := Obtener(nombre)
+		if !ok || !c.Completo {
+			t.Fatalf("%s debe estar completo", nombre)
+		}
+		if c.Version != "" {
+			t.Errorf("%s no proviene de un libro catCFDI, pero tiene Version %q", nombre, c.Version)
+		}
+		if c.Admite("ZZ", fecha) {
+			t.Errorf("%s admite una clave ausente", nombre)
+		}
+	}
+	casos := []struct {
+		moneda    string
+		decimales int32
+	}{
+		{"MXN", 2}, {"HUF", 2}, {"JPY", 0}, {"KRW", 0}, {"XXX", 0}, {"KWD", 3}, {"TND", 3}, {"CLF", 4},
+	}
+	for _, c := range casos {
+		if d, ok := DecimalesMoneda(c.moneda); !ok || d != c.decimales {
+			t.Errorf("DecimalesMoneda(%s) = %d, %v; se esperaba %d", c.moneda, d, ok, c.decimales)
+		}
+	}
+	for _, pais := range []string{"MEX", "USA", "NZL", "ZAF", "ZZZ"} {
+		if c, _ := Obtener(Pais); !c.Vigente(pais, fecha) {
+			t.Errorf("c_Pais no contiene %s", pais)
+		}
+	}
+}
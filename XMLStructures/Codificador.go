@@ -0,0 +1,209 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Escritura de comprobantes
+*
+*
+****************************************************************************************************************************************/
+
+// ErrCaracterInvalido Indica que un valor contiene caracteres que no pueden representarse en un documento XML 1.0.
+var ErrCaracterInvalido = errors.New("el valor contiene caracteres no permitidos en XML")
+
+// ErrorCodificacion Error producido al serializar un comprobante. Indica el nodo y, en su caso, el atributo que no pudo serializarse.
+type ErrorCodificacion struct {
+	Nodo     string // Ruta del nodo, p.ej. /cfdi:Comprobante/cfdi:Emisor.
+	Atributo string // Nombre del atributo, vacío cuando el error corresponde al nodo completo.
+	Err      error  // Error original.
+}
+
+func (e *ErrorCodificacion) Error() string {
+	if e.Atributo == "" {
+		return fmt.Sprintf("xmlstructures: %s: %v", e.Nodo, e.Err)
+	}
+	return fmt.Sprintf("xmlstructures: %s/@%s: %v", e.Nodo, e.Atributo, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorCodificacion) Unwrap() error { return e.Err }
+
+// Encoder Escribe comprobantes CFDI 3.3 en un io.Writer, precedidos de la declaración XML.
+type Encoder struct {
+	w       io.Writer
+	prefijo string
+	sangria string
+}
+
+// NewEncoder Crea un Encoder que escribe en w. Por omisión la salida es compacta.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Indent Hace que el Encoder escriba cada nodo en su propia línea, comenzando con prefix y con indent repetido según la profundidad. Con ambos vacíos la salida vuelve a ser compacta.
+func (e *Encoder) Indent(prefix, indent string) {
+	e.prefijo = prefix
+	e.sangria = indent
+}
+
+// Encode Escribe el comprobante completo. Si algún valor no puede serializarse devuelve un *ErrorCodificacion y no escribe nada; si falla la escritura en w, el *ErrorCodificacion del nodo raíz envuelve el error de w.
+func (e *Encoder) Encode(c Comprobante) error {
+	if err := revisarValores(reflect.ValueOf(c), "/cfdi:Comprobante"); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent(e.prefijo, e.sangria)
+	if err := enc.Encode(c); err != nil {
+		return &ErrorCodificacion{Nodo: "/cfdi:Comprobante", Err: err}
+	}
+	if err := enc.Close(); err != nil {
+		return &ErrorCodificacion{Nodo: "/cfdi:Comprobante", Err: err}
+	}
+	if _, err := buf.WriteTo(e.w); err != nil {
+		return &ErrorCodificacion{Nodo: "/cfdi:Comprobante", Err: err}
+	}
+	return nil
+}
+
+// Marshal Devuelve el comprobante serializado en forma compacta.
+func Marshal(c Comprobante) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalIndent Igual que Marshal pero con cada nodo en su propia línea, usando prefix e indent como en Encoder.Indent.
+func MarshalIndent(c Comprobante, prefix, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Indent(prefix, indent)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// revisarValores Recorre v siguiendo las etiquetas xml del modelo y verifica que cada valor de texto pueda escribirse en el documento, para informar con precisión el nodo y atributo que fallan.
+func revisarValores(v reflect.Value, ruta string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return revisarValores(v.Elem(), ruta)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := revisarValores(v.Index(i), fmt.Sprintf("%s[%d]", ruta, i+1)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Complex64, reflect.Complex128:
+		return &ErrorCodificacion{Nodo: ruta, Err: &xml.UnsupportedTypeError{Type: v.Type()}}
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		campo := t.Field(i)
+		if campo.PkgPath != "" || campo.Name == "XMLName" {
+			continue
+		}
+		etiqueta := campo.Tag.Get("xml")
+		if etiqueta == "-" {
+			continue
+		}
+		opciones := strings.Split(etiqueta, ",")
+		nombre := opciones[0]
+		if nombre == "" {
+			nombre = campo.Name
+		}
+		esAtributo := false
+		for _, o := range opciones[1:] {
+			esAtributo = esAtributo || o == "attr"
+		}
+		f := v.Field(i)
+		if etiqueta == "" && f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Interface {
+			// Complementos: cada elemento es un nodo con el nombre de su propio XMLName.
+			for j := 0; j < f.Len(); j++ {
+				if err := revisarValores(f.Index(j), ruta+"/"+nombreNodo(f.Index(j), nombre)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if esAtributo {
+			for f.Kind() == reflect.Ptr && !f.IsNil() {
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.String && !textoValido(f.String()) {
+				return &ErrorCodificacion{Nodo: ruta, Atributo: nombre, Err: ErrCaracterInvalido}
+			}
+			continue
+		}
+		rutaCampo := ruta + "/" + nombre
+		if f.Kind() == reflect.String {
+			if !textoValido(f.String()) {
+				return &ErrorCodificacion{Nodo: rutaCampo, Err: ErrCaracterInvalido}
+			}
+			continue
+		}
+		if err := revisarValores(f, rutaCampo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nombreNodo Devuelve el nombre de la etiqueta del campo XMLName de v, o porOmision si no lo tiene.
+func nombreNodo(v reflect.Value, porOmision string) string {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return porOmision
+	}
+	if campo, ok := v.Type().FieldByName("XMLName"); ok {
+		if nombre := strings.Split(campo.Tag.Get("xml"), ",")[0]; nombre != "" {
+			return nombre
+		}
+	}
+	return porOmision
+}
+
+// textoValido Indica si s es UTF-8 válido y sólo contiene caracteres permitidos por XML 1.0.
+func textoValido(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r == 0x09 || r == 0x0A || r == 0x0D:
+		case r >= 0x20 && r <= 0xD7FF:
+		case r >= 0xE000 && r <= 0xFFFD:
+		case r >= 0x10000 && r <= 0x10FFFF:
+		default:
+			return false
+		}
+	}
+	return true
+}
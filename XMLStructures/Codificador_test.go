@@ -0,0 +1,88 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+// escritorFallido io.Writer que siempre devuelve errEscritura.
+type escritorFallido struct{}
+
+var errEscritura = errors.New("disco lleno")
+
+func (escritorFallido) Write(p []byte) (int, error) { return 0, errEscritura }
+
+// complementoInvalido Complemento con un campo que encoding/xml no sabe serializar.
+type complementoInvalido struct {
+	XMLName xml.Name       `xml:"prueba:Invalido"`
+	Valores map[string]int `xml:"prueba:Valores"`
+}
+
+func TestMarshalErrores(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		cambio   func(c *Comprobante)
+		nodo     string
+		atributo string
+		esperado error
+	}{
+		{"carácter de control en un atributo", func(c *Comprobante) { c.Emisor.Nombre = "ESCUELA\x01KEMPER" }, "/cfdi:Comprobante/cfdi:Emisor", "Nombre", ErrCaracterInvalido},
+		{"UTF-8 inválido en un concepto", func(c *Comprobante) { c.Conceptos.Conceptos[1].Descripcion = "Caf\xe9" }, "/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[2]", "Descripcion", ErrCaracterInvalido},
+		{"carácter fuera de XML 1.0", func(c *Comprobante) { c.Receptor.Nombre = "PUBLICO\ufffe" }, "/cfdi:Comprobante/cfdi:Receptor", "Nombre", ErrCaracterInvalido},
+		{"atributo del nodo raíz", func(c *Comprobante) { c.Serie = "A\x00" }, "/cfdi:Comprobante", "Serie", ErrCaracterInvalido},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			c := comprobantePrueba(2)
+			caso.cambio(&c)
+			var buf bytes.Buffer
+			err := NewEncoder(&buf).Encode(c)
+			var e *ErrorCodificacion
+			if !errors.As(err, &e) {
+				t.Fatalf("se esperaba un *ErrorCodificacion, se obtuvo %v", err)
+			}
+			if e.Nodo != caso.nodo || e.Atributo != caso.atributo {
+				t.Errorf("se esperaba %s/@%s, se obtuvo %s/@%s", caso.nodo, caso.atributo, e.Nodo, e.Atributo)
+			}
+			if errors.Unwrap(err) != caso.esperado {
+				t.Errorf("errors.Unwrap: se esperaba %v, se obtuvo %v", caso.esperado, errors.Unwrap(err))
+			}
+			if buf.Len() != 0 {
+				t.Errorf("se escribió un documento incompleto:\n%s", buf.Bytes())
+			}
+			if datos, err := Marshal(c); datos != nil || !errors.As(err, &e) {
+				t.Errorf("Marshal devolvió %q, %v", datos, err)
+			}
+		})
+	}
+}
+
+func TestMarshalTipoNoSoportado(t *testing.T) {
+	c := comprobantePrueba(1)
+	c.AgregarComplemento(&complementoInvalido{Valores: map[string]int{"uno": 1}})
+	_, err := Marshal(c)
+	var e *ErrorCodificacion
+	if !errors.As(err, &e) || e.Atributo != "" {
+		t.Fatalf("se esperaba un *ErrorCodificacion de nodo, se obtuvo %v", err)
+	}
+	var tipo *xml.UnsupportedTypeError
+	if !errors.As(errors.Unwrap(err), &tipo) {
+		t.Errorf("errors.Unwrap: se esperaba *xml.UnsupportedTypeError, se obtuvo %v", errors.Unwrap(err))
+	}
+	if e.Nodo != "/cfdi:Comprobante/cfdi:Complemento/prueba:Invalido/prueba:Valores" {
+		t.Errorf("nodo %s", e.Nodo)
+	}
+}
+
+func TestEncoderEscritorFallido(t *testing.T) {
+	err := NewEncoder(escritorFallido{}).Encode(comprobantePrueba(1))
+	if !errors.Is(err, errEscritura) {
+		t.Fatalf("se esperaba el error del escritor, se obtuvo %v", err)
+	}
+	var e *ErrorCodificacion
+	if !errors.As(err, &e) || e.Nodo != "/cfdi:Comprobante" || e.Atributo != "" || errors.Unwrap(err) != errEscritura {
+		t.Errorf("se esperaba un *ErrorCodificacion del nodo raíz que envuelva el error del escritor, se obtuvo %#v", err)
+	}
+}
@@ -0,0 +1,412 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Registro de complementos
+*
+*
+****************************************************************************************************************************************/
+
+// TipoComplemento Describe un complemento que puede incluirse en cfdi:Complemento o en cfdi:ComplementoConcepto. El nodo raíz del tipo Go debe etiquetarse como Prefijo:Nombre, igual que el resto del modelo.
+type TipoComplemento struct {
+	Namespace        string             // Espacio de nombres del complemento, p.ej. http://www.sat.gob.mx/Pagos.
+	Prefijo          string             // Prefijo con el que se escribe el complemento, p.ej. pago10.
+	Nombre           string             // Nombre local del nodo raíz del complemento, p.ej. Pagos.
+	Esquema          string             // Ubicación del XSD que se agrega a xsi:schemaLocation.
+	DeclaracionLocal bool               // Indica que el propio nodo declara su espacio de nombres y esquema, como el Timbre Fiscal Digital, en lugar de hacerlo cfdi:Comprobante.
+	Nuevo            func() interface{} // Devuelve un puntero a un valor vacío del tipo Go del complemento.
+}
+
+var (
+	complementosMu        sync.RWMutex
+	complementosPorNombre = map[string]TipoComplemento{}
+	complementosPorTipo   = map[reflect.Type]TipoComplemento{}
+)
+
+func init() {
+	RegistrarComplemento(TipoComplemento{
+		Namespace:        NamespaceTFD,
+		Prefijo:          "tfd",
+		Nombre:           "TimbreFiscalDigital",
+		Esquema:          EsquemaTFD,
+		DeclaracionLocal: true,
+		Nuevo:            func() interface{} { return new(CFDITimbre) },
+	})
+	RegistrarComplemento(TipoComplemento{
+		Namespace: NamespacePagos,
+		Prefijo:   "pago10",
+		Nombre:    "Pagos",
+		Esquema:   EsquemaPagos,
+		Nuevo:     func() interface{} { return new(Pagos) },
+	})
+}
+
+// RegistrarComplemento Registra un tipo de complemento para que el Encoder declare su espacio de nombres y Unmarshal lo decodifique en su tipo Go. Un registro posterior con el mismo Prefijo:Nombre reemplaza al anterior.
+func RegistrarComplemento(t TipoComplemento) {
+	complementosMu.Lock()
+	defer complementosMu.Unlock()
+	complementosPorNombre[t.Prefijo+":"+t.Nombre] = t
+	complementosPorTipo[tipoBase(reflect.TypeOf(t.Nuevo()))] = t
+	prefijosCanonicos[t.Namespace] = t.Prefijo
+}
+
+// complementoPorNombre Devuelve el complemento registrado para el nombre canónico name.
+func complementoPorNombre(name xml.Name) (TipoComplemento, bool) {
+	complementosMu.RLock()
+	defer complementosMu.RUnlock()
+	if name.Space != "" {
+		return TipoComplemento{}, false
+	}
+	t, ok := complementosPorNombre[name.Local]
+	return t, ok
+}
+
+// complementoPorValor Devuelve el complemento registrado para el tipo Go de v.
+func complementoPorValor(v interface{}) (TipoComplemento, bool) {
+	complementosMu.RLock()
+	defer complementosMu.RUnlock()
+	t, ok := complementosPorTipo[tipoBase(reflect.TypeOf(v))]
+	return t, ok
+}
+
+// prefijoCanonico Devuelve el prefijo canónico registrado para el espacio de nombres ns.
+func prefijoCanonico(ns string) (string, bool) {
+	complementosMu.RLock()
+	defer complementosMu.RUnlock()
+	prefijo, ok := prefijosCanonicos[ns]
+	return prefijo, ok
+}
+
+// tipoBase Devuelve t sin indirecciones de puntero.
+func tipoBase(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Timbre Devuelve el Timbre Fiscal Digital del comprobante, o nil si aún no ha sido timbrado.
+func (c Comprobante) Timbre() *CFDITimbre {
+	if c.Complemento == nil {
+		return nil
+	}
+	for _, v := range c.Complemento.Complementos {
+		switch t := v.(type) {
+		case *CFDITimbre:
+			return t
+		case CFDITimbre:
+			return &t
+		}
+	}
+	return nil
+}
+
+// AgregarComplemento Agrega v a cfdi:Complemento, creando el nodo si no existe.
+func (c *Comprobante) AgregarComplemento(v interface{}) {
+	if c.Complemento == nil {
+		c.Complemento = &CFDIComplemento{}
+	}
+	c.Complemento.Complementos = append(c.Complemento.Complementos, v)
+}
+
+// namespacesComplementos Devuelve las declaraciones xmlns y los pares de xsi:schemaLocation que el nodo raíz debe incluir para los complementos registrados presentes en c. De los complementos desconocidos sólo se incluye el par de schemaLocation leído del documento original, ya que el nodo declara sus propios espacios de nombres.
+func namespacesComplementos(c Comprobante) ([]xml.Attr, []string) {
+	var elementos []interface{}
+	for _, concepto := range c.Conceptos.Conceptos {
+		if concepto.ComplementoConcepto != nil {
+			elementos = append(elementos, concepto.ComplementoConcepto.Complementos...)
+		}
+	}
+	if c.Complemento != nil {
+		elementos = append(elementos, c.Complemento.Complementos...)
+	}
+	var namespaces []xml.Attr
+	var ubicaciones []string
+	declarados := map[string]bool{}
+	for _, v := range elementos {
+		if desconocido, ok := v.(*ComplementoDesconocido); ok {
+			if desconocido.Esquema != "" && !declarados[desconocido.XMLName.Space] {
+				declarados[desconocido.XMLName.Space] = true
+				ubicaciones = append(ubicaciones, desconocido.XMLName.Space, desconocido.Esquema)
+			}
+			continue
+		}
+		t, ok := complementoPorValor(v)
+		if !ok || t.DeclaracionLocal || declarados[t.Namespace] {
+			continue
+		}
+		declarados[t.Namespace] = true
+		namespaces = append(namespaces, xml.Attr{Name: xml.Name{Local: "xmlns:" + t.Prefijo}, Value: t.Namespace})
+		if t.Esquema != "" {
+			ubicaciones = append(ubicaciones, t.Namespace, t.Esquema)
+		}
+	}
+	return namespaces, ubicaciones
+}
+
+// MarshalXML Escribe cada complemento con el nombre de su propio nodo raíz.
+func (c CFDIComplemento) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "cfdi:Complemento"}
+	return codificarComplementos(e, start, c.Complementos)
+}
+
+// UnmarshalXML Decodifica cada complemento en su tipo registrado, o en un *ComplementoDesconocido.
+func (c *CFDIComplemento) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	c.XMLName = start.Name
+	var err error
+	c.Complementos, err = decodificarComplementos(d)
+	return err
+}
+
+// MarshalXML Escribe cada complemento con el nombre de su propio nodo raíz.
+func (c ComplementoConcepto) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "cfdi:ComplementoConcepto"}
+	return codificarComplementos(e, start, c.Complementos)
+}
+
+// UnmarshalXML Decodifica cada complemento en su tipo registrado, o en un *ComplementoDesconocido.
+func (c *ComplementoConcepto) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	c.XMLName = start.Name
+	var err error
+	c.Complementos, err = decodificarComplementos(d)
+	return err
+}
+
+// codificarComplementos Escribe start seguido de cada uno de los elementos.
+func codificarComplementos(e *xml.Encoder, start xml.StartElement, elementos []interface{}) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, v := range elementos {
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// decodificarComplementos Lee los nodos hijos hasta el cierre del nodo actual. Los complementos no registrados se devuelven como *ComplementoDesconocido sin contenido; Unmarshal lo completa después con el nodo original.
+func decodificarComplementos(d *xml.Decoder) ([]interface{}, error) {
+	var elementos []interface{}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			tipo, ok := complementoPorNombre(v.Name)
+			if !ok {
+				elementos = append(elementos, &ComplementoDesconocido{XMLName: v.Name})
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			elemento := tipo.Nuevo()
+			if err := d.DecodeElement(elemento, &v); err != nil {
+				return nil, err
+			}
+			elementos = append(elementos, elemento)
+		case xml.EndElement:
+			return elementos, nil
+		}
+	}
+}
+
+// ComplementoDesconocido Complemento cuyo tipo no está registrado. Conserva el nodo original para poder escribirlo de nuevo.
+type ComplementoDesconocido struct {
+	XMLName    xml.Name   // Espacio de nombres y nombre local del nodo.
+	XML        []byte     // Nodo completo tal como aparece en el documento original.
+	Namespaces []xml.Attr // Declaraciones xmlns heredadas de nodos ancestros, de las que puede depender XML. Se declaran en el nodo al escribirlo.
+	Esquema    string     // Ubicación del esquema de su espacio de nombres en el xsi:schemaLocation del nodo raíz original; el Encoder la vuelve a incluir en el del comprobante.
+}
+
+// MarshalXML Escribe de nuevo el nodo original conservando sus prefijos. De Namespaces sólo se declaran los prefijos que el nodo usa, y el texto formado únicamente por espacios se omite para que la indentación sea la del Encoder.
+func (c ComplementoDesconocido) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(c.XML) == 0 {
+		return fmt.Errorf("xmlstructures: complemento %s no registrado y sin contenido", c.XMLName.Local)
+	}
+	usados, err := prefijosUsados(c.XML)
+	if err != nil {
+		return err
+	}
+	d := xml.NewDecoder(bytes.NewReader(c.XML))
+	raiz := true
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			v = v.Copy()
+			v.Name = nombreCrudo(v.Name)
+			declarados := map[string]bool{}
+			for i := range v.Attr {
+				v.Attr[i].Name = nombreCrudo(v.Attr[i].Name)
+				declarados[v.Attr[i].Name.Local] = true
+			}
+			if raiz {
+				for _, ns := range c.Namespaces {
+					if !declarados[ns.Name.Local] && usados[strings.TrimPrefix(ns.Name.Local, "xmlns:")] {
+						v.Attr = append(v.Attr, ns)
+					}
+				}
+				raiz = false
+			}
+			t = v
+		case xml.EndElement:
+			v.Name = nombreCrudo(v.Name)
+			t = v
+		case xml.CharData:
+			if len(bytes.TrimSpace(v)) == 0 {
+				continue
+			}
+			t = v.Copy()
+		case xml.ProcInst, xml.Directive:
+			continue
+		default:
+			t = xml.CopyToken(t)
+		}
+		if err := e.EncodeToken(t); err != nil {
+			return err
+		}
+	}
+}
+
+// prefijosUsados Devuelve los prefijos de los nodos y atributos de data, leído con RawToken.
+func prefijosUsados(data []byte) (map[string]bool, error) {
+	usados := map[string]bool{}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return usados, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := t.(xml.StartElement); ok {
+			usados[v.Name.Space] = true
+			for _, a := range v.Attr {
+				if a.Name.Space != "xmlns" {
+					usados[a.Name.Space] = true
+				}
+			}
+		}
+	}
+}
+
+// nombreCrudo Convierte un nombre leído con RawToken, cuyo Space es el prefijo, a la forma prefijo:local que escribe el Encoder.
+func nombreCrudo(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+// ubicacionesEsquema Devuelve la ubicación de cada espacio de nombres en el xsi:schemaLocation de attr.
+func ubicacionesEsquema(attr []xml.Attr) map[string]string {
+	esquemas := map[string]string{}
+	for _, a := range attr {
+		if a.Name.Space != NamespaceXSI || a.Name.Local != "schemaLocation" {
+			continue
+		}
+		pares := strings.Fields(a.Value)
+		for i := 0; i+1 < len(pares); i += 2 {
+			esquemas[pares[i]] = pares[i+1]
+		}
+	}
+	return esquemas
+}
+
+// completarDesconocidos Asigna a los complementos desconocidos de c, en orden de documento, los nodos originales capturados durante la lectura.
+func completarDesconocidos(c *Comprobante, crudos []ComplementoDesconocido) {
+	var listas [][]interface{}
+	for _, concepto := range c.Conceptos.Conceptos {
+		if concepto.ComplementoConcepto != nil {
+			listas = append(listas, concepto.ComplementoConcepto.Complementos)
+		}
+	}
+	if c.Complemento != nil {
+		listas = append(listas, c.Complemento.Complementos)
+	}
+	for _, lista := range listas {
+		for _, v := range lista {
+			desconocido, ok := v.(*ComplementoDesconocido)
+			if !ok || len(crudos) == 0 {
+				continue
+			}
+			desconocido.XML = crudos[0].XML
+			desconocido.Namespaces = crudos[0].Namespaces
+			desconocido.Esquema = crudos[0].Esquema
+			crudos = crudos[1:]
+		}
+	}
+}
+
+// leerDesconocidos Devuelve, en orden de documento, los nodos hijos de cfdi:Complemento y cfdi:ComplementoConcepto cuyo tipo no está registrado, junto con las declaraciones de espacio de nombres heredadas de sus ancestros.
+func leerDesconocidos(data []byte) ([]ComplementoDesconocido, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var crudos []ComplementoDesconocido
+	var ruta []string
+	var namespaces [][]xml.Attr
+	esquemas := map[string]string{}
+	for {
+		antes := d.InputOffset()
+		t, err := d.Token()
+		if err == io.EOF {
+			return crudos, nil
+		}
+		if err != nil {
+			return nil, &ErrorLectura{Ruta: "/" + strings.Join(ruta, "/"), Err: err}
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			padre := ""
+			heredados := []xml.Attr(nil)
+			if len(ruta) > 0 {
+				padre = ruta[len(ruta)-1]
+				heredados = namespaces[len(namespaces)-1]
+			}
+			nombre := nombreCanonico(v.Name).Local
+			if len(ruta) == 0 {
+				esquemas = ubicacionesEsquema(v.Attr)
+			}
+			if padre == "cfdi:Complemento" || padre == "cfdi:ComplementoConcepto" {
+				if _, ok := complementoPorNombre(nombreCanonico(v.Name)); !ok {
+					if err := d.Skip(); err != nil {
+						return nil, &ErrorLectura{Ruta: "/" + strings.Join(append(ruta, nombre), "/"), Err: err}
+					}
+					crudos = append(crudos, ComplementoDesconocido{
+						XMLName:    nombreCanonico(v.Name),
+						XML:        append([]byte(nil), data[antes:d.InputOffset()]...),
+						Namespaces: append([]xml.Attr(nil), heredados...),
+						Esquema:    esquemas[v.Name.Space],
+					})
+					continue
+				}
+			}
+			ruta = append(ruta, nombre)
+			namespaces = append(namespaces, declaracionesHeredables(append([]xml.Attr(nil), heredados...), v.Attr))
+		case xml.EndElement:
+			ruta = ruta[:len(ruta)-1]
+			namespaces = namespaces[:len(namespaces)-1]
+		}
+	}
+}
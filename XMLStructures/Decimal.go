@@ -0,0 +1,365 @@
+package xmlstructures
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"./Catalogos"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Importes en punto fijo
+*
+*
+****************************************************************************************************************************************/
+
+// ErrDecimalInvalido Indica que un texto no representa un número decimal válido para el comprobante.
+var ErrDecimalInvalido = errors.New("número decimal inválido")
+
+// ErrDivisionEntreCero Indica que Quo recibió un divisor igual a cero.
+var ErrDivisionEntreCero = errors.New("división de Decimal entre cero")
+
+// Decimal Número en punto fijo usado para importes, cantidades, tasas y tipos de cambio. A diferencia de float64 conserva exactamente los decimales capturados y se escribe sin notación exponencial. El valor cero equivale a 0.
+type Decimal struct {
+	Valor  int64 // Valor sin punto decimal, p.ej. 1234 con Escala 2 representa 12.34.
+	Escala int32 // Número de dígitos después del punto decimal. Sólo es negativa en resultados que no caben en int64 ni sin decimales; p.ej. 5 con Escala -20 representa 5 × 10^20.
+}
+
+// NewDecimal Devuelve el decimal valor × 10^-escala.
+func NewDecimal(valor int64, escala int32) Decimal {
+	return Decimal{Valor: valor, Escala: escala}
+}
+
+// ParseDecimal Interpreta s en la forma [-]dígitos[.dígitos], que es la del patrón tdCFDI:t_Importe más el signo menos que admiten los cálculos: no acepta el signo +, ni un punto sin dígitos a alguno de sus lados (5. o .5). La escala del resultado es el número de decimales escritos en s.
+func ParseDecimal(s string) (Decimal, error) {
+	texto := strings.TrimSpace(s)
+	negativo := strings.HasPrefix(texto, "-")
+	if negativo {
+		texto = texto[1:]
+	}
+	entero, fraccion := texto, ""
+	i := strings.IndexByte(texto, '.')
+	if i >= 0 {
+		entero, fraccion = texto[:i], texto[i+1:]
+	}
+	if entero == "" || (i >= 0 && fraccion == "") {
+		return Decimal{}, fmt.Errorf("%q: %w", s, ErrDecimalInvalido)
+	}
+	for _, r := range entero + fraccion {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("%q: %w", s, ErrDecimalInvalido)
+		}
+	}
+	v, ok := new(big.Int).SetString("0"+entero+fraccion, 10)
+	if !ok || !v.IsInt64() {
+		return Decimal{}, fmt.Errorf("%q: %w", s, ErrDecimalInvalido)
+	}
+	d := Decimal{Valor: v.Int64(), Escala: int32(len(fraccion))}
+	if negativo {
+		d.Valor = -d.Valor
+	}
+	return d, nil
+}
+
+// String Devuelve el decimal con exactamente Escala dígitos después del punto.
+func (d Decimal) String() string {
+	if d.Escala <= 0 {
+		return escalar(d.big(), -d.Escala).String()
+	}
+	v := d.big()
+	signo := ""
+	if v.Sign() < 0 {
+		signo = "-"
+		v.Neg(v)
+	}
+	digitos := v.String()
+	if len(digitos) <= int(d.Escala) {
+		digitos = strings.Repeat("0", int(d.Escala)-len(digitos)+1) + digitos
+	}
+	corte := len(digitos) - int(d.Escala)
+	return signo + digitos[:corte] + "." + digitos[corte:]
+}
+
+// Float64 Devuelve el valor aproximado como float64, sólo para presentación; los cálculos deben hacerse con Decimal.
+func (d Decimal) Float64() float64 {
+	var f float64
+	if d.Escala < 0 {
+		f, _ = new(big.Float).SetInt(escalar(d.big(), -d.Escala)).Float64()
+		return f
+	}
+	f, _ = new(big.Rat).SetFrac(d.big(), potencia10(d.Escala)).Float64()
+	return f
+}
+
+// Sign Devuelve -1, 0 o +1 según el signo de d.
+func (d Decimal) Sign() int {
+	switch {
+	case d.Valor < 0:
+		return -1
+	case d.Valor > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero Indica si d vale cero, sin importar su escala.
+func (d Decimal) IsZero() bool {
+	return d.Valor == 0
+}
+
+// Cmp Compara d con b y devuelve -1, 0 o +1. 1.5 y 1.50 son iguales.
+func (d Decimal) Cmp(b Decimal) int {
+	x, y := alinear(d, b)
+	return x.Cmp(y)
+}
+
+// Neg Devuelve -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{Valor: -d.Valor, Escala: d.Escala}
+}
+
+// Add Devuelve d + b con la mayor de las dos escalas.
+func (d Decimal) Add(b Decimal) Decimal {
+	x, y := alinear(d, b)
+	return desdeBig(x.Add(x, y), maximo(d.Escala, b.Escala))
+}
+
+// Sub Devuelve d - b con la mayor de las dos escalas.
+func (d Decimal) Sub(b Decimal) Decimal {
+	x, y := alinear(d, b)
+	return desdeBig(x.Sub(x, y), maximo(d.Escala, b.Escala))
+}
+
+// Mul Devuelve d × b con la suma de las dos escalas. Si el resultado exacto no cabe, se redondea a la mayor escala que lo permita.
+func (d Decimal) Mul(b Decimal) Decimal {
+	v := new(big.Int).Mul(d.big(), b.big())
+	return desdeBig(v, d.Escala+b.Escala)
+}
+
+// Quo Devuelve d ÷ b redondeado a escala decimales, o ErrDivisionEntreCero si b es cero.
+func (d Decimal) Quo(b Decimal, escala int32) (Decimal, error) {
+	if b.IsZero() {
+		return Decimal{}, ErrDivisionEntreCero
+	}
+	// d/b = (d.Valor × 10^(escala+1+b.Escala-d.Escala)) / b.Valor, con un dígito extra para redondear.
+	num, den := d.big(), b.big()
+	if exponente := escala + 1 + b.Escala - d.Escala; exponente >= 0 {
+		num = escalar(num, exponente)
+	} else {
+		den = escalar(den, -exponente)
+	}
+	return desdeBig(num.Quo(num, den), escala+1).Round(escala), nil
+}
+
+// Round Devuelve d con exactamente escala decimales, redondeando la mitad hacia afuera del cero (1.005 → 1.01, -1.005 → -1.01), como lo indica el SAT.
+func (d Decimal) Round(escala int32) Decimal {
+	if escala >= d.Escala {
+		return desdeBig(new(big.Int).Mul(d.big(), potencia10(escala-d.Escala)), escala)
+	}
+	divisor := potencia10(d.Escala - escala)
+	q, r := new(big.Int).QuoRem(d.big(), divisor, new(big.Int))
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(divisor) >= 0 {
+		if d.Valor < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return desdeBig(q, escala)
+}
+
+// Truncate Devuelve d con a lo más escala decimales, descartando los sobrantes sin redondear.
+func (d Decimal) Truncate(escala int32) Decimal {
+	if escala >= d.Escala {
+		return d
+	}
+	return desdeBig(new(big.Int).Quo(d.big(), potencia10(d.Escala-escala)), escala)
+}
+
+// MarshalXMLAttr Escribe el decimal con su escala, sin notación exponencial.
+func (d Decimal) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: d.String()}, nil
+}
+
+// UnmarshalXMLAttr Lee el decimal conservando el número de decimales del documento.
+func (d *Decimal) UnmarshalXMLAttr(attr xml.Attr) error {
+	v, err := ParseDecimal(attr.Value)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// big Devuelve d.Valor como *big.Int.
+func (d Decimal) big() *big.Int {
+	return big.NewInt(d.Valor)
+}
+
+// alinear Devuelve los valores de a y b expresados en la mayor de sus escalas.
+func alinear(a, b Decimal) (*big.Int, *big.Int) {
+	escala := maximo(a.Escala, b.Escala)
+	x := new(big.Int).Mul(a.big(), potencia10(escala-a.Escala))
+	y := new(big.Int).Mul(b.big(), potencia10(escala-b.Escala))
+	return x, y
+}
+
+// desdeBig Construye el decimal v × 10^-escala. Si v no cabe en int64 reduce la escala redondeando, incluso por debajo de cero; esos valores exceden los 18 dígitos enteros de tdCFDI:t_Importe y Validate los reporta.
+func desdeBig(v *big.Int, escala int32) Decimal {
+	for !v.IsInt64() {
+		q, r := new(big.Int).QuoRem(v, big.NewInt(10), new(big.Int))
+		if r.Abs(r).Int64() >= 5 {
+			if v.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+		v, escala = q, escala-1
+	}
+	return Decimal{Valor: v.Int64(), Escala: escala}
+}
+
+// escalar Devuelve v × 10^n en un nuevo *big.Int.
+func escalar(v *big.Int, n int32) *big.Int {
+	return new(big.Int).Mul(v, potencia10(n))
+}
+
+// potencia10 Devuelve 10^n, o 1 si n no es positivo.
+func potencia10(n int32) *big.Int {
+	if n <= 0 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// maximo Devuelve el mayor de a y b.
+func maximo(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Decimales por moneda y por atributo
+*
+*
+****************************************************************************************************************************************/
+
+// Decimales que el estándar fija para atributos que no dependen de la moneda.
+const (
+	DecimalesTasaOCuota = 6 // TasaOCuota de los traslados y retenciones.
+	DecimalesCantidad   = 6 // Máximo de decimales de Cantidad.
+	DecimalesImporte    = 6 // Máximo de decimales de tdCFDI:t_Importe en los conceptos y sus impuestos.
+	DecimalesTipoCambio = 6 // Máximo de decimales de TipoCambio.
+)
+
+// decimalesMoneda Decimales de las monedas que no usan dos, para cuando la moneda no está en el c_Moneda incluido en el paquete catalogos.
+var decimalesMoneda = map[string]int32{
+	"BHD": 3, "BIF": 0, "BOV": 2, "BYR": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3,
+	"JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0,
+	"VND": 0, "VUV": 0, "XAF": 0, "XAG": 0, "XAU": 0, "XBA": 0, "XBB": 0, "XBC": 0, "XBD": 0, "XDR": 0, "XOF": 0,
+	"XPD": 0, "XPF": 0, "XPT": 0, "XSU": 0, "XTS": 0, "XUA": 0, "XXX": 0,
+}
+
+// DecimalesMoneda Devuelve el número de decimales que el catálogo c_Moneda asigna a moneda. Los importes del nodo Comprobante y del nodo Impuestos deben escribirse con exactamente ese número de decimales.
+func DecimalesMoneda(moneda string) int32 {
+	if d, ok := catalogos.DecimalesMoneda(moneda); ok {
+		return d
+	}
+	if d, ok := decimalesMoneda[moneda]; ok {
+		return d
+	}
+	return 2
+}
+
+// ajustarDecimales Devuelve una copia de c en la que los totales del comprobante y del nodo Impuestos que tienen menos decimales que la moneda, y las tasas que tienen menos de seis, se completan con ceros. Nunca descarta decimales: un importe con más de los que admite su atributo se escribe tal cual y Validate lo reporta. c debe venir de omitirNodosVacios, que ya copia los nodos Impuestos.
+func ajustarDecimales(c Comprobante) Comprobante {
+	moneda := DecimalesMoneda(c.Moneda)
+	c.SubTotal = completar(c.SubTotal, moneda)
+	c.Total = completar(c.Total, moneda)
+	c.Descuento = completarOpcional(c.Descuento, moneda)
+	if c.Impuestos != nil {
+		c.Impuestos.TotalImpuestosRetenidos = completarOpcional(c.Impuestos.TotalImpuestosRetenidos, moneda)
+		c.Impuestos.TotalImpuestosTrasladados = completarOpcional(c.Impuestos.TotalImpuestosTrasladados, moneda)
+		if c.Impuestos.Retenciones != nil {
+			retenciones := *c.Impuestos.Retenciones
+			retenciones.Retenciones = append([]CFDIRetencion(nil), retenciones.Retenciones...)
+			for i := range retenciones.Retenciones {
+				retenciones.Retenciones[i].Importe = completar(retenciones.Retenciones[i].Importe, moneda)
+			}
+			c.Impuestos.Retenciones = &retenciones
+		}
+		if c.Impuestos.Traslados != nil {
+			traslados := *c.Impuestos.Traslados
+			traslados.Traslados = append([]CFDITraslado(nil), traslados.Traslados...)
+			for i := range traslados.Traslados {
+				traslados.Traslados[i].TasaOCuota = completar(traslados.Traslados[i].TasaOCuota, DecimalesTasaOCuota)
+				traslados.Traslados[i].Importe = completar(traslados.Traslados[i].Importe, moneda)
+			}
+			c.Impuestos.Traslados = &traslados
+		}
+	}
+	conceptos := make([]CFDIConcepto, len(c.Conceptos.Conceptos))
+	for i, concepto := range c.Conceptos.Conceptos {
+		conceptos[i] = concepto
+		if concepto.Impuestos == nil {
+			continue
+		}
+		impuestos := *concepto.Impuestos
+		if impuestos.Traslados != nil {
+			traslados := *impuestos.Traslados
+			traslados.Traslados = append([]CFDIImpuestosTrasladoInner(nil), traslados.Traslados...)
+			for j := range traslados.Traslados {
+				traslados.Traslados[j].TasaOCuota = completarOpcional(traslados.Traslados[j].TasaOCuota, DecimalesTasaOCuota)
+			}
+			impuestos.Traslados = &traslados
+		}
+		if impuestos.Retenciones != nil {
+			retenciones := *impuestos.Retenciones
+			retenciones.Retenciones = append([]CFDIImpuestosRetencionInner(nil), retenciones.Retenciones...)
+			for j := range retenciones.Retenciones {
+				retenciones.Retenciones[j].TasaOCuota = completar(retenciones.Retenciones[j].TasaOCuota, DecimalesTasaOCuota)
+			}
+			impuestos.Retenciones = &retenciones
+		}
+		conceptos[i].Impuestos = &impuestos
+	}
+	c.Conceptos.Conceptos = conceptos
+	return c
+}
+
+// completar Devuelve d con escala decimales si tiene menos, agregando ceros; si tiene más lo devuelve sin cambios.
+func completar(d Decimal, escala int32) Decimal {
+	if d.Escala < escala {
+		return d.Round(escala)
+	}
+	return d
+}
+
+// completarOpcional Igual que completar para atributos opcionales; nil se conserva.
+func completarOpcional(d *Decimal, escala int32) *Decimal {
+	if d == nil {
+		return nil
+	}
+	v := completar(*d, escala)
+	return &v
+}
+
+// redondearOpcional Devuelve d con exactamente escala decimales; nil se conserva.
+func redondearOpcional(d *Decimal, escala int32) *Decimal {
+	if d == nil {
+		return nil
+	}
+	v := d.Round(escala)
+	return &v
+}
@@ -0,0 +1,110 @@
+package xmlstructures
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	validos := []struct {
+		texto  string
+		valor  int64
+		escala int32
+		salida string
+	}{
+		{"0", 0, 0, "0"},
+		{"5", 5, 0, "5"},
+		{"12.34", 1234, 2, "12.34"},
+		{"0.160000", 160000, 6, "0.160000"},
+		{"-1.5", -15, 1, "-1.5"},
+		{"007.10", 710, 2, "7.10"},
+		{" 3.3 ", 33, 1, "3.3"},
+		{"9223372036854.775807", math.MaxInt64, 6, "9223372036854.775807"},
+	}
+	for _, v := range validos {
+		d, err := ParseDecimal(v.texto)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", v.texto, err)
+			continue
+		}
+		if d.Valor != v.valor || d.Escala != v.escala || d.String() != v.salida {
+			t.Errorf("ParseDecimal(%q) = %+v (%s), se esperaba {%d %d} (%s)", v.texto, d, d, v.valor, v.escala, v.salida)
+		}
+	}
+	for _, texto := range []string{"", " ", "+5", "5.", ".5", ".", "-", "-.5", "1.2.3", "1e3", "1,000.00", "0x10", "--1", "9223372036854775808"} {
+		if d, err := ParseDecimal(texto); !errors.Is(err, ErrDecimalInvalido) {
+			t.Errorf("ParseDecimal(%q) = %s, %v; se esperaba ErrDecimalInvalido", texto, d, err)
+		}
+	}
+}
+
+func TestDecimalQuo(t *testing.T) {
+	casos := []struct {
+		a, b   string
+		escala int32
+		esp    string
+	}{
+		{"10", "3", 2, "3.33"},
+		{"2", "3", 2, "0.67"},
+		{"-2", "3", 2, "-0.67"},
+		{"1.005", "1", 2, "1.01"},
+		{"100", "0.054200", 6, "1845.018450"},
+		{"1", "0.000001", 0, "1000000"},
+	}
+	for _, c := range casos {
+		q, err := dec(c.a).Quo(dec(c.b), c.escala)
+		if err != nil || q.String() != c.esp {
+			t.Errorf("%s ÷ %s = %s, %v; se esperaba %s", c.a, c.b, q, err, c.esp)
+		}
+	}
+	if _, err := dec("1").Quo(dec("0.00"), 2); !errors.Is(err, ErrDivisionEntreCero) {
+		t.Errorf("la división entre cero devolvió %v", err)
+	}
+}
+
+func TestDecimalFueraDeRango(t *testing.T) {
+	grande := NewDecimal(math.MaxInt64, 0)
+	producto := grande.Mul(grande)
+	if producto.Escala >= 0 {
+		t.Fatalf("se esperaba escala negativa, se obtuvo %+v", producto)
+	}
+	if s := producto.String(); len(s) != 38 || !strings.HasPrefix(s, "850705917302346158") {
+		t.Errorf("MaxInt64² = %s", s)
+	}
+	if producto.Cmp(grande) <= 0 {
+		t.Errorf("MaxInt64² no es mayor que MaxInt64")
+	}
+	if f := producto.Float64(); f < 8.5e37 || f > 8.6e37 {
+		t.Errorf("Float64 = %g", f)
+	}
+	if suma := grande.Add(grande); suma.String() != "18446744073709551610" {
+		t.Errorf("MaxInt64 + MaxInt64 = %s", suma)
+	}
+}
+
+func TestMarshalNoRedondea(t *testing.T) {
+	c := comprobantePrueba(1)
+	c.Conceptos.Conceptos[0].ValorUnitario = dec("10.1234567")
+	c.SubTotal = dec("10.125")
+	c.Total = dec("11")
+	datos, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, atributo := range []string{`ValorUnitario="10.1234567"`, `SubTotal="10.125"`, `Total="11.00"`} {
+		if !strings.Contains(string(datos), atributo) {
+			t.Errorf("no se escribió %s:\n%s", atributo, datos)
+		}
+	}
+	reportados := codigos(Validate(c))
+	for _, esperado := range []string{
+		"CFDI33106 /cfdi:Comprobante/@SubTotal",
+		"XMLS001 /cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[1]/@ValorUnitario",
+	} {
+		if !reportados[esperado] {
+			t.Errorf("no se reportó %s; se reportaron %v", esperado, reportados)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Lectura de comprobantes existentes
+*
+*
+****************************************************************************************************************************************/
+
+// Errores devueltos al leer un comprobante, siempre envueltos en un *ErrorLectura.
+var (
+	ErrNoEsComprobante = errors.New("el nodo raíz no es cfdi:Comprobante")
+	ErrVersion         = errors.New("versión de comprobante no soportada, se esperaba 3.3")
+	ErrDocumentoVacio  = errors.New("el documento no contiene ningún nodo")
+)
+
+// prefijosCanonicos Prefijo con el que el modelo etiqueta los nodos y atributos de cada espacio de nombres conocido. RegistrarComplemento agrega los de cada complemento; se protege con complementosMu.
+var prefijosCanonicos = map[string]string{
+	NamespaceCFDI: "cfdi",
+	NamespaceTFD:  "tfd",
+	NamespaceXSI:  "xsi",
+}
+
+// ErrorLectura Error producido al leer un comprobante mal formado. Indica la ruta del nodo y la línea donde se detectó el problema.
+type ErrorLectura struct {
+	Ruta  string // Ruta del nodo que se estaba leyendo, p.ej. /cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto.
+	Linea int    // Línea del documento donde se detectó el error.
+	Err   error  // Error original.
+}
+
+func (e *ErrorLectura) Error() string {
+	if e.Ruta == "" {
+		return fmt.Sprintf("xmlstructures: línea %d: %v", e.Linea, e.Err)
+	}
+	return fmt.Sprintf("xmlstructures: %s (línea %d): %v", e.Ruta, e.Linea, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorLectura) Unwrap() error { return e.Err }
+
+// Decoder Lee comprobantes CFDI 3.3 desde un io.Reader.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder Crea un Decoder que lee de r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode Lee el documento completo de r y lo vacía en c.
+func (d *Decoder) Decode(c *Comprobante) error {
+	data, err := io.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(data, c)
+}
+
+// Unmarshal Vacía en c el comprobante contenido en data, descartando lo que c tuviera previamente. El contenido de cfdi:Addenda y los complementos no registrados se conservan byte por byte. Los nodos se reconocen por su espacio de nombres y no por el prefijo usado en el documento, por lo que se aceptan prefijos distintos de cfdi: y tfd:.
+func Unmarshal(data []byte, c *Comprobante) error {
+	*c = Comprobante{}
+	n := &normalizador{d: xml.NewDecoder(bytes.NewReader(data))}
+	d := xml.NewTokenDecoder(n)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return n.error(ErrDocumentoVacio)
+		}
+		if err != nil {
+			return n.error(err)
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "cfdi:Comprobante" {
+			return n.error(ErrNoEsComprobante)
+		}
+		if err := d.DecodeElement(c, &start); err != nil {
+			return n.error(err)
+		}
+		break
+	}
+	if c.Addenda != nil {
+		addenda, err := leerAddenda(data)
+		if err != nil {
+			return err
+		}
+		c.Addenda = addenda
+	}
+	if len(c.Conceptos.Conceptos) > 0 || c.Complemento != nil {
+		crudos, err := leerDesconocidos(data)
+		if err != nil {
+			return err
+		}
+		completarDesconocidos(c, crudos)
+	}
+	if c.Version != VersionCFDI {
+		return &ErrorLectura{Ruta: "/cfdi:Comprobante/@Version", Linea: n.lineaRaiz, Err: ErrVersion}
+	}
+	return nil
+}
+
+// normalizador Lector de tokens que reescribe los nombres de nodos y atributos con el prefijo canónico de su espacio de nombres, que es el que usan las etiquetas del modelo.
+type normalizador struct {
+	d         *xml.Decoder
+	ruta      []string
+	lineaRaiz int // Línea donde comienza el nodo raíz.
+}
+
+// Token Devuelve el siguiente token con los nombres normalizados.
+func (n *normalizador) Token() (xml.Token, error) {
+	linea, _ := n.d.InputPos()
+	t, err := n.d.Token()
+	if err != nil {
+		return t, err
+	}
+	switch v := t.(type) {
+	case xml.StartElement:
+		v.Name = nombreCanonico(v.Name)
+		attr := make([]xml.Attr, 0, len(v.Attr))
+		for _, a := range v.Attr {
+			if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+				continue
+			}
+			a.Name = nombreCanonico(a.Name)
+			attr = append(attr, a)
+		}
+		v.Attr = attr
+		if len(n.ruta) == 0 {
+			n.lineaRaiz = linea
+		}
+		n.ruta = append(n.ruta, v.Name.Local)
+		return v, nil
+	case xml.EndElement:
+		v.Name = nombreCanonico(v.Name)
+		n.ruta = n.ruta[:len(n.ruta)-1]
+		return v, nil
+	}
+	return t, nil
+}
+
+// error Envuelve err con la ruta y línea en que se encuentra la lectura.
+func (n *normalizador) error(err error) error {
+	linea, _ := n.d.InputPos()
+	if e, ok := err.(*xml.SyntaxError); ok {
+		linea = e.Line
+		err = errors.New(e.Msg)
+	}
+	ruta := ""
+	if len(n.ruta) > 0 {
+		ruta = "/" + strings.Join(n.ruta, "/")
+	}
+	return &ErrorLectura{Ruta: ruta, Linea: linea, Err: err}
+}
+
+// nombreCanonico Sustituye el espacio de nombres de name por su prefijo canónico. Los prefijos sin declarar que coinciden con uno canónico se conservan tal cual.
+func nombreCanonico(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	if prefijo, ok := prefijoCanonico(name.Space); ok {
+		return xml.Name{Local: prefijo + ":" + name.Local}
+	}
+	complementosMu.RLock()
+	defer complementosMu.RUnlock()
+	for _, prefijo := range prefijosCanonicos {
+		if name.Space == prefijo {
+			return xml.Name{Local: prefijo + ":" + name.Local}
+		}
+	}
+	return name
+}
@@ -0,0 +1,89 @@
+package xmlstructures
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// documentoPrueba Devuelve un comprobante timbrado mínimo cuya raíz es raiz, con las declaraciones xmlns. Los nodos hijos usan prefijo (vacío para el espacio de nombres por omisión) y el timbre el prefijo tfd.
+func documentoPrueba(raiz, xmlns, prefijo, tfd string) string {
+	if prefijo != "" {
+		prefijo += ":"
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<` + raiz + ` ` + xmlns + ` Version="3.3" Total="116.00">
+  <` + prefijo + `Emisor Rfc="AAA010101AAA" RegimenFiscal="601"/>
+  <` + prefijo + `Complemento>
+    <` + tfd + `:TimbreFiscalDigital xmlns:` + tfd + `="` + NamespaceTFD + `" Version="1.1" UUID="ED1752FE-E865-4FF2-BFE1-0F552E770DC9"/>
+  </` + prefijo + `Complemento>
+</` + raiz + `>`
+}
+
+func TestUnmarshalPrefijos(t *testing.T) {
+	casos := []struct {
+		nombre string
+		datos  string
+	}{
+		{"cfdi", documentoPrueba("cfdi:Comprobante", `xmlns:cfdi="`+NamespaceCFDI+`"`, "cfdi", "tfd")},
+		{"otro prefijo", documentoPrueba("c:Comprobante", `xmlns:c="`+NamespaceCFDI+`"`, "c", "t")},
+		{"espacio por omisión", documentoPrueba("Comprobante", `xmlns="`+NamespaceCFDI+`"`, "", "timbre")},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			var c Comprobante
+			if err := Unmarshal([]byte(caso.datos), &c); err != nil {
+				t.Fatal(err)
+			}
+			if c.Emisor.RFC != "AAA010101AAA" || c.Total.String() != "116.00" {
+				t.Errorf("comprobante leído %+v", c)
+			}
+			if c.Timbre() == nil || c.Timbre().UUID != "ED1752FE-E865-4FF2-BFE1-0F552E770DC9" {
+				t.Errorf("no se leyó el timbre: %+v", c.Complemento)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrores(t *testing.T) {
+	const declaracion = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+	const cfdi = `xmlns:cfdi="` + NamespaceCFDI + `"`
+	casos := []struct {
+		nombre   string
+		datos    string
+		esperado error // Error envuelto; nil cuando es un error de sintaxis.
+		ruta     string
+		linea    int
+	}{
+		{"vacío", "", ErrDocumentoVacio, "", 1},
+		{"sólo declaración", declaracion, ErrDocumentoVacio, "", 2},
+		{"basura antes de la raíz", declaracion + "<<cfdi:Comprobante/>", nil, "", 2},
+		{"nodo sin cerrar", declaracion + `<cfdi:Comprobante ` + cfdi + ` Version="3.3">` + "\n  <cfdi:Emisor Rfc=\"AAA010101AAA\">\n</cfdi:Comprobante>", nil, "/cfdi:Comprobante/cfdi:Emisor", 4},
+		{"atributo sin comillas", declaracion + `<cfdi:Comprobante ` + cfdi + ` Version="3.3">` + "\n\n  <cfdi:Emisor Rfc=AAA010101AAA/>\n</cfdi:Comprobante>", nil, "/cfdi:Comprobante", 4},
+		{"decimal inválido", declaracion + `<cfdi:Comprobante ` + cfdi + ` Version="3.3"` + "\n  Total=\"1,000.00\"/>", ErrDecimalInvalido, "/cfdi:Comprobante", 3},
+		{"otra raíz", declaracion + `<cfdi:Factura ` + cfdi + ` Version="3.3"/>`, ErrNoEsComprobante, "/cfdi:Factura", 2},
+		{"raíz sin espacio de nombres", `<Comprobante Version="3.3"/>`, ErrNoEsComprobante, "/Comprobante", 1},
+		{"CFDI 4.0", declaracion + `<cfdi:Comprobante xmlns:cfdi="http://www.sat.gob.mx/cfd/4" Version="4.0"/>`, ErrNoEsComprobante, "/Comprobante", 2},
+		{"versión 3.2", declaracion + "\n" + `<cfdi:Comprobante ` + cfdi + ` version="3.2"/>`, ErrVersion, "/cfdi:Comprobante/@Version", 3},
+		{"versión 4.0", declaracion + `<cfdi:Comprobante ` + cfdi + ` Version="4.0">` + "\n  <cfdi:Emisor Rfc=\"AAA010101AAA\"/>\n</cfdi:Comprobante>", ErrVersion, "/cfdi:Comprobante/@Version", 2},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			var c Comprobante
+			err := Unmarshal([]byte(caso.datos), &c)
+			var e *ErrorLectura
+			if !errors.As(err, &e) {
+				t.Fatalf("se esperaba un *ErrorLectura, se obtuvo %v", err)
+			}
+			if caso.esperado != nil && !errors.Is(err, caso.esperado) {
+				t.Errorf("se esperaba %v, se obtuvo %v", caso.esperado, e.Err)
+			}
+			if e.Ruta != caso.ruta || e.Linea != caso.linea {
+				t.Errorf("se esperaba %q en la línea %d, se obtuvo %q en la línea %d (%v)", caso.ruta, caso.linea, e.Ruta, e.Linea, err)
+			}
+			if e.Ruta != "" && !strings.Contains(err.Error(), e.Ruta) {
+				t.Errorf("el mensaje no indica la ruta: %v", err)
+			}
+		})
+	}
+}
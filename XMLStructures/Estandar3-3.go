@@ -21,11 +21,11 @@ type ComprobanteMgo struct {
 	NoCertificado     string    // Atributo requerido para expresar el número de serie del certificado de sello digital que ampara al comprobante, de acuerdo con el acuse correspondiente a 20 posiciones otorgado por el sistema del SAT. Pattern [0-9]{20} Req.
 	Certificado       string    // Atributo requerido que sirve para incorporar el certificado de sello digital que ampara al comprobante, como texto en formato base 64. Req.
 	CondicionesDePago string    // Atributo condicional para expresar las condiciones comerciales aplicables para el pago del comprobante fiscal digital por Internet. Este atributo puede ser condicionado mediante atributos o complementos. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü |Ü){1,1000} Opc.
-	SubTotal          float64   // Atributo requerido para representar la suma de los importes de los conceptos antes de descuentos e impuesto. No se permiten valores negativos. Req.
-	Descuento         float64   // Atributo condicional para representar el importe total de los descuentos aplicables antes de impuestos. No se permiten valores negativos. Se debe registrar cuando existan conceptos con descuento. Opc.
+	SubTotal          Decimal   // Atributo requerido para representar la suma de los importes de los conceptos antes de descuentos e impuesto. No se permiten valores negativos. Req.
+	Descuento         Decimal   // Atributo condicional para representar el importe total de los descuentos aplicables antes de impuestos. No se permiten valores negativos. Se debe registrar cuando existan conceptos con descuento. Opc.
 	Moneda            string    // Atributo requerido para identificar la clave de la moneda utilizada para expresar los montos, cuando se usa moneda nacional se registra MXN. Conforme con la especificación ISO 4217. catCFDI:c_Moneda Req.
-	TipoCambio        string    // Atributo condicional para representar el tipo de cambio conforme con la moneda usada. Es requerido cuando la clave de moneda es distinta de MXN y de XXX. Opc.
-	Total             float64   // Atributo requerido para representar la suma del subtotal, menos los descuentos aplicables, más las contribuciones recibidas. Req.
+	TipoCambio        Decimal   // Atributo condicional para representar el tipo de cambio conforme con la moneda usada. Es requerido cuando la clave de moneda es distinta de MXN y de XXX. Opc.
+	Total             Decimal   // Atributo requerido para representar la suma del subtotal, menos los descuentos aplicables, más las contribuciones recibidas. Req.
 	TipoDeComprobante string    // Atributo requerido para expresar la clave del efecto del comprobante fiscal para el contribuyente emisor. Req
 	MetodoPago        string    // Atributo condicional para precisar la clave del método de pago que aplica para este comprobante fiscal digital por Internet, conforme al Artículo 29-A fracción VII incisos a y b del CFF.Opc.
 	LugarExpedicion   string    // Atributo requerido para incorporar el código postal del lugar de expedición del comprobante (domicilio de la matriz o de la sucursal). Req.
@@ -35,6 +35,7 @@ type ComprobanteMgo struct {
 	CFDIReceptorMgo
 	CFDIConceptosMgo
 	CFDIImpuestosMgo
+	Cancelacion CFDICancelacionMgo // Seguimiento de la cancelación del comprobante; vacío mientras no se solicite.
 }
 
 /*****************************************************************************************************************************************
@@ -101,20 +102,24 @@ type CFDIConceptosMgo struct {
 type CFDIConceptoMgo struct {
 	ClaveProdServ    string  // Atributo requerido para expresar la clave del producto o del servicio amparado por el presente concepto. Es requerido y deben utilizar las claves del catálogo de productos y servicios, cuando los conceptos que registren por sus actividades correspondan con dichos conceptos. c_ClaveProdServ Req.
 	NoIdentificacion string  // Atributo opcional para expresar el número de parte, identificador del producto o del servicio, la clave de producto o servicio, SKU o equivalente, propia de la operación del emisor, amparado por el presente concepto. Opcionalmente se puede utilizar claves del estándar GTIN. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,100}. Opc.
-	Cantidad         float64 // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por el presente concepto. decimales (6) Req.
+	Cantidad         Decimal // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por el presente concepto. decimales (6) Req.
 	ClaveUnidad      string  // Atributo requerido para precisar la clave de unidad de medida estandarizada aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. catCFDI:c_ClaveUnidad Req.
 	Unidad           string  // Atributo opcional para precisar la unidad de medida propia de la operación del emisor, aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,20}.Opc.
 	Descripcion      string  // Atributo requerido para precisar la descripción del bien o servicio cubierto por el presente concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,1000} Opc.
-	ValorUnitario    float64 // Atributo requerido para precisar el valor o precio unitario del bien o servicio cubierto por el presente concepto. tdCFDI:t_Importe Req.
-	Importe          float64 //Atributo requerido para precisar el importe total de los bienes o servicios del presente concepto. Debe ser equivalente al resultado de multiplicar la cantidad por el valor unitario expresado en el concepto. No se permiten valores negativos. tdCFDI:t_Importe Req.
-	Descuento        float64 // Atributo opcional para representar el importe de los descuentos aplicables al concepto. No se permiten valores negativos. tdCFDI:t_Importe Opc.
+	ValorUnitario    Decimal // Atributo requerido para precisar el valor o precio unitario del bien o servicio cubierto por el presente concepto. tdCFDI:t_Importe Req.
+	Importe          Decimal //Atributo requerido para precisar el importe total de los bienes o servicios del presente concepto. Debe ser equivalente al resultado de multiplicar la cantidad por el valor unitario expresado en el concepto. No se permiten valores negativos. tdCFDI:t_Importe Req.
+	Descuento        Decimal // Atributo opcional para representar el importe de los descuentos aplicables al concepto. No se permiten valores negativos. tdCFDI:t_Importe Opc.
 	CFDIImpuestosInnerMgo
+	InformacionAduanera []CFDIInformacionAduaneraMgo // Información aduanera, una por cada pedimento.
+	CuentaPredial       CFDICuentaPredialMgo         // Cuenta predial del inmueble.
+	ComplementoConcepto ComplementoConceptoMgo       // Complemento del concepto.
+	Parte               []CFDIParteMgo               // Partes o componentes del concepto.
 }
 
 // CFDIImpuestosInnerMgo Nodo opcional para capturar los impuestos aplicables al presente concepto. Cuando un concepto no registra un impuesto, implica que no es objeto del mismo.
 type CFDIImpuestosInnerMgo struct {
-	Traslados CFDIImpuestosTrasladosInnerMgo //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
-	Traslado  CFDIImpuestosTrasladosInnerMgo //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
+	Traslados   CFDIImpuestosTrasladosInnerMgo   //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
+	Retenciones CFDIImpuestosRetencionesInnerMgo //Nodo opcional para asentar los impuestos retenidos aplicables al presente concepto.
 }
 
 // CFDIImpuestosTrasladosInnerMgo Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
@@ -124,11 +129,11 @@ type CFDIImpuestosTrasladosInnerMgo struct {
 
 // CFDIImpuestosTrasladoInnerMgo Nodo requerido para asentar la información detallada de un traslado de impuestos aplicable al presente concepto.
 type CFDIImpuestosTrasladoInnerMgo struct {
-	Base       float64 // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
+	Base       Decimal // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
 	Impuesto   string  // Atributo requerido para señalar la clave del tipo de impuesto trasladado aplicable al concepto.
 	TipoFactor string  // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64 // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
-	Importe    float64 // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
+	TasaOCuota Decimal // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
+	Importe    Decimal // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
 }
 
 // CFDIImpuestosRetencionesInnerMgo Nodo opcional para asentar los impuestos retenidos aplicables al presente concepto.
@@ -138,11 +143,11 @@ type CFDIImpuestosRetencionesInnerMgo struct {
 
 // CFDIImpuestosRetencionInnerMgo Nodo requerido para asentar la información detallada de una retención de impuestos aplicable al presente concepto.
 type CFDIImpuestosRetencionInnerMgo struct {
-	Base       float64 // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
+	Base       Decimal // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
 	Impuesto   string  // Atributo requerido para señalar la clave del tipo de impuesto trasladado aplicable al concepto.
 	TipoFactor string  // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64 // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
-	Importe    float64 // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
+	TasaOCuota Decimal // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
+	Importe    Decimal // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
 }
 
 // CFDIInformacionAduanera Nodo opcional para introducir la información aduanera aplicable cuando se trate de ventas de primera mano de mercancías importadas o se trate de operaciones de comercio exterior con bienes o servicios.
@@ -157,7 +162,19 @@ type CFDICuentaPredialMgo struct {
 
 // ComplementoConcepto Nodo opcional donde se incluyen los nodos complementarios de extensión al concepto definidos por el SAT, de acuerdo con las disposiciones particulares para un sector o actividad específica.
 type ComplementoConceptoMgo struct {
-	Complemento interface{}
+	Complementos []interface{} // Complementos del concepto, en el orden en que aparecen en el documento.
+}
+
+// CFDIParteMgo Nodo opcional para expresar las partes o componentes que integran la totalidad del concepto expresado en el comprobante fiscal digital por Internet.
+type CFDIParteMgo struct {
+	ClaveProdServ       string                       // Atributo requerido para expresar la clave del producto o del servicio amparado por la presente parte. c_ClaveProdServ Req.
+	NoIdentificacion    string                       // Atributo opcional para expresar el número de serie, número de parte del bien o identificador del producto o del servicio amparado por la presente parte. Opc.
+	Cantidad            Decimal                      // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por la presente parte. decimales (6) Req.
+	Unidad              string                       // Atributo opcional para precisar la unidad de medida propia de la operación del emisor, aplicable para la cantidad expresada en la parte. Opc.
+	Descripcion         string                       // Atributo requerido para precisar la descripción del bien o servicio cubierto por la presente parte. Req.
+	ValorUnitario       Decimal                      // Atributo opcional para precisar el valor o precio unitario del bien o servicio cubierto por la presente parte. tdCFDI:t_Importe Opc.
+	Importe             Decimal                      // Atributo opcional para precisar el importe total de los bienes o servicios de la presente parte. tdCFDI:t_Importe Opc.
+	InformacionAduanera []CFDIInformacionAduaneraMgo // Información aduanera de la parte, una por cada pedimento.
 }
 
 /*****************************************************************************************************************************************
@@ -169,8 +186,8 @@ type ComplementoConceptoMgo struct {
 
 // CFDIImpuestosMgo Nodo condicional para expresar el resumen de los impuestos aplicables.
 type CFDIImpuestosMgo struct {
-	TotalImpuestosRetenidos   float64 // Atributo condicional para expresar el total de los impuestos retenidos que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos retenidos
-	TotalImpuestosTrasladados float64 // Atributo condicional para expresar el total de los impuestos trasladados que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos trasladados.
+	TotalImpuestosRetenidos   Decimal // Atributo condicional para expresar el total de los impuestos retenidos que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos retenidos
+	TotalImpuestosTrasladados Decimal // Atributo condicional para expresar el total de los impuestos trasladados que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos trasladados.
 	CFDIRetencionesMGO
 	CFDITrasladosMGO
 }
@@ -183,7 +200,7 @@ type CFDIRetencionesMGO struct {
 // CFDIRetencionMGO Nodo requerido para la información detallada de una retención de impuesto específico
 type CFDIRetencionMGO struct {
 	Impuesto string  // Atributo requerido para señalar la clave del tipo de impuesto retenido
-	Importe  float64 // Atributo requerido para señalar el monto del impuesto retenido. No se permiten valores negativos.
+	Importe  Decimal // Atributo requerido para señalar el monto del impuesto retenido. No se permiten valores negativos.
 }
 
 // CFDITrasladosMGO Nodo condicional para capturar los impuestos trasladados aplicables. Es requerido cuando en los conceptos se registre un impuesto trasladado.
@@ -195,8 +212,8 @@ type CFDITrasladosMGO struct {
 type CFDITrasladoMGO struct {
 	Impuesto   string  // Atributo requerido para señalar la clave del tipo de impuesto trasladado.
 	TipoFactor string  // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64 // Atributo requerido para señalar el valor de la tasa o cuota del impuesto que se traslada por los conceptos amparados en el comprobante.
-	Importe    float64 // Atributo requerido para señalar la suma del importe del impuesto trasladado, agrupado por impuesto, TipoFactor y TasaOCuota. No se permiten valores negativos.
+	TasaOCuota Decimal // Atributo requerido para señalar el valor de la tasa o cuota del impuesto que se traslada por los conceptos amparados en el comprobante.
+	Importe    Decimal // Atributo requerido para señalar la suma del importe del impuesto trasladado, agrupado por impuesto, TipoFactor y TasaOCuota. No se permiten valores negativos.
 }
 
 /*
@@ -204,7 +221,7 @@ type CFDITrasladoMGO struct {
 
 // CFDIComplementoMgo Nodo opcional donde se incluye el complemento Timbre Fiscal Digital de manera obligatoria y los nodos complementarios determinados por el SAT, de acuerdo con las disposiciones particulares para un sector o actividad específica.
 type CFDIComplementoMgo struct {
-	elemento interface{}
+	Complementos []interface{} // Complementos del comprobante, incluido el timbre, en el orden en que aparecen en el documento.
 }
 
 /*
@@ -212,17 +229,38 @@ type CFDIComplementoMgo struct {
 
 // CFDIAddendaMgo Nodo opcional para recibir las extensiones al presente formato que sean de utilidad al contribuyente. Para las reglas de uso del mismo, referirse al formato origen.
 type CFDIAddendaMgo struct {
-	elemento interface{}
+	Contenido string // XML de la addenda tal como se recibió o generó.
 }
 
-// CFDITimbreMGO esquema del timbre del cfd
+// CFDITimbreMGO Timbre Fiscal Digital 1.1 del comprobante.
 type CFDITimbreMGO struct {
-	Tfd              string `xml:"tfd,attr"`
-	SchemaLocation   string `xml:"schemaLocation,attr"`
-	SelloSAT         string `xml:"selloSAT,attr"`
-	NoCertificadoSAT string `xml:"noCertificadoSAT,attr"`
-	SelloCFD         string `xml:"selloCFD,attr"`
-	FechaTimbrado    string `xml:"FechaTimbrado,attr"`
-	UUID             string `xml:"UUID,attr"`
-	Version          string `xml:"version,attr"`
+	Version          string    // Versión del timbre, 1.1.
+	UUID             string    // Folio fiscal asignado al comprobante.
+	FechaTimbrado    time.Time // Fecha y hora de la generación del timbre.
+	RFCProvCertif    string    // RFC del proveedor de certificación que generó el timbre.
+	Leyenda          string    // Información que el SAT comunica a los usuarios del CFDI, si la hay.
+	SelloCFD         string    // Sello digital del comprobante que se timbró.
+	NoCertificadoSAT string    // Número de serie del certificado del SAT con que se generó SelloSAT.
+	SelloSAT         string    // Sello digital del timbre.
+}
+
+/*****************************************************************************************************************************************
+*
+*	Seccion referente a la cancelación del CFD
+*
+*
+*****************************************************************************************************************************************/
+
+// CFDICancelacionMgo Seguimiento de la solicitud de cancelación del comprobante y de su estado en el SAT.
+type CFDICancelacionMgo struct {
+	UUID               string    // Folio fiscal del comprobante cuya cancelación se solicitó.
+	Motivo             string    // Clave del motivo de cancelación: 01 a 04.
+	FolioSustitucion   string    // UUID del comprobante que sustituye al cancelado, con el motivo 01.
+	FechaSolicitud     time.Time // Fecha en que el SAT recibió la solicitud, según el acuse.
+	EstatusUUID        string    // Código del SAT para el folio en el acuse: 201 solicitud recibida, 202 previamente cancelado, 203 no corresponde al emisor, 205 no existe.
+	Estado             string    // Estado del comprobante en el SAT: Vigente, Cancelado o No Encontrado.
+	EsCancelable       string    // Cancelable sin aceptación, Cancelable con aceptación o No cancelable.
+	EstatusCancelacion string    // En proceso, Cancelado sin aceptación, Cancelado con aceptación, Plazo vencido o Solicitud rechazada.
+	FechaConsulta      time.Time // Fecha de la última consulta del estado en el SAT.
+	Acuse              []byte    // Acuse de cancelación firmado por el SAT, tal como lo devolvió el PAC.
 }
@@ -0,0 +1,126 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestGolden Lee cada testdata/*.xml y compara byte por byte la salida de MarshalIndent con testdata/*.golden. Los .xml no son muestras publicadas por el SAT: son comprobantes de prueba escritos como las generan los sistemas de facturación (atributos en otro orden, nodos vacíos autocerrados, tabuladores) con los RFC de prueba del SAT; las muestras oficiales pueden agregarse a testdata junto con su .golden. Los .golden se escriben y corrigen a mano contra el orden de cfdv33.xsd, Pagos10.xsd y TimbreFiscalDigitalv11.xsd, nunca con la salida de este paquete; contenidoXML verifica además, sin pasar por este paquete, que cada .golden conserve el contenido de su .xml.
+func TestGolden(t *testing.T) {
+	entradas, err := filepath.Glob(filepath.Join("testdata", "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entradas) == 0 {
+		t.Fatal("no hay comprobantes en testdata")
+	}
+	for _, entrada := range entradas {
+		entrada := entrada
+		t.Run(filepath.Base(entrada), func(t *testing.T) {
+			datos, err := os.ReadFile(entrada)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c Comprobante
+			if err := Unmarshal(datos, &c); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			salida, err := MarshalIndent(c, "", "\t")
+			if err != nil {
+				t.Fatalf("MarshalIndent: %v", err)
+			}
+			golden := strings.TrimSuffix(entrada, ".xml") + ".golden"
+			esperado, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodos, ubicaciones := contenidoXML(t, datos)
+			nodosGolden, ubicacionesGolden := contenidoXML(t, esperado)
+			if a, b := strings.Join(nodos, "\n"), strings.Join(nodosGolden, "\n"); a != b {
+				t.Errorf("%s no conserva el contenido de %s:\n%s", golden, entrada, diferencia([]byte(a), []byte(b)))
+			}
+			for espacio, esquema := range ubicaciones {
+				if ubicacionesGolden[espacio] != esquema {
+					t.Errorf("el xsi:schemaLocation de %s no incluye %s %s", golden, espacio, esquema)
+				}
+			}
+			if !bytes.Equal(salida, esperado) {
+				t.Errorf("la salida difiere de %s:\n%s", golden, diferencia(esperado, salida))
+			}
+			var otro Comprobante
+			if err := Unmarshal(salida, &otro); err != nil {
+				t.Fatalf("Unmarshal de la salida: %v", err)
+			}
+			if segunda, err := MarshalIndent(otro, "", "\t"); err != nil || !bytes.Equal(segunda, salida) {
+				t.Errorf("la salida no es estable al leerla de nuevo (err %v)", err)
+			}
+		})
+	}
+}
+
+// contenidoXML Devuelve, en orden de documento, cada nodo de datos con su espacio de nombres, sus atributos ordenados y su texto, leído sólo con encoding/xml. Omite las declaraciones xmlns, cuya ubicación puede cambiar, los atributos vacíos, que Marshal escribe para Sello y Certificado aunque falten, y el texto formado sólo por espacios. Los pares de xsi:schemaLocation se devuelven aparte.
+func contenidoXML(t *testing.T, datos []byte) ([]string, map[string]string) {
+	t.Helper()
+	var nodos []string
+	ubicaciones := map[string]string{}
+	d := xml.NewDecoder(bytes.NewReader(datos))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nodos, ubicaciones
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			var atributos []string
+			for _, a := range v.Attr {
+				switch {
+				case a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" || a.Value == "":
+				case a.Name.Space == NamespaceXSI && a.Name.Local == "schemaLocation":
+					pares := strings.Fields(a.Value)
+					for i := 0; i+1 < len(pares); i += 2 {
+						ubicaciones[pares[i]] = pares[i+1]
+					}
+				default:
+					atributos = append(atributos, a.Name.Space+" "+a.Name.Local+"="+strconv.Quote(a.Value))
+				}
+			}
+			sort.Strings(atributos)
+			nodos = append(nodos, "<"+v.Name.Space+" "+v.Name.Local+" "+strings.Join(atributos, " "))
+		case xml.EndElement:
+			nodos = append(nodos, "</"+v.Name.Local)
+		case xml.CharData:
+			if texto := strings.TrimSpace(string(v)); texto != "" {
+				nodos = append(nodos, strconv.Quote(texto))
+			}
+		}
+	}
+}
+
+// diferencia Devuelve la primera línea en la que difieren esperado y obtenido.
+func diferencia(esperado, obtenido []byte) string {
+	a := strings.Split(string(esperado), "\n")
+	b := strings.Split(string(obtenido), "\n")
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y string
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return "línea " + strconv.Itoa(i+1) + ":\n  se esperaba: " + x + "\n  se obtuvo:   " + y
+		}
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package xmlstructures
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Notas de crédito
+*
+*
+****************************************************************************************************************************************/
+
+// Datos con que se expide una nota de crédito según la guía de llenado del SAT.
+const (
+	TipoRelacionNotaCredito = "01"       // Nota de crédito de los documentos relacionados.
+	UsoCFDINotaCredito      = "G02"      // Devoluciones, descuentos o bonificaciones.
+	ClaveProdServDescuento  = "84111506" // Servicios de facturación, para los descuentos y bonificaciones.
+	ClaveUnidadDescuento    = "ACT"      // Actividad.
+)
+
+// Errores devueltos al generar una nota de crédito, envueltos en un *ErrorCalculo cuando se refieren a un nodo.
+var (
+	ErrNoEsIngreso           = errors.New("el comprobante original no es de ingreso")
+	ErrLineaCredito          = errors.New("la línea debe indicar Cantidad o Importe, pero no ambos")
+	ErrCreditoExcedeConcepto = errors.New("el crédito excede la cantidad o el importe del concepto original")
+	ErrPorcentajeCredito     = errors.New("el porcentaje debe ser mayor que 0 y a lo más 100")
+	ErrCreditoExcedeTotal    = errors.New("las notas de crédito exceden el total del comprobante original")
+	ErrFormaPagoNotaCredito  = errors.New("la nota de crédito debe indicar la forma en que se devuelve o compensa el crédito, distinta de 99")
+)
+
+// LineaCredito Concepto del comprobante original que se devuelve o se descuenta en una nota de crédito.
+type LineaCredito struct {
+	Concepto int      // Posición del concepto en el comprobante original, desde 0.
+	Cantidad *Decimal // Cantidad devuelta; la nota repite el concepto original con esta cantidad y la parte proporcional de su descuento.
+	Importe  *Decimal // Descuento o bonificación antes de impuestos; la nota lleva un concepto de descuento con este importe.
+}
+
+// NotaCredito Devuelve el borrador de la nota de crédito por las lineas del comprobante de ingreso timbrado c. Cada concepto de la nota conserva los impuestos del concepto original, con sus bases e importes recalculados por CalcularTotales, de modo que los impuestos se reducen en proporción al crédito.
+//
+// La nota es de tipo E, relaciona a c con TipoRelacionNotaCredito, usa UsoCFDINotaCredito, MetodoPago PUE y formaPago, la clave de c_FormaPago con que se devuelve o compensa el crédito (p.ej. 03 transferencia o 17 compensación), que no tiene por qué coincidir con la de c y no puede ser 99; copia emisor, receptor, moneda y lugar de expedición y queda sin Fecha, serie, folio ni sello.
+//
+// previas son las notas de crédito vigentes ya emitidas para c y deben incluirse todas, de cualquier serie o periodo y también las que relacionan a c junto con otros comprobantes: el tope se revisa sólo contra ellas, por lo que si falta alguna el crédito acumulado puede exceder el Total de c sin error. Si con la nueva nota el crédito acumulado excede el Total de c se devuelve ErrCreditoExcedeTotal.
+func (c Comprobante) NotaCredito(lineas []LineaCredito, formaPago string, previas []Comprobante) (Comprobante, error) {
+	nota, err := borradorNotaCredito(c, formaPago)
+	if err != nil {
+		return Comprobante{}, err
+	}
+	decimales := DecimalesMoneda(c.Moneda)
+	for i, linea := range lineas {
+		ruta := fmt.Sprintf("/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[%d]", linea.Concepto+1)
+		if linea.Concepto < 0 || linea.Concepto >= len(c.Conceptos.Conceptos) {
+			return Comprobante{}, &ErrorCalculo{Ruta: ruta, Err: fmt.Errorf("línea %d: el concepto no existe", i+1)}
+		}
+		original := c.Conceptos.Conceptos[linea.Concepto]
+		var concepto CFDIConcepto
+		switch {
+		case linea.Cantidad != nil && linea.Importe == nil:
+			if linea.Cantidad.Sign() <= 0 || linea.Cantidad.Cmp(original.Cantidad) > 0 {
+				return Comprobante{}, &ErrorCalculo{Ruta: ruta + "/@Cantidad", Err: ErrCreditoExcedeConcepto}
+			}
+			concepto = conceptoDevolucion(original, *linea.Cantidad, decimales)
+		case linea.Importe != nil && linea.Cantidad == nil:
+			if linea.Importe.Sign() <= 0 || linea.Importe.Cmp(importeNeto(original)) > 0 {
+				return Comprobante{}, &ErrorCalculo{Ruta: ruta + "/@Importe", Err: ErrCreditoExcedeConcepto}
+			}
+			concepto = conceptoDescuento(original, *linea.Importe)
+		default:
+			return Comprobante{}, &ErrorCalculo{Ruta: ruta, Err: ErrLineaCredito}
+		}
+		nota.Conceptos.Conceptos = append(nota.Conceptos.Conceptos, concepto)
+	}
+	return cerrarNotaCredito(c, nota, previas)
+}
+
+// NotaCreditoPorcentaje Devuelve el borrador de la nota de crédito por un descuento de porcentaje (p.ej. 10 para 10 %) sobre cada concepto del comprobante de ingreso timbrado c, después de su descuento. Por lo demás se comporta como NotaCredito; previas también deben ser todas las notas vigentes de c.
+func (c Comprobante) NotaCreditoPorcentaje(porcentaje Decimal, formaPago string, previas []Comprobante) (Comprobante, error) {
+	cien := NewDecimal(100, 0)
+	if porcentaje.Sign() <= 0 || porcentaje.Cmp(cien) > 0 {
+		return Comprobante{}, &ErrorCalculo{Ruta: "/cfdi:Comprobante", Err: ErrPorcentajeCredito}
+	}
+	nota, err := borradorNotaCredito(c, formaPago)
+	if err != nil {
+		return Comprobante{}, err
+	}
+	decimales := DecimalesMoneda(c.Moneda)
+	for _, original := range c.Conceptos.Conceptos {
+		importe, _ := importeNeto(original).Mul(porcentaje).Quo(cien, decimales) // cien nunca es cero.
+		if importe.IsZero() {
+			continue
+		}
+		nota.Conceptos.Conceptos = append(nota.Conceptos.Conceptos, conceptoDescuento(original, importe))
+	}
+	return cerrarNotaCredito(c, nota, previas)
+}
+
+// CreditoAplicado Devuelve la suma de los totales de las notas de crédito que relacionan el comprobante uuid con TipoRelacionNotaCredito. Una nota que relaciona varios comprobantes se cuenta completa para cada uno, ya que su total no puede repartirse. Las notas canceladas no deben incluirse.
+func CreditoAplicado(uuid string, notas []Comprobante) Decimal {
+	suma := Decimal{}
+	for _, n := range notas {
+		if n.TipoDeComprobante == "E" && n.Relaciona(TipoRelacionNotaCredito, uuid) {
+			suma = suma.Add(n.Total)
+		}
+	}
+	return suma
+}
+
+// Relaciona Indica si c relaciona el comprobante uuid con el tipo de relación dado. Los UUID se comparan sin distinguir mayúsculas.
+func (c Comprobante) Relaciona(tipoRelacion, uuid string) bool {
+	if c.Relacionados == nil || c.Relacionados.TipoRelacion != tipoRelacion {
+		return false
+	}
+	for _, r := range c.Relacionados.CfdiRelacionado {
+		if strings.EqualFold(r.UUID, uuid) {
+			return true
+		}
+	}
+	return false
+}
+
+// borradorNotaCredito Devuelve la nota de crédito de c con formaPago, sin conceptos.
+func borradorNotaCredito(c Comprobante, formaPago string) (Comprobante, error) {
+	if c.TipoDeComprobante != "I" {
+		return Comprobante{}, ErrNoEsIngreso
+	}
+	if formaPago == "" || formaPago == "99" {
+		return Comprobante{}, &ErrorCalculo{Ruta: "/cfdi:Comprobante/@FormaPago", Err: ErrFormaPagoNotaCredito}
+	}
+	t := c.Timbre()
+	if t == nil {
+		return Comprobante{}, ErrSinTimbre
+	}
+	nota := Comprobante{
+		Version:           c.Version,
+		FormaPago:         formaPago,
+		Moneda:            c.Moneda,
+		TipoCambio:        c.TipoCambio,
+		TipoDeComprobante: "E",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   c.LugarExpedicion,
+		Relacionados:      &CFDIRelacionados{TipoRelacion: TipoRelacionNotaCredito, CfdiRelacionado: []CFDIRelacionado{{UUID: t.UUID}}},
+		Emisor:            c.Emisor,
+		Receptor:          c.Receptor,
+	}
+	nota.Receptor.UsoCFDI = UsoCFDINotaCredito
+	return nota, nil
+}
+
+// cerrarNotaCredito Calcula los totales de la nota y revisa que el crédito acumulado no exceda el total de c.
+func cerrarNotaCredito(c, nota Comprobante, previas []Comprobante) (Comprobante, error) {
+	if len(nota.Conceptos.Conceptos) == 0 {
+		return Comprobante{}, &ErrorCalculo{Ruta: "/cfdi:Comprobante/cfdi:Conceptos", Err: ErrLineaCredito}
+	}
+	if err := nota.CalcularTotales(); err != nil {
+		return Comprobante{}, err
+	}
+	uuid := nota.Relacionados.CfdiRelacionado[0].UUID
+	acumulado := CreditoAplicado(uuid, previas).Add(nota.Total)
+	if acumulado.Cmp(c.Total) > 0 {
+		return Comprobante{}, &ErrorCalculo{Ruta: "/cfdi:Comprobante/@Total", Err: fmt.Errorf("%w: %s de %s", ErrCreditoExcedeTotal, acumulado, c.Total)}
+	}
+	return nota, nil
+}
+
+// conceptoDevolucion Devuelve el concepto original con la cantidad devuelta y la parte proporcional de su descuento.
+func conceptoDevolucion(original CFDIConcepto, cantidad Decimal, decimales int32) CFDIConcepto {
+	concepto := CFDIConcepto{
+		ClaveProdServ:    original.ClaveProdServ,
+		NoIdentificacion: original.NoIdentificacion,
+		Cantidad:         cantidad,
+		ClaveUnidad:      original.ClaveUnidad,
+		Unidad:           original.Unidad,
+		Descripcion:      original.Descripcion,
+		ValorUnitario:    original.ValorUnitario,
+		Impuestos:        impuestosSinImportes(original.Impuestos),
+	}
+	if original.Descuento != nil && !original.Descuento.IsZero() {
+		descuento, _ := original.Descuento.Mul(cantidad).Quo(original.Cantidad, decimales) // NotaCredito ya verificó 0 < cantidad ≤ original.Cantidad.
+		concepto.Descuento = &descuento
+	}
+	return concepto
+}
+
+// conceptoDescuento Devuelve el concepto de descuento por importe sobre el concepto original, con sus mismos impuestos.
+func conceptoDescuento(original CFDIConcepto, importe Decimal) CFDIConcepto {
+	return CFDIConcepto{
+		ClaveProdServ: ClaveProdServDescuento,
+		Cantidad:      NewDecimal(1, 0),
+		ClaveUnidad:   ClaveUnidadDescuento,
+		Descripcion:   "Descuento sobre " + original.Descripcion,
+		ValorUnitario: importe,
+		Impuestos:     impuestosSinImportes(original.Impuestos),
+	}
+}
+
+// importeNeto Devuelve el importe del concepto menos su descuento, que es la base de sus impuestos.
+func importeNeto(concepto CFDIConcepto) Decimal {
+	if concepto.Descuento == nil {
+		return concepto.Importe
+	}
+	return concepto.Importe.Sub(*concepto.Descuento)
+}
+
+// impuestosSinImportes Devuelve una copia de los impuestos del concepto con sólo Impuesto, TipoFactor y TasaOCuota, para que CalcularTotales calcule las bases e importes.
+func impuestosSinImportes(impuestos *CFDIImpuestosInner) *CFDIImpuestosInner {
+	if impuestos == nil {
+		return nil
+	}
+	copia := &CFDIImpuestosInner{}
+	if impuestos.Traslados != nil {
+		copia.Traslados = &CFDIImpuestosTrasladosInner{}
+		for _, t := range impuestos.Traslados.Traslados {
+			copia.Traslados.Traslados = append(copia.Traslados.Traslados, CFDIImpuestosTrasladoInner{Impuesto: t.Impuesto, TipoFactor: t.TipoFactor, TasaOCuota: t.TasaOCuota})
+		}
+	}
+	if impuestos.Retenciones != nil {
+		copia.Retenciones = &CFDIImpuestosRetencionesInner{}
+		for _, r := range impuestos.Retenciones.Retenciones {
+			copia.Retenciones.Retenciones = append(copia.Retenciones.Retenciones, CFDIImpuestosRetencionInner{Impuesto: r.Impuesto, TipoFactor: r.TipoFactor, TasaOCuota: r.TasaOCuota})
+		}
+	}
+	return copia
+}
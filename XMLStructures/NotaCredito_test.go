@@ -0,0 +1,190 @@
+package xmlstructures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// facturaTimbrada Lee testdata/ingreso.xml, un comprobante de ingreso timbrado con FormaPago 03.
+func facturaTimbrada(t *testing.T) Comprobante {
+	t.Helper()
+	datos, err := os.ReadFile(filepath.Join("testdata", "ingreso.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Comprobante
+	if err := Unmarshal(datos, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNotaCreditoFormaPago(t *testing.T) {
+	factura := facturaTimbrada(t)
+	diez := NewDecimal(10, 0)
+	nota, err := factura.NotaCreditoPorcentaje(diez, "15", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nota.FormaPago != "15" || nota.Receptor.UsoCFDI != UsoCFDINotaCredito || !nota.Relaciona(TipoRelacionNotaCredito, "ed1752fe-e865-4ff2-bfe1-0f552e770dc9") {
+		t.Errorf("nota FormaPago=%s UsoCFDI=%s Relacionados=%+v", nota.FormaPago, nota.Receptor.UsoCFDI, nota.Relacionados)
+	}
+	for _, formaPago := range []string{"", "99"} {
+		if _, err := factura.NotaCreditoPorcentaje(diez, formaPago, nil); !errors.Is(err, ErrFormaPagoNotaCredito) {
+			t.Errorf("FormaPago %q: %v", formaPago, err)
+		}
+	}
+}
+
+func TestNotaCreditoPrevias(t *testing.T) {
+	factura := facturaTimbrada(t)
+	previa, err := factura.NotaCreditoPorcentaje(NewDecimal(90, 0), "03", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := factura.NotaCreditoPorcentaje(NewDecimal(20, 0), "03", []Comprobante{previa}); !errors.Is(err, ErrCreditoExcedeTotal) {
+		t.Errorf("se esperaba ErrCreditoExcedeTotal, se obtuvo %v", err)
+	}
+	// Sin la nota previa el tope no puede revisarse.
+	if _, err := factura.NotaCreditoPorcentaje(NewDecimal(20, 0), "03", nil); err != nil {
+		t.Errorf("sin notas previas: %v", err)
+	}
+}
+
+// resumenNota Describe cada concepto de la nota como "ClaveProdServ Cantidad Importe Descuento" seguido de "T Base Importe" por traslado y "R Base Importe" por retención, y al final los totales como "SubTotal Descuento Total TotalImpuestosTrasladados TotalImpuestosRetenidos". Un importe ausente se escribe como -.
+func resumenNota(n Comprobante) []string {
+	texto := func(d *Decimal) string {
+		if d == nil {
+			return "-"
+		}
+		return d.String()
+	}
+	var resumen []string
+	for _, c := range n.Conceptos.Conceptos {
+		linea := []string{c.ClaveProdServ, c.Cantidad.String(), c.Importe.String(), texto(c.Descuento)}
+		if c.Impuestos != nil && c.Impuestos.Traslados != nil {
+			for _, t := range c.Impuestos.Traslados.Traslados {
+				linea = append(linea, "T", t.Base.String(), texto(t.Importe))
+			}
+		}
+		if c.Impuestos != nil && c.Impuestos.Retenciones != nil {
+			for _, r := range c.Impuestos.Retenciones.Retenciones {
+				linea = append(linea, "R", r.Base.String(), r.Importe.String())
+			}
+		}
+		resumen = append(resumen, strings.Join(linea, " "))
+	}
+	totales := []string{n.SubTotal.String(), texto(n.Descuento), n.Total.String()}
+	if n.Impuestos != nil {
+		totales = append(totales, texto(n.Impuestos.TotalImpuestosTrasladados), texto(n.Impuestos.TotalImpuestosRetenidos))
+	}
+	return append(resumen, strings.Join(totales, " "))
+}
+
+func TestNotaCreditoLineas(t *testing.T) {
+	// El primer concepto de testdata/ingreso.xml es Cantidad 2 por 750.00 con Descuento 100.00, traslada IVA 16 % y retiene ISR 10 % e IVA 10.6666 %; el segundo es 1 por 500.00 y traslada IVA 16 %.
+	factura := facturaTimbrada(t)
+	casos := []struct {
+		nombre   string
+		lineas   []LineaCredito
+		esperado []string
+	}{
+		{"devolución de una unidad", []LineaCredito{{Concepto: 0, Cantidad: pdec("1")}}, []string{
+			"81111500 1 750.00 50.00 T 700.00 112.00 R 700.00 70.00 R 700.00 74.67",
+			"750.00 50.00 667.33 112.00 144.67",
+		}},
+		{"devolución fraccionaria", []LineaCredito{{Concepto: 0, Cantidad: pdec("0.3")}}, []string{
+			"81111500 0.3 225.00 15.00 T 210.00 33.60 R 210.00 21.00 R 210.00 22.40",
+			"225.00 15.00 200.20 33.60 43.40",
+		}},
+		{"devolución completa", []LineaCredito{{Concepto: 1, Cantidad: pdec("1")}}, []string{
+			"43211500 1 500.00 - T 500.00 80.00",
+			"500.00 - 580.00 80.00 -",
+		}},
+		{"descuento por importe", []LineaCredito{{Concepto: 1, Importe: pdec("100")}}, []string{
+			ClaveProdServDescuento + " 1 100.00 - T 100.00 16.00",
+			"100.00 - 116.00 16.00 -",
+		}},
+		{"descuento del importe neto", []LineaCredito{{Concepto: 0, Importe: pdec("1400.00")}}, []string{
+			ClaveProdServDescuento + " 1 1400.00 - T 1400.00 224.00 R 1400.00 140.00 R 1400.00 149.33",
+			"1400.00 - 1334.67 224.00 289.33",
+		}},
+		{"devolución y descuento", []LineaCredito{{Concepto: 0, Cantidad: pdec("1")}, {Concepto: 1, Importe: pdec("100")}}, []string{
+			"81111500 1 750.00 50.00 T 700.00 112.00 R 700.00 70.00 R 700.00 74.67",
+			ClaveProdServDescuento + " 1 100.00 - T 100.00 16.00",
+			"850.00 50.00 783.33 128.00 144.67",
+		}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			nota, err := factura.NotaCredito(caso.lineas, "03", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumen := resumenNota(nota); strings.Join(resumen, "\n") != strings.Join(caso.esperado, "\n") {
+				t.Errorf("se esperaba\n  %s\nse obtuvo\n  %s", strings.Join(caso.esperado, "\n  "), strings.Join(resumen, "\n  "))
+			}
+			if nota.TipoDeComprobante != "E" || nota.MetodoPago != "PUE" || nota.Fecha != "" || nota.Sello != "" {
+				t.Errorf("nota TipoDeComprobante=%s MetodoPago=%s Fecha=%q", nota.TipoDeComprobante, nota.MetodoPago, nota.Fecha)
+			}
+			for i, c := range nota.Conceptos.Conceptos {
+				original := factura.Conceptos.Conceptos[caso.lineas[i].Concepto]
+				if c.ClaveProdServ == ClaveProdServDescuento && (c.ClaveUnidad != ClaveUnidadDescuento || c.Descripcion != "Descuento sobre "+original.Descripcion) {
+					t.Errorf("concepto de descuento %s %q", c.ClaveUnidad, c.Descripcion)
+				}
+			}
+			nota.Fecha = "2026-10-18T09:00:00"
+			if errores := Validate(nota); len(errores) != 0 {
+				t.Errorf("la nota no es válida: %v", errores)
+			}
+		})
+	}
+}
+
+func TestNotaCreditoErrores(t *testing.T) {
+	const concepto = "/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto"
+	casos := []struct {
+		nombre   string
+		cambio   func(c *Comprobante)
+		lineas   []LineaCredito
+		ruta     string
+		esperado error // nil cuando el error no tiene un valor propio.
+	}{
+		{"concepto inexistente", nil, []LineaCredito{{Concepto: 2, Cantidad: pdec("1")}}, concepto + "[3]", nil},
+		{"concepto negativo", nil, []LineaCredito{{Concepto: -1, Cantidad: pdec("1")}}, concepto + "[0]", nil},
+		{"cantidad mayor", nil, []LineaCredito{{Concepto: 0, Cantidad: pdec("2.000001")}}, concepto + "[1]/@Cantidad", ErrCreditoExcedeConcepto},
+		{"cantidad cero", nil, []LineaCredito{{Concepto: 0, Cantidad: pdec("0")}}, concepto + "[1]/@Cantidad", ErrCreditoExcedeConcepto},
+		{"importe mayor que el neto", nil, []LineaCredito{{Concepto: 0, Importe: pdec("1400.01")}}, concepto + "[1]/@Importe", ErrCreditoExcedeConcepto},
+		{"importe negativo", nil, []LineaCredito{{Concepto: 1, Importe: pdec("-1")}}, concepto + "[2]/@Importe", ErrCreditoExcedeConcepto},
+		{"cantidad e importe", nil, []LineaCredito{{Concepto: 1, Cantidad: pdec("1"), Importe: pdec("1")}}, concepto + "[2]", ErrLineaCredito},
+		{"línea vacía", nil, []LineaCredito{{Concepto: 1}}, concepto + "[2]", ErrLineaCredito},
+		{"sin líneas", nil, nil, "/cfdi:Comprobante/cfdi:Conceptos", ErrLineaCredito},
+		{"no es de ingreso", func(c *Comprobante) { c.TipoDeComprobante = "E" }, []LineaCredito{{Concepto: 0, Cantidad: pdec("1")}}, "", ErrNoEsIngreso},
+		{"sin timbre", func(c *Comprobante) { c.Complemento = nil }, []LineaCredito{{Concepto: 0, Cantidad: pdec("1")}}, "", ErrSinTimbre},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			factura := facturaTimbrada(t)
+			if caso.cambio != nil {
+				caso.cambio(&factura)
+			}
+			nota, err := factura.NotaCredito(caso.lineas, "03", nil)
+			if err == nil {
+				t.Fatalf("se generó la nota %+v", nota)
+			}
+			if caso.esperado != nil && !errors.Is(err, caso.esperado) {
+				t.Errorf("se esperaba %v, se obtuvo %v", caso.esperado, err)
+			}
+			var e *ErrorCalculo
+			switch {
+			case caso.ruta == "" && errors.As(err, &e):
+				t.Errorf("se esperaba el error sin ruta, se obtuvo %v", err)
+			case caso.ruta != "" && (!errors.As(err, &e) || e.Ruta != caso.ruta):
+				t.Errorf("se esperaba un *ErrorCalculo en %s, se obtuvo %v", caso.ruta, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package pac
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+
+	xmlstructures ".."
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Cancelación de comprobantes timbrados
+*
+*
+****************************************************************************************************************************************/
+
+// Claves de Folio.Motivo del catálogo de motivos de cancelación.
+const (
+	MotivoErroresConRelacion = "01" // Comprobante emitido con errores con relación: se sustituye por el comprobante de FolioSustitucion.
+	MotivoErroresSinRelacion = "02" // Comprobante emitido con errores sin relación.
+	MotivoSinOperacion       = "03" // No se llevó a cabo la operación.
+	MotivoFacturaGlobal      = "04" // Operación nominativa relacionada en una factura global.
+)
+
+// Códigos de FolioCancelado.EstatusUUID en el acuse del SAT.
+const (
+	EstatusSolicitudRecibida    = "201"
+	EstatusPreviamenteCancelado = "202"
+	EstatusNoCorrespondeEmisor  = "203"
+	EstatusNoAplicable          = "204"
+	EstatusNoExiste             = "205"
+)
+
+// MensajesCancelacion Descripción de cada código de EstatusUUID.
+var MensajesCancelacion = map[string]string{
+	EstatusSolicitudRecibida:    "Solicitud de cancelación recibida.",
+	EstatusPreviamenteCancelado: "Folio fiscal previamente cancelado.",
+	EstatusNoCorrespondeEmisor:  "Folio fiscal no corresponde al emisor.",
+	EstatusNoAplicable:          "Folio fiscal no aplicable a cancelación.",
+	EstatusNoExiste:             "Folio fiscal no existente.",
+}
+
+// Valores de Estado.EstatusCancelacion y FolioCancelado.EstatusCancelacion. Desde 2018 la cancelación de algunos comprobantes requiere que el receptor la acepte en un plazo de tres días hábiles; mientras tanto la solicitud queda En proceso y, si el receptor no responde, se cancela por Plazo vencido.
+const (
+	CancelacionEnProceso   = "En proceso"
+	CanceladoSinAceptacion = "Cancelado sin aceptación"
+	CanceladoConAceptacion = "Cancelado con aceptación"
+	CanceladoPlazoVencido  = "Plazo vencido"
+	SolicitudRechazada     = "Solicitud rechazada"
+)
+
+// ErrSinSolicitud Indica que se consultó el seguimiento de un comprobante cuya cancelación no se ha solicitado.
+var ErrSinSolicitud = errors.New("pac: no se ha solicitado la cancelación del comprobante")
+
+// Cancelador Solicita cancelaciones firmadas con el CSD del emisor a través de un PAC y lleva su seguimiento en el ComprobanteMgo.
+type Cancelador struct {
+	PAC   Stamper            // PAC por el que se envían las solicitudes.
+	CSD   *xmlstructures.CSD // Certificado del emisor con que se firman las solicitudes.
+	Ahora func() time.Time   // Reloj con que se fechan las solicitudes; time.Now si es nil.
+}
+
+// ahora Devuelve la hora del reloj del cancelador en la zona del SAT.
+func (c *Cancelador) ahora() time.Time {
+	if c.Ahora != nil {
+		return c.Ahora().In(zonaSAT)
+	}
+	return time.Now().In(zonaSAT)
+}
+
+// Cancelar Firma la solicitud de cancelación de folios con el CSD y la envía al PAC. Si el PAC devuelve el acuse del SAT sin interpretarlo, los folios y la fecha se toman del acuse.
+func (c *Cancelador) Cancelar(ctx context.Context, rfcEmisor string, folios []Folio) (*AcuseCancelacion, error) {
+	cancelacion := xmlstructures.Cancelacion{Fecha: c.ahora().Format("2006-01-02T15:04:05"), RFCEmisor: rfcEmisor}
+	for _, f := range folios {
+		cancelacion.Folios = append(cancelacion.Folios, xmlstructures.FolioCancelacion{UUID: f.UUID, Motivo: f.Motivo, FolioSustitucion: f.FolioSustitucion})
+	}
+	firmada, err := c.CSD.FirmarCancelacion(cancelacion)
+	if err != nil {
+		return nil, err
+	}
+	acuse, err := c.PAC.Cancel(ctx, SolicitudCancelacion{RFCEmisor: rfcEmisor, Folios: folios, XML: firmada})
+	if err != nil {
+		return nil, err
+	}
+	if len(acuse.XML) > 0 && (len(acuse.Folios) == 0 || acuse.Fecha == "") {
+		leido, err := LeerAcuse(acuse.XML)
+		if err != nil {
+			return nil, err
+		}
+		if len(acuse.Folios) == 0 {
+			acuse.Folios = leido.Folios
+		}
+		if acuse.Fecha == "" {
+			acuse.Fecha = leido.Fecha
+		}
+	}
+	return acuse, nil
+}
+
+// CancelarComprobante Solicita la cancelación del comprobante m con los datos de folio y registra el resultado en m.Cancelacion, aun si el SAT no recibió la solicitud. Una solicitud En proceso deja el Estado en Vigente hasta que ActualizarComprobante registre la respuesta del receptor. Devuelve un *ErrorPAC con el EstatusUUID si el SAT rechazó el folio.
+func (c *Cancelador) CancelarComprobante(ctx context.Context, m *xmlstructures.ComprobanteMgo, folio Folio) error {
+	acuse, resultado, err := c.cancelarFolio(ctx, m.CFDIEmisorMgo.RFC, folio)
+	if err != nil {
+		return err
+	}
+	registro := m.Cancelacion
+	registro.UUID = folio.UUID
+	registro.Motivo = folio.Motivo
+	registro.FolioSustitucion = folio.FolioSustitucion
+	registro.EstatusUUID = resultado.EstatusUUID
+	registro.Acuse = acuse.XML
+	if fecha, err := time.ParseInLocation("2006-01-02T15:04:05", acuse.Fecha, zonaSAT); err == nil {
+		registro.FechaSolicitud = fecha
+	}
+	switch resultado.EstatusUUID {
+	case EstatusSolicitudRecibida:
+		registro.EstatusCancelacion = resultado.EstatusCancelacion
+		if registro.EstatusCancelacion == "" {
+			registro.EstatusCancelacion = CancelacionEnProceso
+		}
+		registro.Estado = EstadoVigente
+		if cancelado(registro.EstatusCancelacion) {
+			registro.Estado = EstadoCancelado
+		}
+	case EstatusPreviamenteCancelado:
+		registro.Estado = EstadoCancelado
+	default:
+		m.Cancelacion = registro
+		return &ErrorPAC{Codigo: resultado.EstatusUUID, Mensaje: MensajesCancelacion[resultado.EstatusUUID]}
+	}
+	m.Cancelacion = registro
+	return nil
+}
+
+// ActualizarComprobante Consulta en el SAT el estado del comprobante m cuya cancelación se solicitó y lo registra en m.Cancelacion. Se llama periódicamente mientras la solicitud esté En proceso, hasta que el receptor la acepte o la rechace o venza el plazo.
+func (c *Cancelador) ActualizarComprobante(ctx context.Context, m *xmlstructures.ComprobanteMgo) (*Estado, error) {
+	if m.Cancelacion.UUID == "" {
+		return nil, ErrSinSolicitud
+	}
+	estado, err := c.PAC.Status(ctx, Consulta{
+		UUID:        m.Cancelacion.UUID,
+		RFCEmisor:   m.CFDIEmisorMgo.RFC,
+		RFCReceptor: m.CFDIReceptorMgo.RFC,
+		Total:       m.Total.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.Cancelacion.Estado = estado.Estado
+	m.Cancelacion.EsCancelable = estado.EsCancelable
+	if estado.EstatusCancelacion != "" {
+		m.Cancelacion.EstatusCancelacion = estado.EstatusCancelacion
+	}
+	m.Cancelacion.FechaConsulta = c.ahora()
+	return estado, nil
+}
+
+// cancelarFolio Solicita la cancelación de un solo folio y devuelve el acuse junto con el resultado para ese folio.
+func (c *Cancelador) cancelarFolio(ctx context.Context, rfcEmisor string, folio Folio) (*AcuseCancelacion, FolioCancelado, error) {
+	acuse, err := c.Cancelar(ctx, rfcEmisor, []Folio{folio})
+	if err != nil {
+		return nil, FolioCancelado{}, err
+	}
+	resultado, ok := buscarFolio(acuse.Folios, folio.UUID)
+	if !ok {
+		return nil, FolioCancelado{}, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: "El acuse no incluye el folio " + folio.UUID + "."}
+	}
+	return acuse, resultado, nil
+}
+
+// cancelado Indica si el estatus de cancelación corresponde a un comprobante ya cancelado.
+func cancelado(estatus string) bool {
+	return estatus == CanceladoSinAceptacion || estatus == CanceladoConAceptacion || estatus == CanceladoPlazoVencido
+}
+
+// buscarFolio Devuelve el resultado para uuid entre los folios del acuse.
+func buscarFolio(folios []FolioCancelado, uuid string) (FolioCancelado, bool) {
+	for _, f := range folios {
+		if strings.EqualFold(f.UUID, uuid) {
+			return f, true
+		}
+	}
+	return FolioCancelado{}, false
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Acuse de cancelación del SAT
+*
+*
+****************************************************************************************************************************************/
+
+// acuseSAT Acuse de cancelación tal como lo emite el SAT: un nodo Folios por cada comprobante solicitado.
+type acuseSAT struct {
+	XMLName    xml.Name `xml:"Acuse"`
+	Fecha      string   `xml:"Fecha,attr"`
+	RFCEmisor  string   `xml:"RfcEmisor,attr"`
+	CodEstatus string   `xml:"CodEstatus,attr"`
+	Folios     []struct {
+		UUID               string `xml:"UUID"`
+		EstatusUUID        string `xml:"EstatusUUID"`
+		EstatusCancelacion string `xml:"EstatusCancelacion"`
+	} `xml:"Folios"`
+}
+
+// LeerAcuse Interpreta el acuse de cancelación del SAT. Si el SAT rechazó la solicitud completa, el acuse no lleva folios y se devuelve un *ErrorPAC con el CodEstatus.
+func LeerAcuse(datos []byte) (*AcuseCancelacion, error) {
+	var a acuseSAT
+	if err := xml.Unmarshal(datos, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Folios) == 0 {
+		return nil, &ErrorPAC{Mensaje: a.CodEstatus}
+	}
+	acuse := &AcuseCancelacion{RFCEmisor: a.RFCEmisor, Fecha: a.Fecha, XML: datos}
+	for _, f := range a.Folios {
+		acuse.Folios = append(acuse.Folios, FolioCancelado{UUID: f.UUID, EstatusUUID: f.EstatusUUID, EstatusCancelacion: f.EstatusCancelacion})
+	}
+	return acuse, nil
+}
+
+// escribirAcuse Devuelve el acuse en el formato del SAT, sin firma.
+func escribirAcuse(acuse *AcuseCancelacion) []byte {
+	var b strings.Builder
+	b.WriteString(`<Acuse xmlns="` + xmlstructures.NamespaceCancelacion + `" Fecha="` + escaparXML(acuse.Fecha) + `" RfcEmisor="` + escaparXML(acuse.RFCEmisor) + `">`)
+	for _, f := range acuse.Folios {
+		b.WriteString("<Folios><UUID>" + escaparXML(f.UUID) + "</UUID><EstatusUUID>" + f.EstatusUUID + "</EstatusUUID>")
+		if f.EstatusCancelacion != "" {
+			b.WriteString("<EstatusCancelacion>" + escaparXML(f.EstatusCancelacion) + "</EstatusCancelacion>")
+		}
+		b.WriteString("</Folios>")
+	}
+	b.WriteString("</Acuse>")
+	return []byte(b.String())
+}
+
+// escaparXML Escapa s para usarse como texto o valor de atributo.
+func escaparXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
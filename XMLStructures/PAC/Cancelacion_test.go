@@ -0,0 +1,165 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	xmlstructures ".."
+)
+
+// cancelacionPrueba Timbra con el simulador un comprobante de total expedido una hora antes de ahoraPrueba y devuelve el simulador, un cancelador y el ComprobanteMgo del comprobante timbrado. El reloj de ambos es *reloj.
+func cancelacionPrueba(t *testing.T, reloj *time.Time, total string) (*Simulador, *Cancelador, *xmlstructures.ComprobanteMgo, string) {
+	t.Helper()
+	s, c, original, _ := sustitucionPrueba(t, reloj, total)
+	m := &xmlstructures.ComprobanteMgo{Total: original.Total}
+	m.CFDIEmisorMgo.RFC = original.Emisor.RFC
+	m.CFDIReceptorMgo.RFC = original.Receptor.RFC
+	return s, c, m, original.Timbre().UUID
+}
+
+func TestCancelarComprobante(t *testing.T) {
+	casos := []struct {
+		nombre             string
+		total              string
+		horas              time.Duration // Horas después de ahoraPrueba en que se solicita la cancelación.
+		preparar           func(t *testing.T, s *Simulador, m *xmlstructures.ComprobanteMgo, folio *Folio)
+		estatusUUID        string
+		estado             string
+		estatusCancelacion string
+	}{
+		{"sin aceptación", "100.00", 0, nil, EstatusSolicitudRecibida, EstadoCancelado, CanceladoSinAceptacion},
+		{"en proceso", "5000.00", 25, nil, EstatusSolicitudRecibida, EstadoVigente, CancelacionEnProceso},
+		{"previamente cancelado", "100.00", 0, func(t *testing.T, s *Simulador, m *xmlstructures.ComprobanteMgo, folio *Folio) {
+			if _, err := s.Cancel(context.Background(), SolicitudCancelacion{RFCEmisor: m.CFDIEmisorMgo.RFC, Folios: []Folio{*folio}}); err != nil {
+				t.Fatal(err)
+			}
+		}, EstatusPreviamenteCancelado, EstadoCancelado, ""},
+		{"no corresponde al emisor", "100.00", 0, func(t *testing.T, s *Simulador, m *xmlstructures.ComprobanteMgo, folio *Folio) {
+			// El CSD sólo firma solicitudes de su RFC: el folio se registra como timbrado para otro emisor.
+			s.timbrados[strings.ToUpper(folio.UUID)].rfcEmisor = "EKU9003173C9"
+		}, EstatusNoCorrespondeEmisor, "", ""},
+		{"no existe", "100.00", 0, func(t *testing.T, s *Simulador, m *xmlstructures.ComprobanteMgo, folio *Folio) {
+			folio.UUID = "00000000-0000-4000-8000-000000000000"
+		}, EstatusNoExiste, "", ""},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			reloj := ahoraPrueba
+			s, c, m, uuid := cancelacionPrueba(t, &reloj, caso.total)
+			reloj = ahoraPrueba.Add(caso.horas * time.Hour)
+			folio := Folio{UUID: uuid, Motivo: MotivoErroresSinRelacion}
+			if caso.preparar != nil {
+				caso.preparar(t, s, m, &folio)
+			}
+			err := c.CancelarComprobante(context.Background(), m, folio)
+			rechazado := caso.estatusUUID != EstatusSolicitudRecibida && caso.estatusUUID != EstatusPreviamenteCancelado
+			if rechazado != (codigoPAC(err) == caso.estatusUUID) || !rechazado && err != nil {
+				t.Fatalf("se esperaba EstatusUUID %s, se obtuvo el error %v", caso.estatusUUID, err)
+			}
+			r := m.Cancelacion
+			if r.UUID != folio.UUID || r.Motivo != folio.Motivo || r.EstatusUUID != caso.estatusUUID || len(r.Acuse) == 0 {
+				t.Errorf("registro %+v", r)
+			}
+			if r.Estado != caso.estado || r.EstatusCancelacion != caso.estatusCancelacion {
+				t.Errorf("Estado %q y EstatusCancelacion %q, se esperaba %q y %q", r.Estado, r.EstatusCancelacion, caso.estado, caso.estatusCancelacion)
+			}
+			if !r.FechaSolicitud.Equal(reloj) {
+				t.Errorf("FechaSolicitud %v, se esperaba %v", r.FechaSolicitud, reloj)
+			}
+		})
+	}
+}
+
+func TestActualizarComprobante(t *testing.T) {
+	reloj := ahoraPrueba
+	_, c, m, _ := cancelacionPrueba(t, &reloj, "100.00")
+	if _, err := c.ActualizarComprobante(context.Background(), m); err != ErrSinSolicitud {
+		t.Errorf("sin solicitud: se esperaba ErrSinSolicitud, se obtuvo %v", err)
+	}
+
+	casos := []struct {
+		nombre             string
+		aceptar            bool
+		estado             string
+		estatusCancelacion string
+	}{
+		{"aceptada", true, EstadoCancelado, CanceladoConAceptacion},
+		{"rechazada", false, EstadoVigente, SolicitudRechazada},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			reloj := ahoraPrueba
+			s, c, m, uuid := cancelacionPrueba(t, &reloj, "5000.00")
+			// Pasadas 24 horas, el comprobante de más de 1000 pesos requiere la aceptación del receptor.
+			reloj = ahoraPrueba.Add(25 * time.Hour)
+			if err := c.CancelarComprobante(context.Background(), m, Folio{UUID: uuid, Motivo: MotivoErroresSinRelacion}); err != nil {
+				t.Fatal(err)
+			}
+			reloj = reloj.Add(time.Hour)
+			e, err := c.ActualizarComprobante(context.Background(), m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := m.Cancelacion
+			if e.Estado != EstadoVigente || r.Estado != EstadoVigente || r.EsCancelable != CancelableConAceptacion || r.EstatusCancelacion != CancelacionEnProceso {
+				t.Errorf("en proceso: %+v", r)
+			}
+			if !r.FechaConsulta.Equal(reloj) {
+				t.Errorf("FechaConsulta %v, se esperaba %v", r.FechaConsulta, reloj)
+			}
+
+			if err := s.ResponderCancelacion(uuid, caso.aceptar); err != nil {
+				t.Fatal(err)
+			}
+			reloj = reloj.Add(time.Hour)
+			if _, err := c.ActualizarComprobante(context.Background(), m); err != nil {
+				t.Fatal(err)
+			}
+			r = m.Cancelacion
+			if r.Estado != caso.estado || r.EstatusCancelacion != caso.estatusCancelacion || !r.FechaConsulta.Equal(reloj) {
+				t.Errorf("Estado %q y EstatusCancelacion %q, se esperaba %q y %q", r.Estado, r.EstatusCancelacion, caso.estado, caso.estatusCancelacion)
+			}
+			if r.EstatusUUID != EstatusSolicitudRecibida || len(r.Acuse) == 0 {
+				t.Errorf("la consulta cambió los datos del acuse: %+v", r)
+			}
+			if err := s.ResponderCancelacion(uuid, caso.aceptar); codigoPAC(err) != CodigoComprobanteNoEncontrado {
+				t.Errorf("responder dos veces: %v", err)
+			}
+		})
+	}
+}
+
+func TestLeerAcuse(t *testing.T) {
+	datos := []byte(`<Acuse xmlns="` + xmlstructures.NamespaceCancelacion + `" Fecha="2026-10-18T13:01:00" RfcEmisor="AAA010101AAA">` +
+		`<Folios><UUID>ED1752FE-E865-4FF2-BFE1-0F552E770DC9</UUID><EstatusUUID>201</EstatusUUID><EstatusCancelacion>En proceso</EstatusCancelacion></Folios>` +
+		`<Folios><UUID>00000000-0000-4000-8000-000000000000</UUID><EstatusUUID>205</EstatusUUID></Folios></Acuse>`)
+	acuse, err := LeerAcuse(datos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperados := []FolioCancelado{
+		{UUID: "ED1752FE-E865-4FF2-BFE1-0F552E770DC9", EstatusUUID: EstatusSolicitudRecibida, EstatusCancelacion: CancelacionEnProceso},
+		{UUID: "00000000-0000-4000-8000-000000000000", EstatusUUID: EstatusNoExiste},
+	}
+	if acuse.Fecha != "2026-10-18T13:01:00" || acuse.RFCEmisor != "AAA010101AAA" || string(acuse.XML) != string(datos) || len(acuse.Folios) != len(esperados) {
+		t.Fatalf("acuse %+v", acuse)
+	}
+	for i, f := range acuse.Folios {
+		if f != esperados[i] {
+			t.Errorf("folio %d: %+v", i, f)
+		}
+	}
+
+	// Si el SAT rechaza la solicitud completa el acuse no trae folios.
+	_, err = LeerAcuse([]byte(`<Acuse xmlns="` + xmlstructures.NamespaceCancelacion + `" Fecha="2026-10-18T13:01:00" RfcEmisor="AAA010101AAA" CodEstatus="305"/>`))
+	var e *ErrorPAC
+	if !errors.As(err, &e) || e.Mensaje != "305" {
+		t.Errorf("acuse sin folios: se esperaba un *ErrorPAC con el CodEstatus, se obtuvo %v", err)
+	}
+	if _, err := LeerAcuse([]byte(`<Acuse>`)); err == nil || errors.As(err, &e) {
+		t.Errorf("acuse mal formado: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+// Package pac Cliente para timbrar, consultar y cancelar comprobantes con un Proveedor Autorizado de Certificación (PAC).
+//
+// Stamper es la interfaz común a los adaptadores de cada protocolo: ClienteSOAP para el servicio SOAP de Finkok y de los PAC que publican su mismo WSDL, y ClienteREST para el protocolo JSON del Simulador. Otros PAC se integran implementando Stamper. Simulador es un PAC local para pruebas que valida el comprobante, lo timbra con un certificado de prueba y reproduce los códigos de error de los PAC; puede usarse directamente o servirse por HTTP con el protocolo de ClienteREST.
+//
+// Cancelador firma las solicitudes de cancelación con el CSD del emisor, las envía por cualquier Stamper y registra el acuse y el estado de la cancelación en el ComprobanteMgo.
+package pac
+
+import (
+	"context"
+	"fmt"
+
+	xmlstructures ".."
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Interfaz común de los PAC
+*
+*
+****************************************************************************************************************************************/
+
+// Stamper Operaciones que ofrece un PAC. Los errores del PAC se devuelven como *ErrorPAC; los de comunicación, tal como los produce el transporte.
+type Stamper interface {
+	// Stamp Timbra el comprobante sellado cfdi y devuelve el XML con el Timbre Fiscal Digital.
+	Stamp(ctx context.Context, cfdi []byte) (*Respuesta, error)
+	// Status Consulta en el SAT el estado de un comprobante timbrado.
+	Status(ctx context.Context, consulta Consulta) (*Estado, error)
+	// Cancel Solicita la cancelación de uno o varios comprobantes de un mismo emisor.
+	Cancel(ctx context.Context, solicitud SolicitudCancelacion) (*AcuseCancelacion, error)
+}
+
+// Respuesta Resultado de timbrar un comprobante.
+type Respuesta struct {
+	XML    []byte                   // Comprobante timbrado, tal como lo devolvió el PAC.
+	Timbre xmlstructures.CFDITimbre // Timbre Fiscal Digital que agregó el PAC.
+}
+
+// Consulta Datos con que el SAT identifica un comprobante en el servicio de consulta de estado.
+type Consulta struct {
+	UUID        string `json:"uuid"`        // Folio fiscal del comprobante.
+	RFCEmisor   string `json:"rfcEmisor"`   // RFC del emisor.
+	RFCReceptor string `json:"rfcReceptor"` // RFC del receptor.
+	Total       string `json:"total"`       // Total del comprobante tal como aparece en el XML.
+}
+
+// Valores de Estado.Estado.
+const (
+	EstadoVigente      = "Vigente"
+	EstadoCancelado    = "Cancelado"
+	EstadoNoEncontrado = "No Encontrado"
+)
+
+// Valores de Estado.EsCancelable.
+const (
+	CancelableSinAceptacion = "Cancelable sin aceptación"
+	CancelableConAceptacion = "Cancelable con aceptación"
+	NoCancelable            = "No cancelable"
+)
+
+// Estado Respuesta del servicio de consulta de estado del SAT.
+type Estado struct {
+	CodigoEstatus      string `json:"codigoEstatus"`      // Resultado de la consulta, p.ej. "S - Comprobante obtenido satisfactoriamente.".
+	Estado             string `json:"estado"`             // Vigente, Cancelado o No Encontrado.
+	EsCancelable       string `json:"esCancelable"`       // Cancelable sin aceptación, Cancelable con aceptación o No cancelable.
+	EstatusCancelacion string `json:"estatusCancelacion"` // Estado de una solicitud de cancelación en curso, p.ej. En proceso; vacío si no la hay.
+}
+
+// Folio Comprobante que se solicita cancelar.
+type Folio struct {
+	UUID             string `json:"uuid"`                       // Folio fiscal del comprobante.
+	Motivo           string `json:"motivo"`                     // Clave del motivo de cancelación: 01 a 04.
+	FolioSustitucion string `json:"folioSustitucion,omitempty"` // UUID del comprobante que sustituye al cancelado; requerido con el motivo 01.
+}
+
+// SolicitudCancelacion Comprobantes de un emisor que se solicita cancelar.
+type SolicitudCancelacion struct {
+	RFCEmisor string  `json:"rfcEmisor"`     // RFC del emisor de los comprobantes.
+	Folios    []Folio `json:"folios"`        // Comprobantes a cancelar.
+	XML       []byte  `json:"xml,omitempty"` // Solicitud firmada por el emisor con su CSD; la requieren los PAC que no reciben el certificado y la llave.
+}
+
+// FolioCancelado Resultado de la solicitud para un comprobante.
+type FolioCancelado struct {
+	UUID               string `json:"uuid"`                         // Folio fiscal del comprobante.
+	EstatusUUID        string `json:"estatusUUID"`                  // Código del SAT: 201 solicitud recibida, 202 previamente cancelado, 203 no corresponde al emisor, 205 no existe.
+	EstatusCancelacion string `json:"estatusCancelacion,omitempty"` // Estado de la cancelación, p.ej. Cancelado sin aceptación o En proceso.
+}
+
+// AcuseCancelacion Acuse que devuelve el SAT por una solicitud de cancelación.
+type AcuseCancelacion struct {
+	RFCEmisor string           `json:"rfcEmisor"`     // RFC del emisor.
+	Fecha     string           `json:"fecha"`         // Fecha en que el SAT recibió la solicitud.
+	Folios    []FolioCancelado `json:"folios"`        // Resultado por comprobante, en el orden de la solicitud.
+	XML       []byte           `json:"xml,omitempty"` // Acuse firmado por el SAT, si el PAC lo devuelve.
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Errores de los PAC
+*
+*
+****************************************************************************************************************************************/
+
+// Códigos de error comunes a los PAC, definidos en el Anexo 20.
+const (
+	CodigoXMLMalFormado           = "301"
+	CodigoSelloInvalido           = "302"
+	CodigoSelloNoCorresponde      = "303"
+	CodigoCertificadoRevocado     = "304"
+	CodigoFechaFueraDeVigencia    = "305"
+	CodigoNoEsCSD                 = "306"
+	CodigoTimbrePrevio            = "307"
+	CodigoCertificadoNoSAT        = "308"
+	CodigoFechaFueraDeRango       = "401"
+	CodigoRFCNoInscrito           = "402"
+	CodigoFechaAnterior2012       = "403"
+	CodigoAutenticacion           = "501"
+	CodigoComprobanteNoEncontrado = "602"
+)
+
+// MensajesPAC Descripción de cada código de error de los PAC.
+var MensajesPAC = map[string]string{
+	CodigoXMLMalFormado:           "XML mal formado.",
+	CodigoSelloInvalido:           "Sello mal formado o inválido.",
+	CodigoSelloNoCorresponde:      "Sello no corresponde a emisor.",
+	CodigoCertificadoRevocado:     "Certificado revocado o caduco.",
+	CodigoFechaFueraDeVigencia:    "La fecha de emisión no está dentro de la vigencia del CSD del emisor.",
+	CodigoNoEsCSD:                 "El certificado no es de tipo CSD.",
+	CodigoTimbrePrevio:            "El CFDI contiene un timbre previo.",
+	CodigoCertificadoNoSAT:        "Certificado no expedido por el SAT.",
+	CodigoFechaFueraDeRango:       "Fecha y hora de generación fuera de rango.",
+	CodigoRFCNoInscrito:           "RFC del emisor no se encuentra en el régimen de contribuyentes.",
+	CodigoFechaAnterior2012:       "La fecha de emisión no es posterior al 01 de enero de 2012.",
+	CodigoAutenticacion:           "Autenticación no válida.",
+	CodigoComprobanteNoEncontrado: "Comprobante no encontrado.",
+}
+
+// ErrorPAC Error informado por el PAC. Codigo es el código del PAC (p.ej. 307) o el de la matriz de errores del Anexo 20 (p.ej. CFDI33106).
+type ErrorPAC struct {
+	Codigo  string // Código de error.
+	Mensaje string // Descripción devuelta por el PAC.
+}
+
+func (e *ErrorPAC) Error() string {
+	return fmt.Sprintf("pac: %s: %s", e.Codigo, e.Mensaje)
+}
+
+// nuevoError Devuelve un *ErrorPAC con el mensaje de MensajesPAC para codigo.
+func nuevoError(codigo string) *ErrorPAC {
+	return &ErrorPAC{Codigo: codigo, Mensaje: MensajesPAC[codigo]}
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Timbrado de un Comprobante
+*
+*
+****************************************************************************************************************************************/
+
+// Timbrar Serializa el comprobante sellado c, lo timbra con s y reemplaza c por el comprobante timbrado que devolvió el PAC. Si el PAC devuelve un error c queda sin cambios.
+func Timbrar(ctx context.Context, s Stamper, c *xmlstructures.Comprobante) (*Respuesta, error) {
+	cfdi, err := xmlstructures.Marshal(*c)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.Stamp(ctx, cfdi)
+	if err != nil {
+		return nil, err
+	}
+	var timbrado xmlstructures.Comprobante
+	if err := xmlstructures.Unmarshal(r.XML, &timbrado); err != nil {
+		return nil, err
+	}
+	*c = timbrado
+	return r, nil
+}
+
+// leerRespuesta Interpreta el comprobante timbrado que devolvió un PAC.
+func leerRespuesta(cfdi []byte) (*Respuesta, error) {
+	var c xmlstructures.Comprobante
+	if err := xmlstructures.Unmarshal(cfdi, &c); err != nil {
+		return nil, err
+	}
+	t := c.Timbre()
+	if t == nil {
+		return nil, fmt.Errorf("pac: %w", xmlstructures.ErrSinTimbre)
+	}
+	return &Respuesta{XML: cfdi, Timbre: *t}, nil
+}
@@ -0,0 +1,108 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Adaptador REST
+*
+*
+****************************************************************************************************************************************/
+
+// Rutas del protocolo REST, relativas a ClienteREST.URL.
+const (
+	rutaTimbrar  = "/timbrar"
+	rutaEstado   = "/estado"
+	rutaCancelar = "/cancelar"
+)
+
+// mensajeTimbrado Cuerpo de la solicitud y de la respuesta de /timbrar. encoding/json codifica XML en base 64.
+type mensajeTimbrado struct {
+	XML []byte `json:"xml"` // Comprobante sellado en la solicitud; timbrado en la respuesta.
+}
+
+// mensajeError Cuerpo de las respuestas con error.
+type mensajeError struct {
+	Codigo  string `json:"codigo"`  // Código de error del PAC.
+	Mensaje string `json:"mensaje"` // Descripción del error.
+}
+
+// ClienteREST Adaptador para el protocolo JSON sobre HTTP que sirve Simulador: POST a /timbrar, /estado y /cancelar con autenticación Bearer. No corresponde a la API de ningún PAC comercial; sirve para probar una aplicación completa contra el Simulador o contra un servicio propio que exponga el mismo protocolo. Para la API REST de un PAC se escribe un Stamper con sus rutas y mensajes.
+type ClienteREST struct {
+	URL   string       // Dirección base del servicio, p.ej. https://pac.example.com/api/v1.
+	Token string       // Token de acceso que se envía en el encabezado Authorization.
+	HTTP  *http.Client // Cliente HTTP; http.DefaultClient si es nil.
+}
+
+// Stamp Timbra cfdi.
+func (c *ClienteREST) Stamp(ctx context.Context, cfdi []byte) (*Respuesta, error) {
+	var respuesta mensajeTimbrado
+	if err := c.enviar(ctx, rutaTimbrar, mensajeTimbrado{XML: cfdi}, &respuesta); err != nil {
+		return nil, err
+	}
+	return leerRespuesta(respuesta.XML)
+}
+
+// Status Consulta el estado de un comprobante.
+func (c *ClienteREST) Status(ctx context.Context, consulta Consulta) (*Estado, error) {
+	estado := &Estado{}
+	if err := c.enviar(ctx, rutaEstado, consulta, estado); err != nil {
+		return nil, err
+	}
+	return estado, nil
+}
+
+// Cancel Solicita la cancelación de los folios de solicitud.
+func (c *ClienteREST) Cancel(ctx context.Context, solicitud SolicitudCancelacion) (*AcuseCancelacion, error) {
+	acuse := &AcuseCancelacion{}
+	if err := c.enviar(ctx, rutaCancelar, solicitud, acuse); err != nil {
+		return nil, err
+	}
+	return acuse, nil
+}
+
+// enviar Envía solicitud en JSON a ruta y decodifica la respuesta en respuesta. Las respuestas con error del PAC se devuelven como *ErrorPAC.
+func (c *ClienteREST) enviar(ctx context.Context, ruta string, solicitud, respuesta interface{}) error {
+	cuerpo, err := json.Marshal(solicitud)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.URL, "/")+ruta, bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	cliente := c.HTTP
+	if cliente == nil {
+		cliente = http.DefaultClient
+	}
+	resp, err := cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	datos, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e mensajeError
+		if json.Unmarshal(datos, &e) == nil && e.Codigo != "" {
+			return &ErrorPAC{Codigo: e.Codigo, Mensaje: e.Mensaje}
+		}
+		return fmt.Errorf("pac: %s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return json.Unmarshal(datos, respuesta)
+}
@@ -0,0 +1,178 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Adaptador SOAP
+*
+*
+****************************************************************************************************************************************/
+
+// nsSOAP Espacio de nombres del sobre SOAP 1.1.
+const nsSOAP = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// Espacios de nombres de las operaciones en el WSDL de Finkok, que ClienteSOAP usa si no se indican otros.
+const (
+	NamespaceTimbradoFinkok    = "http://facturacion.finkok.com/stamp"
+	NamespaceCancelacionFinkok = "http://facturacion.finkok.com/cancel"
+)
+
+// ErrSolicitudSinFirmar Indica que se intentó cancelar por SOAP sin la solicitud firmada por el emisor.
+var ErrSolicitudSinFirmar = errors.New("pac: el servicio SOAP requiere la solicitud de cancelación firmada en SolicitudCancelacion.XML")
+
+// ClienteSOAP Adaptador para el servicio SOAP de timbrado y cancelación de Finkok: operaciones stamp, get_sat_status y cancel_signature con usuario y contraseña, con los parámetros y respuestas de su WSDL. Sirve también para los PAC que publican ese mismo WSDL bajo otro espacio de nombres, indicándolo en NamespaceTimbrado y NamespaceCancelacion; los PAC con otro WSDL requieren su propio Stamper.
+type ClienteSOAP struct {
+	URLTimbrado          string       // Dirección del servicio de timbrado, p.ej. https://demo-facturacion.finkok.com/servicios/soap/stamp.
+	URLCancelacion       string       // Dirección del servicio de cancelación y consulta de estado.
+	Usuario              string       // Usuario de la cuenta en el PAC.
+	Contrasena           string       // Contraseña de la cuenta en el PAC.
+	NamespaceTimbrado    string       // Espacio de nombres de la operación stamp; NamespaceTimbradoFinkok si está vacío.
+	NamespaceCancelacion string       // Espacio de nombres de get_sat_status y cancel_signature; NamespaceCancelacionFinkok si está vacío.
+	HTTP                 *http.Client // Cliente HTTP; http.DefaultClient si es nil.
+}
+
+// incidenciaSOAP Error que informa el servicio de timbrado.
+type incidenciaSOAP struct {
+	CodigoError       string `xml:"CodigoError"`
+	MensajeIncidencia string `xml:"MensajeIncidencia"`
+}
+
+// Stamp Timbra cfdi con la operación stamp.
+func (c *ClienteSOAP) Stamp(ctx context.Context, cfdi []byte) (*Respuesta, error) {
+	var resultado struct {
+		XML         string           `xml:"Body>stampResponse>stampResult>xml"`
+		Incidencias []incidenciaSOAP `xml:"Body>stampResponse>stampResult>Incidencias>Incidencia"`
+	}
+	err := c.llamar(ctx, c.URLTimbrado, valorOmision(c.NamespaceTimbrado, NamespaceTimbradoFinkok), "stamp", &resultado,
+		"xml", base64.StdEncoding.EncodeToString(cfdi),
+		"username", c.Usuario,
+		"password", c.Contrasena)
+	if err != nil {
+		return nil, err
+	}
+	if len(resultado.Incidencias) > 0 {
+		return nil, &ErrorPAC{Codigo: resultado.Incidencias[0].CodigoError, Mensaje: resultado.Incidencias[0].MensajeIncidencia}
+	}
+	return leerRespuesta([]byte(resultado.XML))
+}
+
+// Status Consulta el estado de un comprobante con la operación get_sat_status.
+func (c *ClienteSOAP) Status(ctx context.Context, consulta Consulta) (*Estado, error) {
+	var resultado struct {
+		Estado Estado `xml:"Body>get_sat_statusResponse>get_sat_statusResult>sat"`
+		Error  string `xml:"Body>get_sat_statusResponse>get_sat_statusResult>error"`
+	}
+	err := c.llamar(ctx, c.URLCancelacion, valorOmision(c.NamespaceCancelacion, NamespaceCancelacionFinkok), "get_sat_status", &resultado,
+		"username", c.Usuario,
+		"password", c.Contrasena,
+		"taxpayer_id", consulta.RFCEmisor,
+		"rtaxpayer_id", consulta.RFCReceptor,
+		"uuid", consulta.UUID,
+		"total", consulta.Total)
+	if err != nil {
+		return nil, err
+	}
+	if resultado.Error != "" {
+		return nil, &ErrorPAC{Mensaje: resultado.Error}
+	}
+	return &resultado.Estado, nil
+}
+
+// Cancel Envía la solicitud firmada solicitud.XML con la operación cancel_signature.
+func (c *ClienteSOAP) Cancel(ctx context.Context, solicitud SolicitudCancelacion) (*AcuseCancelacion, error) {
+	if len(solicitud.XML) == 0 {
+		return nil, ErrSolicitudSinFirmar
+	}
+	var resultado struct {
+		Folios []struct {
+			UUID               string `xml:"UUID"`
+			EstatusUUID        string `xml:"EstatusUUID"`
+			EstatusCancelacion string `xml:"EstatusCancelacion"`
+		} `xml:"Body>cancel_signatureResponse>cancel_signatureResult>Folios>Folio"`
+		Acuse      string `xml:"Body>cancel_signatureResponse>cancel_signatureResult>Acuse"`
+		Fecha      string `xml:"Body>cancel_signatureResponse>cancel_signatureResult>Fecha"`
+		RFCEmisor  string `xml:"Body>cancel_signatureResponse>cancel_signatureResult>RfcEmisor"`
+		CodEstatus string `xml:"Body>cancel_signatureResponse>cancel_signatureResult>CodEstatus"`
+	}
+	err := c.llamar(ctx, c.URLCancelacion, valorOmision(c.NamespaceCancelacion, NamespaceCancelacionFinkok), "cancel_signature", &resultado,
+		"xml", base64.StdEncoding.EncodeToString(solicitud.XML),
+		"username", c.Usuario,
+		"password", c.Contrasena)
+	if err != nil {
+		return nil, err
+	}
+	if len(resultado.Folios) == 0 {
+		return nil, &ErrorPAC{Mensaje: resultado.CodEstatus}
+	}
+	acuse := &AcuseCancelacion{RFCEmisor: resultado.RFCEmisor, Fecha: resultado.Fecha, XML: []byte(resultado.Acuse)}
+	for _, f := range resultado.Folios {
+		acuse.Folios = append(acuse.Folios, FolioCancelado{UUID: f.UUID, EstatusUUID: f.EstatusUUID, EstatusCancelacion: f.EstatusCancelacion})
+	}
+	return acuse, nil
+}
+
+// valorOmision Devuelve valor, u omision si valor está vacío.
+func valorOmision(valor, omision string) string {
+	if valor == "" {
+		return omision
+	}
+	return valor
+}
+
+// llamar Envía la operacion con los parámetros dados como pares nombre, valor y decodifica el sobre de respuesta en resultado. Los Fault de SOAP se devuelven como *ErrorPAC.
+func (c *ClienteSOAP) llamar(ctx context.Context, url, ns, operacion string, resultado interface{}, parametros ...string) error {
+	var cuerpo bytes.Buffer
+	cuerpo.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	cuerpo.WriteString(`<soapenv:Envelope xmlns:soapenv="` + nsSOAP + `" xmlns:op="` + ns + `"><soapenv:Body><op:` + operacion + `>`)
+	for i := 0; i+1 < len(parametros); i += 2 {
+		cuerpo.WriteString("<op:" + parametros[i] + ">")
+		if err := xml.EscapeText(&cuerpo, []byte(parametros[i+1])); err != nil {
+			return err
+		}
+		cuerpo.WriteString("</op:" + parametros[i] + ">")
+	}
+	cuerpo.WriteString(`</op:` + operacion + `></soapenv:Body></soapenv:Envelope>`)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &cuerpo)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", `"`+strings.TrimSuffix(ns, "/")+"/"+operacion+`"`)
+	cliente := c.HTTP
+	if cliente == nil {
+		cliente = http.DefaultClient
+	}
+	resp, err := cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	datos, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var fault struct {
+		Codigo  string `xml:"Body>Fault>faultcode"`
+		Mensaje string `xml:"Body>Fault>faultstring"`
+	}
+	if err := xml.Unmarshal(datos, &fault); err == nil && fault.Mensaje != "" {
+		return &ErrorPAC{Codigo: fault.Codigo, Mensaje: fault.Mensaje}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pac: %s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return xml.Unmarshal(datos, resultado)
+}
@@ -0,0 +1,84 @@
+package pac
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// servidorSOAP Devuelve un servidor que responde cada solicitud con respuesta y guarda en *cuerpo y *accion el cuerpo y el encabezado SOAPAction recibidos.
+func servidorSOAP(t *testing.T, respuesta string, cuerpo, accion *string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		datos, _ := io.ReadAll(r.Body)
+		*cuerpo, *accion = string(datos), r.Header.Get("SOAPAction")
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+respuesta+`</soap:Body></soap:Envelope>`)
+	}))
+}
+
+func TestClienteSOAPNamespace(t *testing.T) {
+	var cuerpo, accion string
+	servidor := servidorSOAP(t, `<s0:stampResponse xmlns:s0="urn:otro"><s0:stampResult><s0:Incidencias><s0:Incidencia><s0:CodigoError>307</s0:CodigoError><s0:MensajeIncidencia>El CFDI contiene un timbre previo.</s0:MensajeIncidencia></s0:Incidencia></s0:Incidencias></s0:stampResult></s0:stampResponse>`, &cuerpo, &accion)
+	defer servidor.Close()
+
+	casos := []struct {
+		cliente   ClienteSOAP
+		namespace string
+	}{
+		{ClienteSOAP{}, NamespaceTimbradoFinkok},
+		{ClienteSOAP{NamespaceTimbrado: "http://pac.example.com/stamp/"}, "http://pac.example.com/stamp/"},
+	}
+	for _, caso := range casos {
+		cliente := caso.cliente
+		cliente.URLTimbrado, cliente.Usuario, cliente.Contrasena, cliente.HTTP = servidor.URL, "usuario", "a<b", servidor.Client()
+		_, err := cliente.Stamp(context.Background(), []byte("<cfdi/>"))
+		if codigoPAC(err) != CodigoTimbrePrevio {
+			t.Errorf("%s: se esperaba la incidencia 307, se obtuvo %v", caso.namespace, err)
+		}
+		for _, esperado := range []string{`xmlns:op="` + caso.namespace + `"`, "<op:stamp>", "<op:xml>" + base64.StdEncoding.EncodeToString([]byte("<cfdi/>")) + "</op:xml>", "<op:password>a&lt;b</op:password>"} {
+			if !strings.Contains(cuerpo, esperado) {
+				t.Errorf("%s: la solicitud no contiene %s:\n%s", caso.namespace, esperado, cuerpo)
+			}
+		}
+		if accion != `"`+strings.TrimSuffix(caso.namespace, "/")+`/stamp"` {
+			t.Errorf("%s: SOAPAction %s", caso.namespace, accion)
+		}
+	}
+}
+
+func TestClienteSOAPCancel(t *testing.T) {
+	var cuerpo, accion string
+	servidor := servidorSOAP(t, `<cancel_signatureResponse><cancel_signatureResult><Folios><Folio><UUID>ED1752FE-E865-4FF2-BFE1-0F552E770DC9</UUID><EstatusUUID>201</EstatusUUID><EstatusCancelacion>En proceso</EstatusCancelacion></Folio></Folios><Acuse>&lt;Acuse/&gt;</Acuse><Fecha>2026-10-17T12:01:00</Fecha><RfcEmisor>AAA010101AAA</RfcEmisor></cancel_signatureResult></cancel_signatureResponse>`, &cuerpo, &accion)
+	defer servidor.Close()
+	cliente := &ClienteSOAP{URLCancelacion: servidor.URL, HTTP: servidor.Client(), NamespaceCancelacion: "urn:pac:cancel"}
+
+	if _, err := cliente.Cancel(context.Background(), SolicitudCancelacion{}); err != ErrSolicitudSinFirmar {
+		t.Errorf("sin solicitud firmada: %v", err)
+	}
+	acuse, err := cliente.Cancel(context.Background(), SolicitudCancelacion{XML: []byte("<Cancelacion/>")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperado := FolioCancelado{UUID: "ED1752FE-E865-4FF2-BFE1-0F552E770DC9", EstatusUUID: EstatusSolicitudRecibida, EstatusCancelacion: CancelacionEnProceso}
+	if len(acuse.Folios) != 1 || acuse.Folios[0] != esperado || string(acuse.XML) != "<Acuse/>" || acuse.RFCEmisor != "AAA010101AAA" {
+		t.Errorf("acuse %+v", acuse)
+	}
+	if !strings.Contains(cuerpo, `xmlns:op="urn:pac:cancel"`) || accion != `"urn:pac:cancel/cancel_signature"` {
+		t.Errorf("la solicitud no usa el espacio de nombres configurado: %s %s", accion, cuerpo)
+	}
+}
+
+func TestClienteSOAPFault(t *testing.T) {
+	var cuerpo, accion string
+	servidor := servidorSOAP(t, `<soap:Fault><faultcode>soap:Server</faultcode><faultstring>Usuario o contraseña inválidos</faultstring></soap:Fault>`, &cuerpo, &accion)
+	defer servidor.Close()
+	cliente := &ClienteSOAP{URLCancelacion: servidor.URL, HTTP: servidor.Client()}
+	if _, err := cliente.Status(context.Background(), Consulta{}); codigoPAC(err) != "soap:Server" {
+		t.Errorf("se esperaba el Fault como *ErrorPAC, se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	xmlstructures ".."
+)
+
+/****************************************************************************************************************************************
+*
+*
+* PAC simulado para pruebas
+*
+*
+****************************************************************************************************************************************/
+
+// RFCProvCertifPruebas RFC con que el Simulador firma los timbres si no se indica otro.
+const RFCProvCertifPruebas = "SAT970701NN3"
+
+// zonaSAT Zona horaria de FechaTimbrado y de la fecha del acuse: la del centro de México, sin horario de verano.
+var zonaSAT = time.FixedZone("CST", -6*60*60)
+
+// Simulador PAC local para pruebas. Timbra comprobantes con un certificado de prueba después de revisar, en el mismo orden que un PAC, su estructura, sello, certificado (un certificado caducado al momento de timbrar produce el código 304; el simulador no consulta revocaciones) y fecha, y las reglas de xmlstructures.Validate. Lleva el registro de los comprobantes que timbró para responder a Status y Cancel. Es seguro usarlo desde varias goroutines.
+type Simulador struct {
+	CSD           *xmlstructures.CSD // Certificado con que se firman los timbres; en las pruebas se agrega a un xmlstructures.AlmacenCertificados para verificarlos.
+	RFCProvCertif string             // RFC del proveedor que se escribe en los timbres.
+	Token         string             // Si no está vacío, el servidor HTTP exige el encabezado Authorization: Bearer Token.
+	Ahora         func() time.Time   // Reloj con que se fechan los timbres y se revisa la fecha de expedición; time.Now si es nil.
+
+	mu                  sync.Mutex
+	timbrados           map[string]*registroSimulador // Por UUID.
+	sellos              map[string]string             // UUID de cada sello timbrado, para detectar comprobantes duplicados.
+	forzados            []string                      // Códigos que devuelven las siguientes llamadas a Stamp.
+	forzadosCancelacion []string                      // Códigos que devuelven las siguientes llamadas a Cancel.
+}
+
+// registroSimulador Datos de un comprobante timbrado por el Simulador.
+type registroSimulador struct {
+	rfcEmisor     string
+	rfcReceptor   string
+	total         xmlstructures.Decimal
+	tipo          string
+	fechaTimbrado time.Time
+	estatus       string // Estatus de cancelación; vacío si no se ha solicitado.
+}
+
+// montoSinAceptacion Total hasta el que un comprobante de ingreso o egreso se cancela sin aceptación del receptor.
+var montoSinAceptacion = xmlstructures.NewDecimal(1000, 0)
+
+// conAceptacion Indica si la cancelación del comprobante requiere la aceptación del receptor en la fecha ahora. El simulador aplica sólo las reglas más comunes: no la requieren los comprobantes de traslado, nómina o pago, los de total hasta montoSinAceptacion ni los que se cancelan dentro de las 24 horas siguientes al timbrado.
+func (r *registroSimulador) conAceptacion(ahora time.Time) bool {
+	return (r.tipo == "I" || r.tipo == "E") && r.total.Cmp(montoSinAceptacion) > 0 && ahora.Sub(r.fechaTimbrado) > 24*time.Hour
+}
+
+// cancelado Indica si el comprobante ya fue cancelado.
+func (r *registroSimulador) cancelado() bool {
+	return cancelado(r.estatus)
+}
+
+// NuevoSimulador Crea un Simulador que firma los timbres con csd.
+func NuevoSimulador(csd *xmlstructures.CSD) *Simulador {
+	return &Simulador{CSD: csd, RFCProvCertif: RFCProvCertifPruebas}
+}
+
+// ForzarError Hace que la siguiente llamada a Stamp falle con codigo sin revisar el comprobante. Varias llamadas se acumulan y se consumen en orden.
+func (s *Simulador) ForzarError(codigo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forzados = append(s.forzados, codigo)
+}
+
+// ForzarErrorCancelacion Como ForzarError, para la siguiente llamada a Cancel: falla con codigo sin revisar la solicitud ni cancelar ningún folio.
+func (s *Simulador) ForzarErrorCancelacion(codigo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forzadosCancelacion = append(s.forzadosCancelacion, codigo)
+}
+
+// ahora Devuelve la hora del reloj del simulador en la zona del SAT.
+func (s *Simulador) ahora() time.Time {
+	if s.Ahora != nil {
+		return s.Ahora().In(zonaSAT)
+	}
+	return time.Now().In(zonaSAT)
+}
+
+// Stamp Timbra cfdi. El timbre se inserta en el documento original sin volver a serializarlo, como lo hace un PAC.
+func (s *Simulador) Stamp(ctx context.Context, cfdi []byte) (*Respuesta, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.forzados) > 0 {
+		codigo := s.forzados[0]
+		s.forzados = s.forzados[1:]
+		return nil, nuevoError(codigo)
+	}
+
+	var c xmlstructures.Comprobante
+	if err := xmlstructures.Unmarshal(cfdi, &c); err != nil {
+		return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: err.Error()}
+	}
+	if c.Timbre() != nil {
+		return nil, nuevoError(CodigoTimbrePrevio)
+	}
+	if uuid, ok := s.sellos[c.Sello]; ok {
+		return nil, &ErrorPAC{Codigo: CodigoTimbrePrevio, Mensaje: "El comprobante ya fue timbrado con el UUID " + uuid + "."}
+	}
+	reporte, err := xmlstructures.VerificarSello(c)
+	if err != nil {
+		var errCadena *xmlstructures.ErrorCadena
+		if errors.As(err, &errCadena) {
+			return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: err.Error()}
+		}
+		return nil, &ErrorPAC{Codigo: CodigoCertificadoNoSAT, Mensaje: err.Error()}
+	}
+	ahora := s.ahora()
+	switch {
+	case !reporte.EsCSD:
+		return nil, nuevoError(CodigoNoEsCSD)
+	case !reporte.SelloValido, !reporte.NoCertificadoValido:
+		return nil, nuevoError(CodigoSelloInvalido)
+	case !reporte.RFCValido:
+		return nil, nuevoError(CodigoSelloNoCorresponde)
+	case ahora.After(reporte.Certificado.NotAfter):
+		return nil, nuevoError(CodigoCertificadoRevocado)
+	case !reporte.VigenteEnFecha:
+		return nil, nuevoError(CodigoFechaFueraDeVigencia)
+	}
+	fecha, err := time.ParseInLocation("2006-01-02T15:04:05", c.Fecha, zonaSAT)
+	switch {
+	case err != nil:
+		return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: err.Error()}
+	case fecha.Before(time.Date(2012, 1, 1, 0, 0, 0, 0, zonaSAT)):
+		return nil, nuevoError(CodigoFechaAnterior2012)
+	case fecha.Before(ahora.Add(-72 * time.Hour)), fecha.After(ahora.Add(5 * time.Minute)):
+		return nil, nuevoError(CodigoFechaFueraDeRango)
+	}
+	if errores := xmlstructures.Validate(c); len(errores) > 0 {
+		return nil, &ErrorPAC{Codigo: errores[0].Codigo, Mensaje: errores[0].Mensaje}
+	}
+
+	uuid, err := nuevoUUID()
+	if err != nil {
+		return nil, err
+	}
+	t := xmlstructures.CFDITimbre{
+		Version:          xmlstructures.VersionTFD,
+		UUID:             uuid,
+		FechaTimbrado:    ahora.Format("2006-01-02T15:04:05"),
+		RFCProvCertif:    s.RFCProvCertif,
+		SelloCFD:         c.Sello,
+		NoCertificadoSAT: s.CSD.NoCertificado,
+	}
+	if t.SelloSAT, err = s.CSD.Firmar([]byte(t.CadenaOriginal())); err != nil {
+		return nil, err
+	}
+	timbrado, err := insertarTimbre(cfdi, t)
+	if err != nil {
+		return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: err.Error()}
+	}
+	if s.timbrados == nil {
+		s.timbrados = map[string]*registroSimulador{}
+		s.sellos = map[string]string{}
+	}
+	s.timbrados[uuid] = &registroSimulador{rfcEmisor: c.Emisor.RFC, rfcReceptor: c.Receptor.RFC, total: c.Total, tipo: c.TipoDeComprobante, fechaTimbrado: ahora}
+	s.sellos[c.Sello] = uuid
+	return &Respuesta{XML: timbrado, Timbre: t}, nil
+}
+
+// Status Responde como el servicio de consulta del SAT: el comprobante sólo se encuentra si coinciden UUID, RFC del emisor, RFC del receptor y total.
+func (s *Simulador) Status(ctx context.Context, consulta Consulta) (*Estado, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.timbrados[strings.ToUpper(consulta.UUID)]
+	total, err := xmlstructures.ParseDecimal(consulta.Total)
+	if !ok || err != nil || r.rfcEmisor != consulta.RFCEmisor || r.rfcReceptor != consulta.RFCReceptor || r.total.Cmp(total) != 0 {
+		return &Estado{CodigoEstatus: "N - 602: Comprobante no encontrado.", Estado: EstadoNoEncontrado}, nil
+	}
+	e := &Estado{CodigoEstatus: "S - Comprobante obtenido satisfactoriamente.", Estado: EstadoVigente, EsCancelable: CancelableSinAceptacion, EstatusCancelacion: r.estatus}
+	if r.conAceptacion(s.ahora()) {
+		e.EsCancelable = CancelableConAceptacion
+	}
+	if r.cancelado() {
+		e.Estado = EstadoCancelado
+	}
+	return e, nil
+}
+
+// Cancel Cancela los comprobantes timbrados por el simulador. Los que no requieren aceptación se cancelan de inmediato; los demás quedan En proceso hasta que se llame a ResponderCancelacion. Si la solicitud incluye el XML firmado, se verifica su firma y los folios y el emisor se toman de él cuando no se indican.
+func (s *Simulador) Cancel(ctx context.Context, solicitud SolicitudCancelacion) (*AcuseCancelacion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if len(s.forzadosCancelacion) > 0 {
+		codigo := s.forzadosCancelacion[0]
+		s.forzadosCancelacion = s.forzadosCancelacion[1:]
+		s.mu.Unlock()
+		return nil, nuevoError(codigo)
+	}
+	s.mu.Unlock()
+	if len(solicitud.XML) > 0 {
+		firmada, err := xmlstructures.VerificarCancelacion(solicitud.XML)
+		if err != nil {
+			return nil, &ErrorPAC{Codigo: CodigoSelloInvalido, Mensaje: err.Error()}
+		}
+		if solicitud.RFCEmisor == "" {
+			solicitud.RFCEmisor = firmada.RFCEmisor
+		}
+		if len(solicitud.Folios) == 0 {
+			for _, f := range firmada.Folios {
+				solicitud.Folios = append(solicitud.Folios, Folio{UUID: f.UUID, Motivo: f.Motivo, FolioSustitucion: f.FolioSustitucion})
+			}
+		}
+		if !strings.EqualFold(solicitud.RFCEmisor, firmada.RFCEmisor) {
+			return nil, nuevoError(CodigoSelloNoCorresponde)
+		}
+	}
+	for _, f := range solicitud.Folios {
+		switch {
+		case f.Motivo < MotivoErroresConRelacion || f.Motivo > MotivoFacturaGlobal || len(f.Motivo) != 2:
+			return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: fmt.Sprintf("El motivo de cancelación %q del folio %s no es válido.", f.Motivo, f.UUID)}
+		case f.Motivo == MotivoErroresConRelacion && f.FolioSustitucion == "":
+			return nil, &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: fmt.Sprintf("El folio %s se cancela con el motivo 01 y no indica el folio que lo sustituye.", f.UUID)}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ahora := s.ahora()
+	acuse := &AcuseCancelacion{RFCEmisor: solicitud.RFCEmisor, Fecha: ahora.Format("2006-01-02T15:04:05")}
+	for _, f := range solicitud.Folios {
+		resultado := FolioCancelado{UUID: f.UUID}
+		r, ok := s.timbrados[strings.ToUpper(f.UUID)]
+		switch {
+		case !ok:
+			resultado.EstatusUUID = EstatusNoExiste
+		case r.rfcEmisor != solicitud.RFCEmisor:
+			resultado.EstatusUUID = EstatusNoCorrespondeEmisor
+		case r.cancelado():
+			resultado.EstatusUUID = EstatusPreviamenteCancelado
+		case r.conAceptacion(ahora):
+			r.estatus = CancelacionEnProceso
+			resultado.EstatusUUID = EstatusSolicitudRecibida
+			resultado.EstatusCancelacion = r.estatus
+		default:
+			r.estatus = CanceladoSinAceptacion
+			resultado.EstatusUUID = EstatusSolicitudRecibida
+			resultado.EstatusCancelacion = r.estatus
+		}
+		acuse.Folios = append(acuse.Folios, resultado)
+	}
+	acuse.XML = escribirAcuse(acuse)
+	return acuse, nil
+}
+
+// ResponderCancelacion Simula la respuesta del receptor a una solicitud de cancelación En proceso: si la acepta el comprobante queda Cancelado con aceptación y si no, Solicitud rechazada y vigente.
+func (s *Simulador) ResponderCancelacion(uuid string, aceptar bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.timbrados[strings.ToUpper(uuid)]
+	if !ok || r.estatus != CancelacionEnProceso {
+		return nuevoError(CodigoComprobanteNoEncontrado)
+	}
+	if aceptar {
+		r.estatus = CanceladoConAceptacion
+	} else {
+		r.estatus = SolicitudRechazada
+	}
+	return nil
+}
+
+// nuevoUUID Devuelve un UUID versión 4 en mayúsculas, como los que asignan los PAC.
+func nuevoUUID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// insertarTimbre Agrega el timbre al final del nodo Complemento del documento, o crea el nodo antes de la Addenda o del cierre del comprobante, conservando el resto del documento byte por byte. El nodo Complemento se escribe con el prefijo que usa el nodo raíz.
+func insertarTimbre(cfdi []byte, t xmlstructures.CFDITimbre) ([]byte, error) {
+	timbre, err := xml.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	d := xml.NewDecoder(bytes.NewReader(cfdi))
+	prefijo := ""
+	profundidad := 0
+	inicioComplemento := int64(-1)
+	for {
+		antes := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return nil, fmt.Errorf("el documento no tiene nodo raíz")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			profundidad++
+			switch {
+			case profundidad == 1:
+				prefijo = v.Name.Space
+			case profundidad == 2 && v.Name.Local == "Complemento":
+				inicioComplemento = antes
+			case profundidad == 2 && v.Name.Local == "Addenda":
+				return insertar(cfdi, antes, antes, nodoComplemento(prefijo, timbre)), nil
+			}
+		case xml.EndElement:
+			profundidad--
+			switch {
+			case profundidad == 1 && v.Name.Local == "Complemento":
+				if d.InputOffset() == antes {
+					// <cfdi:Complemento/>: se reemplaza el nodo vacío completo.
+					return insertar(cfdi, inicioComplemento, antes, nodoComplemento(prefijo, timbre)), nil
+				}
+				return insertar(cfdi, antes, antes, timbre), nil
+			case profundidad == 0:
+				return insertar(cfdi, antes, antes, nodoComplemento(prefijo, timbre)), nil
+			}
+		}
+	}
+}
+
+// nodoComplemento Devuelve el nodo Complemento con el prefijo dado que contiene al timbre.
+func nodoComplemento(prefijo string, timbre []byte) []byte {
+	nombre := "Complemento"
+	if prefijo != "" {
+		nombre = prefijo + ":" + nombre
+	}
+	return []byte("<" + nombre + ">" + string(timbre) + "</" + nombre + ">")
+}
+
+// insertar Devuelve una copia de datos con datos[inicio:fin] reemplazado por nuevo.
+func insertar(datos []byte, inicio, fin int64, nuevo []byte) []byte {
+	resultado := make([]byte, 0, len(datos)+len(nuevo))
+	resultado = append(resultado, datos[:inicio]...)
+	resultado = append(resultado, nuevo...)
+	return append(resultado, datos[fin:]...)
+}
+
+// ServeHTTP Sirve el simulador con el protocolo de ClienteREST, p.ej. con httptest.NewServer para probar un cliente completo.
+func (s *Simulador) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		responderJSON(w, http.StatusUnauthorized, mensajeError{Codigo: CodigoAutenticacion, Mensaje: MensajesPAC[CodigoAutenticacion]})
+		return
+	}
+	var (
+		respuesta interface{}
+		err       error
+	)
+	switch r.URL.Path {
+	case rutaTimbrar:
+		var solicitud mensajeTimbrado
+		if err = json.NewDecoder(r.Body).Decode(&solicitud); err == nil {
+			var timbrado *Respuesta
+			if timbrado, err = s.Stamp(r.Context(), solicitud.XML); err == nil {
+				respuesta = mensajeTimbrado{XML: timbrado.XML}
+			}
+		}
+	case rutaEstado:
+		var consulta Consulta
+		if err = json.NewDecoder(r.Body).Decode(&consulta); err == nil {
+			respuesta, err = s.Status(r.Context(), consulta)
+		}
+	case rutaCancelar:
+		var solicitud SolicitudCancelacion
+		if err = json.NewDecoder(r.Body).Decode(&solicitud); err == nil {
+			respuesta, err = s.Cancel(r.Context(), solicitud)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		var e *ErrorPAC
+		if !errors.As(err, &e) {
+			e = &ErrorPAC{Codigo: CodigoXMLMalFormado, Mensaje: err.Error()}
+		}
+		responderJSON(w, http.StatusBadRequest, mensajeError{Codigo: e.Codigo, Mensaje: e.Mensaje})
+		return
+	}
+	responderJSON(w, http.StatusOK, respuesta)
+}
+
+// responderJSON Escribe v en JSON con el código de estado dado.
+func responderJSON(w http.ResponseWriter, estado int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(v)
+}
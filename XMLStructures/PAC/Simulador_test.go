@@ -0,0 +1,327 @@
+package pac
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	xmlstructures ".."
+)
+
+// ahoraPrueba Reloj de los simuladores de prueba.
+var ahoraPrueba = time.Date(2026, 10, 17, 12, 1, 0, 0, zonaSAT)
+
+// csdPrueba Carga el CSD de prueba de ../testdata/csd (AAA010101AAA, vigente desde 2026-10-17T03:52:20Z).
+func csdPrueba(t *testing.T) *xmlstructures.CSD {
+	t.Helper()
+	csd, err := xmlstructures.CargarCSD(filepath.Join("..", "testdata", "csd", "csd.cer"), filepath.Join("..", "testdata", "csd", "des3.key"), []byte("12345678a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csd
+}
+
+// certificadoPrueba Devuelve un CSD con la llave de base y un certificado autofirmado nuevo, vigente de desde a hasta, con el número de serie 30001000000300023709. Si fiel es true el certificado tiene los usos de una e.firma.
+func certificadoPrueba(t *testing.T, base *xmlstructures.CSD, desde, hasta time.Time, fiel bool) *xmlstructures.CSD {
+	t.Helper()
+	plantilla := &x509.Certificate{
+		SerialNumber: new(big.Int).SetBytes([]byte("30001000000300023709")),
+		Subject: pkix.Name{CommonName: "EMPRESA PRUEBA", ExtraNames: []pkix.AttributeTypeAndValue{
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 45}, Value: "AAA010101AAA / PERJ800101AB1"},
+		}},
+		NotBefore: desde,
+		NotAfter:  hasta,
+		KeyUsage:  x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	if fiel {
+		plantilla.KeyUsage |= x509.KeyUsageDataEncipherment | x509.KeyUsageKeyAgreement
+		plantilla.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection, x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, plantilla, &base.Llave.PublicKey, base.Llave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificado, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCertificado, err := xmlstructures.NumeroCertificado(certificado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &xmlstructures.CSD{Certificado: certificado, Llave: base.Llave, NoCertificado: noCertificado}
+}
+
+// comprobantePrueba Devuelve un comprobante de ingreso de AAA010101AAA expedido en la Ciudad de México en fecha, con un concepto de valor total, sin sellar.
+func comprobantePrueba(t *testing.T, fecha time.Time, total string) xmlstructures.Comprobante {
+	t.Helper()
+	importe, err := xmlstructures.ParseDecimal(total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := xmlstructures.Comprobante{
+		Version:           xmlstructures.VersionCFDI,
+		Serie:             "A",
+		Folio:             "1",
+		Fecha:             fecha.In(zonaSAT).Format("2006-01-02T15:04:05"),
+		FormaPago:         "01",
+		Moneda:            "MXN",
+		TipoDeComprobante: "I",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   "01000",
+		Emisor:            xmlstructures.CFDIEmisor{RFC: "AAA010101AAA", Nombre: "EMPRESA PRUEBA", RegimenFiscal: "601"},
+		Receptor:          xmlstructures.CFDIReceptor{RFC: "XAXX010101000", Nombre: "PUBLICO EN GENERAL", UsoCFDI: "G03"},
+	}
+	c.Conceptos.Conceptos = []xmlstructures.CFDIConcepto{{
+		ClaveProdServ: "01010101",
+		Cantidad:      xmlstructures.NewDecimal(1, 0),
+		ClaveUnidad:   "H87",
+		Descripcion:   "Producto",
+		ValorUnitario: importe,
+	}}
+	if err := c.CalcularTotales(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// sellado Sella c con csd y devuelve el XML.
+func sellado(t *testing.T, csd *xmlstructures.CSD, c xmlstructures.Comprobante) []byte {
+	t.Helper()
+	if err := csd.Sellar(&c); err != nil {
+		t.Fatal(err)
+	}
+	cfdi, err := xmlstructures.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfdi
+}
+
+// simuladorPrueba Devuelve un Simulador con el CSD de prueba cuyo reloj es *reloj.
+func simuladorPrueba(t *testing.T, reloj *time.Time) *Simulador {
+	s := NuevoSimulador(csdPrueba(t))
+	s.Ahora = func() time.Time { return *reloj }
+	return s
+}
+
+// codigoPAC Devuelve el código del *ErrorPAC en err, o vacío si err no lo es.
+func codigoPAC(err error) string {
+	var e *ErrorPAC
+	if errors.As(err, &e) {
+		return e.Codigo
+	}
+	return ""
+}
+
+func TestSimuladorStamp(t *testing.T) {
+	reloj := ahoraPrueba
+	s := simuladorPrueba(t, &reloj)
+	csd := csdPrueba(t)
+	r, err := s.Stamp(context.Background(), sellado(t, csd, comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), "100.00")))
+	if err != nil {
+		t.Fatalf("Stamp: %v", err)
+	}
+	var timbrado xmlstructures.Comprobante
+	if err := xmlstructures.Unmarshal(r.XML, &timbrado); err != nil {
+		t.Fatal(err)
+	}
+	var almacen xmlstructures.AlmacenCertificados
+	if err := almacen.Agregar(csd.Certificado); err != nil {
+		t.Fatal(err)
+	}
+	reporte, err := almacen.VerificarTimbre(timbrado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reporte.Valido() || timbrado.Timbre().UUID != r.Timbre.UUID || r.Timbre.FechaTimbrado != "2026-10-17T12:01:00" {
+		t.Errorf("timbre %+v inválido: %v", r.Timbre, reporte.Problemas())
+	}
+}
+
+func TestSimuladorStampErrores(t *testing.T) {
+	csd := csdPrueba(t)
+	vigente := certificadoPrueba(t, csd, time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC), false)
+	caducado := certificadoPrueba(t, csd, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), ahoraPrueba.Add(-time.Hour), false)
+	fiel := certificadoPrueba(t, csd, time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC), true)
+	haceUnaHora := ahoraPrueba.Add(-time.Hour)
+
+	alterado := comprobantePrueba(t, haceUnaHora, "100.00")
+	if err := csd.Sellar(&alterado); err != nil {
+		t.Fatal(err)
+	}
+	alterado.Folio = "2"
+	cfdiAlterado, err := xmlstructures.Marshal(alterado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otroEmisor := comprobantePrueba(t, haceUnaHora, "100.00")
+	otroEmisor.Emisor.RFC = "EKU9003173C9"
+
+	casos := []struct {
+		nombre string
+		codigo string
+		cfdi   func(s *Simulador) []byte
+	}{
+		{"XML mal formado", CodigoXMLMalFormado, func(*Simulador) []byte { return []byte("<cfdi:Comprobante") }},
+		{"sello alterado", CodigoSelloInvalido, func(*Simulador) []byte { return cfdiAlterado }},
+		{"emisor distinto del certificado", CodigoSelloNoCorresponde, func(*Simulador) []byte { return sellado(t, csd, otroEmisor) }},
+		{"certificado caducado", CodigoCertificadoRevocado, func(*Simulador) []byte {
+			return sellado(t, caducado, comprobantePrueba(t, ahoraPrueba.Add(-2*time.Hour), "100.00"))
+		}},
+		{"fecha antes de la vigencia", CodigoFechaFueraDeVigencia, func(*Simulador) []byte {
+			return sellado(t, csd, comprobantePrueba(t, time.Date(2026, 10, 16, 20, 0, 0, 0, zonaSAT), "100.00"))
+		}},
+		{"e.firma", CodigoNoEsCSD, func(*Simulador) []byte { return sellado(t, fiel, comprobantePrueba(t, haceUnaHora, "100.00")) }},
+		{"timbrado dos veces", CodigoTimbrePrevio, func(s *Simulador) []byte {
+			cfdi := sellado(t, csd, comprobantePrueba(t, haceUnaHora, "100.00"))
+			if _, err := s.Stamp(context.Background(), cfdi); err != nil {
+				t.Fatal(err)
+			}
+			return cfdi
+		}},
+		{"con timbre previo", CodigoTimbrePrevio, func(s *Simulador) []byte {
+			r, err := s.Stamp(context.Background(), sellado(t, csd, comprobantePrueba(t, haceUnaHora, "100.00")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return r.XML
+		}},
+		{"fecha de hace más de 72 horas", CodigoFechaFueraDeRango, func(*Simulador) []byte {
+			return sellado(t, vigente, comprobantePrueba(t, ahoraPrueba.Add(-73*time.Hour), "100.00"))
+		}},
+		{"fecha futura", CodigoFechaFueraDeRango, func(*Simulador) []byte {
+			return sellado(t, vigente, comprobantePrueba(t, ahoraPrueba.Add(10*time.Minute), "100.00"))
+		}},
+		{"fecha anterior a 2012", CodigoFechaAnterior2012, func(*Simulador) []byte {
+			return sellado(t, vigente, comprobantePrueba(t, time.Date(2011, 6, 1, 12, 0, 0, 0, zonaSAT), "100.00"))
+		}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			reloj := ahoraPrueba
+			s := simuladorPrueba(t, &reloj)
+			_, err := s.Stamp(context.Background(), caso.cfdi(s))
+			if codigoPAC(err) != caso.codigo {
+				t.Errorf("se esperaba el código %s, se obtuvo %v", caso.codigo, err)
+			}
+		})
+	}
+}
+
+func TestSimuladorForzarError(t *testing.T) {
+	reloj := ahoraPrueba
+	s := simuladorPrueba(t, &reloj)
+	cfdi := sellado(t, csdPrueba(t), comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), "100.00"))
+	s.ForzarError(CodigoRFCNoInscrito)
+	s.ForzarError(CodigoAutenticacion)
+	for _, codigo := range []string{CodigoRFCNoInscrito, CodigoAutenticacion, ""} {
+		_, err := s.Stamp(context.Background(), cfdi)
+		if codigoPAC(err) != codigo || codigo == "" && err != nil {
+			t.Errorf("se esperaba el código %q, se obtuvo %v", codigo, err)
+		}
+	}
+}
+
+func TestSimuladorStatusCancel(t *testing.T) {
+	reloj := ahoraPrueba
+	s := simuladorPrueba(t, &reloj)
+	csd := csdPrueba(t)
+	ctx := context.Background()
+	menor, err := s.Stamp(ctx, sellado(t, csd, comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), "100.00")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mayor, err := s.Stamp(ctx, sellado(t, csd, comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), "5000.00")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	consulta := Consulta{UUID: menor.Timbre.UUID, RFCEmisor: "AAA010101AAA", RFCReceptor: "XAXX010101000", Total: "100.00"}
+	if e, err := s.Status(ctx, consulta); err != nil || e.Estado != EstadoVigente || e.EsCancelable != CancelableSinAceptacion {
+		t.Errorf("Status de un comprobante vigente: %+v, %v", e, err)
+	}
+	otra := consulta
+	otra.Total = "100.01"
+	if e, err := s.Status(ctx, otra); err != nil || e.Estado != EstadoNoEncontrado {
+		t.Errorf("Status con otro total: %+v, %v", e, err)
+	}
+
+	// Pasadas 24 horas, el comprobante de más de 1000 pesos requiere aceptación.
+	reloj = ahoraPrueba.Add(25 * time.Hour)
+	acuse, err := s.Cancel(ctx, SolicitudCancelacion{RFCEmisor: "AAA010101AAA", Folios: []Folio{
+		{UUID: menor.Timbre.UUID, Motivo: MotivoErroresSinRelacion},
+		{UUID: mayor.Timbre.UUID, Motivo: MotivoErroresSinRelacion},
+		{UUID: "00000000-0000-4000-8000-000000000000", Motivo: MotivoErroresSinRelacion},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperados := []FolioCancelado{
+		{UUID: menor.Timbre.UUID, EstatusUUID: EstatusSolicitudRecibida, EstatusCancelacion: CanceladoSinAceptacion},
+		{UUID: mayor.Timbre.UUID, EstatusUUID: EstatusSolicitudRecibida, EstatusCancelacion: CancelacionEnProceso},
+		{UUID: "00000000-0000-4000-8000-000000000000", EstatusUUID: EstatusNoExiste},
+	}
+	for i, f := range acuse.Folios {
+		if i >= len(esperados) || f != esperados[i] {
+			t.Errorf("folio %d del acuse: %+v", i, f)
+		}
+	}
+	if e, _ := s.Status(ctx, consulta); e.Estado != EstadoCancelado {
+		t.Errorf("Status después de cancelar: %+v", e)
+	}
+
+	acuse, err = s.Cancel(ctx, SolicitudCancelacion{RFCEmisor: "EKU9003173C9", Folios: []Folio{{UUID: mayor.Timbre.UUID, Motivo: MotivoErroresSinRelacion}}})
+	if err != nil || acuse.Folios[0].EstatusUUID != EstatusNoCorrespondeEmisor {
+		t.Errorf("cancelación de otro emisor: %+v, %v", acuse, err)
+	}
+	if err := s.ResponderCancelacion(mayor.Timbre.UUID, true); err != nil {
+		t.Fatal(err)
+	}
+	acuse, err = s.Cancel(ctx, SolicitudCancelacion{RFCEmisor: "AAA010101AAA", Folios: []Folio{{UUID: mayor.Timbre.UUID, Motivo: MotivoErroresSinRelacion}}})
+	if err != nil || acuse.Folios[0].EstatusUUID != EstatusPreviamenteCancelado {
+		t.Errorf("cancelación de un folio cancelado: %+v, %v", acuse, err)
+	}
+	if _, err := s.Cancel(ctx, SolicitudCancelacion{RFCEmisor: "AAA010101AAA", Folios: []Folio{{UUID: menor.Timbre.UUID, Motivo: MotivoErroresConRelacion}}}); codigoPAC(err) != CodigoXMLMalFormado {
+		t.Errorf("motivo 01 sin folio de sustitución: %v", err)
+	}
+}
+
+func TestClienteRESTSimulador(t *testing.T) {
+	reloj := ahoraPrueba
+	s := simuladorPrueba(t, &reloj)
+	s.Token = "secreto"
+	servidor := httptest.NewServer(s)
+	defer servidor.Close()
+	ctx := context.Background()
+	cliente := &ClienteREST{URL: servidor.URL, Token: "secreto", HTTP: servidor.Client()}
+
+	r, err := cliente.Stamp(ctx, sellado(t, csdPrueba(t), comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), "100.00")))
+	if err != nil {
+		t.Fatalf("Stamp: %v", err)
+	}
+	e, err := cliente.Status(ctx, Consulta{UUID: r.Timbre.UUID, RFCEmisor: "AAA010101AAA", RFCReceptor: "XAXX010101000", Total: "100.00"})
+	if err != nil || e.Estado != EstadoVigente {
+		t.Errorf("Status: %+v, %v", e, err)
+	}
+	acuse, err := cliente.Cancel(ctx, SolicitudCancelacion{RFCEmisor: "AAA010101AAA", Folios: []Folio{{UUID: r.Timbre.UUID, Motivo: MotivoSinOperacion}}})
+	if err != nil || len(acuse.Folios) != 1 || acuse.Folios[0].EstatusCancelacion != CanceladoSinAceptacion || len(acuse.XML) == 0 {
+		t.Errorf("Cancel: %+v, %v", acuse, err)
+	}
+
+	s.ForzarError(CodigoFechaFueraDeVigencia)
+	if _, err := cliente.Stamp(ctx, []byte("<x/>")); codigoPAC(err) != CodigoFechaFueraDeVigencia {
+		t.Errorf("error forzado por HTTP: %v", err)
+	}
+	sinToken := &ClienteREST{URL: servidor.URL, HTTP: servidor.Client()}
+	if _, err := sinToken.Status(ctx, Consulta{}); codigoPAC(err) != CodigoAutenticacion {
+		t.Errorf("sin token: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	xmlstructures ".."
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Sustitución de comprobantes
+*
+*
+****************************************************************************************************************************************/
+
+// ErrSustitutoSinRelacion Indica que el sustituto no relaciona al original con xmlstructures.TipoRelacionSustitucion.
+var ErrSustitutoSinRelacion = errors.New("pac: el sustituto no relaciona al comprobante original con el tipo de relación 04")
+
+// ErrCancelacionEnProceso Indica que Sustituir timbró el sustituto y el SAT recibió la cancelación del original, pero ésta requiere la aceptación del receptor y quedó En proceso.
+var ErrCancelacionEnProceso = errors.New("pac: la cancelación del comprobante original quedó en proceso en espera de la aceptación del receptor")
+
+// Etapas de una sustitución, para ErrorSustitucion.
+const (
+	EtapaTimbrado    = "timbrado del sustituto"
+	EtapaCancelacion = "cancelación del original"
+)
+
+// ErrorSustitucion Error en una de las etapas de Sustituir. Si falló la cancelación del original, el sustituto ya timbrado se cancela; Reversion es el error de esa cancelación y, si no es nil, el sustituto UUIDSustituto sigue vigente y debe cancelarse a mano.
+type ErrorSustitucion struct {
+	Etapa         string // EtapaTimbrado o EtapaCancelacion.
+	UUIDSustituto string // UUID del sustituto si llegó a timbrarse.
+	Err           error  // Error original.
+	Reversion     error  // Error al cancelar el sustituto; nil si no hizo falta o se canceló.
+}
+
+func (e *ErrorSustitucion) Error() string {
+	mensaje := fmt.Sprintf("pac: sustitución: %s: %v", e.Etapa, e.Err)
+	if e.Reversion != nil {
+		mensaje += fmt.Sprintf("; el sustituto %s no pudo cancelarse: %v", e.UUIDSustituto, e.Reversion)
+	}
+	return mensaje
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorSustitucion) Unwrap() error { return e.Err }
+
+// Sustituir Expide el comprobante sustituto y cancela el original con el motivo 01 indicando el UUID del sustituto. El sustituto suele obtenerse con original.Sustitucion() y debe relacionar al original con el tipo de relación 04; si no tiene Fecha se le asigna la del reloj del cancelador. Se sella con el CSD y se timbra con el PAC.
+//
+// Si el timbrado falla, nada cambia. Si la cancelación del original falla o el SAT no la recibe, el sustituto se cancela con el motivo 03, ya que la operación no se llevó a cabo, y se devuelve un *ErrorSustitucion. Si el SAT recibe la cancelación, sustituto se reemplaza por el comprobante timbrado.
+//
+// Cuando el original requiere la aceptación del receptor, el acuse trae EstatusUUID 201 con EstatusCancelacion En proceso (o sin él, como en CancelarComprobante): el sustituto ya está timbrado y vigente, pero el original también lo sigue estando. En ese caso se devuelven la respuesta y el acuse junto con ErrCancelacionEnProceso; el seguimiento se hace con ActualizarComprobante sobre el original y, si el receptor rechaza la cancelación, el sustituto debe cancelarse con el motivo 03.
+func (c *Cancelador) Sustituir(ctx context.Context, original xmlstructures.Comprobante, sustituto *xmlstructures.Comprobante) (*Respuesta, *AcuseCancelacion, error) {
+	t := original.Timbre()
+	if t == nil {
+		return nil, nil, fmt.Errorf("pac: %w", xmlstructures.ErrSinTimbre)
+	}
+	if !sustituto.Relaciona(xmlstructures.TipoRelacionSustitucion, t.UUID) {
+		return nil, nil, ErrSustitutoSinRelacion
+	}
+
+	nuevo := *sustituto
+	if nuevo.Fecha == "" {
+		nuevo.Fecha = c.ahora().Format("2006-01-02T15:04:05")
+	}
+	if err := c.CSD.Sellar(&nuevo); err != nil {
+		return nil, nil, &ErrorSustitucion{Etapa: EtapaTimbrado, Err: err}
+	}
+	respuesta, err := Timbrar(ctx, c.PAC, &nuevo)
+	if err != nil {
+		return nil, nil, &ErrorSustitucion{Etapa: EtapaTimbrado, Err: err}
+	}
+
+	folio := Folio{UUID: t.UUID, Motivo: MotivoErroresConRelacion, FolioSustitucion: respuesta.Timbre.UUID}
+	acuse, resultado, err := c.cancelarFolio(ctx, original.Emisor.RFC, folio)
+	if err == nil && resultado.EstatusUUID != EstatusSolicitudRecibida {
+		err = &ErrorPAC{Codigo: resultado.EstatusUUID, Mensaje: MensajesCancelacion[resultado.EstatusUUID]}
+	}
+	if err != nil {
+		e := &ErrorSustitucion{Etapa: EtapaCancelacion, UUIDSustituto: respuesta.Timbre.UUID, Err: err}
+		reversion := Folio{UUID: respuesta.Timbre.UUID, Motivo: MotivoSinOperacion}
+		_, resultado, e.Reversion = c.cancelarFolio(ctx, nuevo.Emisor.RFC, reversion)
+		if e.Reversion == nil && resultado.EstatusUUID != EstatusSolicitudRecibida {
+			e.Reversion = &ErrorPAC{Codigo: resultado.EstatusUUID, Mensaje: MensajesCancelacion[resultado.EstatusUUID]}
+		}
+		return nil, nil, e
+	}
+	*sustituto = nuevo
+	if !cancelado(resultado.EstatusCancelacion) {
+		return respuesta, acuse, ErrCancelacionEnProceso
+	}
+	return respuesta, acuse, nil
+}
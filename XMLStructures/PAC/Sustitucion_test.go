@@ -0,0 +1,139 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	xmlstructures ".."
+)
+
+// sustitucionPrueba Timbra con el simulador un comprobante de total expedido una hora antes de ahoraPrueba y devuelve el cancelador, el original timbrado y el borrador de su sustituto. El reloj del simulador y del cancelador es *reloj.
+func sustitucionPrueba(t *testing.T, reloj *time.Time, total string) (*Simulador, *Cancelador, xmlstructures.Comprobante, xmlstructures.Comprobante) {
+	t.Helper()
+	s := simuladorPrueba(t, reloj)
+	c := &Cancelador{PAC: s, CSD: csdPrueba(t), Ahora: func() time.Time { return *reloj }}
+	original := comprobantePrueba(t, ahoraPrueba.Add(-time.Hour), total)
+	if err := c.CSD.Sellar(&original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Timbrar(context.Background(), s, &original); err != nil {
+		t.Fatal(err)
+	}
+	sustituto, err := original.Sustitucion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c, original, sustituto
+}
+
+// estadoSimulador Devuelve el Estado con que el simulador responde por el comprobante uuid de AAA010101AAA.
+func estadoSimulador(t *testing.T, s *Simulador, uuid string, total xmlstructures.Decimal) string {
+	t.Helper()
+	e, err := s.Status(context.Background(), Consulta{UUID: uuid, RFCEmisor: "AAA010101AAA", RFCReceptor: "XAXX010101000", Total: total.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.Estado
+}
+
+func TestSustituir(t *testing.T) {
+	reloj := ahoraPrueba
+	s, c, original, sustituto := sustitucionPrueba(t, &reloj, "100.00")
+	respuesta, acuse, err := c.Sustituir(context.Background(), original, &sustituto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sustituto.Timbre() == nil || sustituto.Timbre().UUID != respuesta.Timbre.UUID {
+		t.Errorf("el sustituto no se reemplazó por el comprobante timbrado")
+	}
+	esperado := FolioCancelado{UUID: original.Timbre().UUID, EstatusUUID: EstatusSolicitudRecibida, EstatusCancelacion: CanceladoSinAceptacion}
+	if len(acuse.Folios) != 1 || acuse.Folios[0] != esperado {
+		t.Errorf("acuse %+v", acuse.Folios)
+	}
+	if e := estadoSimulador(t, s, original.Timbre().UUID, original.Total); e != EstadoCancelado {
+		t.Errorf("estado del original: %s", e)
+	}
+}
+
+func TestSustituirEnProceso(t *testing.T) {
+	reloj := ahoraPrueba
+	s, c, original, sustituto := sustitucionPrueba(t, &reloj, "5000.00")
+	// Pasadas 24 horas, el original de más de 1000 pesos requiere la aceptación del receptor.
+	reloj = ahoraPrueba.Add(25 * time.Hour)
+	respuesta, acuse, err := c.Sustituir(context.Background(), original, &sustituto)
+	if err != ErrCancelacionEnProceso {
+		t.Fatalf("se esperaba ErrCancelacionEnProceso, se obtuvo %v", err)
+	}
+	if respuesta == nil || acuse == nil || sustituto.Timbre() == nil {
+		t.Fatalf("la sustitución en proceso debe devolver el sustituto timbrado y el acuse: %+v %+v", respuesta, acuse)
+	}
+	if acuse.Folios[0].EstatusCancelacion != CancelacionEnProceso {
+		t.Errorf("acuse %+v", acuse.Folios)
+	}
+	if e := estadoSimulador(t, s, original.Timbre().UUID, original.Total); e != EstadoVigente {
+		t.Errorf("estado del original: %s", e)
+	}
+	if e := estadoSimulador(t, s, respuesta.Timbre.UUID, sustituto.Total); e != EstadoVigente {
+		t.Errorf("estado del sustituto: %s", e)
+	}
+}
+
+func TestSustituirErrorTimbrado(t *testing.T) {
+	reloj := ahoraPrueba
+	s, c, original, sustituto := sustitucionPrueba(t, &reloj, "100.00")
+	s.ForzarError(CodigoRFCNoInscrito)
+	_, _, err := c.Sustituir(context.Background(), original, &sustituto)
+	var e *ErrorSustitucion
+	if !errors.As(err, &e) || e.Etapa != EtapaTimbrado || codigoPAC(err) != CodigoRFCNoInscrito || e.UUIDSustituto != "" {
+		t.Fatalf("se esperaba un error de timbrado, se obtuvo %v", err)
+	}
+	if sustituto.Fecha != "" || sustituto.Sello != "" {
+		t.Errorf("el borrador se modificó: %s %s", sustituto.Fecha, sustituto.Sello)
+	}
+	if e := estadoSimulador(t, s, original.Timbre().UUID, original.Total); e != EstadoVigente {
+		t.Errorf("estado del original: %s", e)
+	}
+}
+
+func TestSustituirReversion(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		forzados  []string // Errores de las cancelaciones del original y del sustituto.
+		reversion string   // Código del error al cancelar el sustituto; vacío si se canceló.
+		estado    string   // Estado final del sustituto.
+	}{
+		{"sustituto cancelado", []string{CodigoAutenticacion}, "", EstadoCancelado},
+		{"sustituto vigente", []string{CodigoAutenticacion, CodigoXMLMalFormado}, CodigoXMLMalFormado, EstadoVigente},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			reloj := ahoraPrueba
+			s, c, original, sustituto := sustitucionPrueba(t, &reloj, "100.00")
+			for _, codigo := range caso.forzados {
+				s.ForzarErrorCancelacion(codigo)
+			}
+			respuesta, acuse, err := c.Sustituir(context.Background(), original, &sustituto)
+			var e *ErrorSustitucion
+			if !errors.As(err, &e) || e.Etapa != EtapaCancelacion || codigoPAC(e.Err) != CodigoAutenticacion {
+				t.Fatalf("se esperaba un error de cancelación, se obtuvo %v", err)
+			}
+			if respuesta != nil || acuse != nil || sustituto.Timbre() != nil {
+				t.Errorf("una sustitución fallida no debe devolver el sustituto")
+			}
+			if codigoPAC(e.Reversion) != caso.reversion || caso.reversion == "" && e.Reversion != nil {
+				t.Errorf("Reversion: se esperaba %q, se obtuvo %v", caso.reversion, e.Reversion)
+			}
+			if e.UUIDSustituto == "" {
+				t.Fatal("el error no indica el UUID del sustituto")
+			}
+			if estado := estadoSimulador(t, s, e.UUIDSustituto, original.Total); estado != caso.estado {
+				t.Errorf("estado del sustituto: %s", estado)
+			}
+			if estado := estadoSimulador(t, s, original.Timbre().UUID, original.Total); estado != EstadoVigente {
+				t.Errorf("estado del original: %s", estado)
+			}
+		})
+	}
+}
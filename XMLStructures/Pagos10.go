@@ -0,0 +1,237 @@
+package xmlstructures
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Complemento para recepción de pagos 1.0
+*
+*
+****************************************************************************************************************************************/
+
+// Espacio de nombres del complemento para recepción de pagos y datos fijos del comprobante de tipo P que lo contiene, según la guía de llenado del SAT.
+const (
+	NamespacePagos    = "http://www.sat.gob.mx/Pagos"                                // Espacio de nombres del prefijo pago10.
+	EsquemaPagos      = "http://www.sat.gob.mx/sitio_internet/cfd/Pagos/Pagos10.xsd" // Ubicación del esquema del complemento para recepción de pagos 1.0.
+	VersionPagos      = "1.0"                                                        // Versión del complemento.
+	MonedaPago        = "XXX"                                                        // Moneda del comprobante de tipo P: los montos se expresan en el complemento.
+	UsoCFDIPago       = "P01"                                                        // Por definir.
+	ClaveProdServPago = "84111506"                                                   // Servicios de facturación.
+	ClaveUnidadPago   = "ACT"                                                        // Actividad.
+	DescripcionPago   = "Pago"                                                       // Descripción del único concepto.
+)
+
+// Errores devueltos al preparar un comprobante de recepción de pagos.
+var (
+	ErrNoEsPPD         = errors.New("el comprobante no es de ingreso con método de pago PPD")
+	ErrPagoExcedeSaldo = errors.New("el importe pagado debe ser mayor que cero y a lo más el saldo anterior")
+)
+
+// Pagos Complemento pago10:Pagos para registrar la información sobre la recepción de pagos. Va en cfdi:Complemento de un comprobante de tipo P.
+type Pagos struct {
+	XMLName xml.Name `xml:"pago10:Pagos"`
+	Version string   `xml:"Version,attr"` // Atributo requerido que indica la versión del complemento para recepción de pagos, con valor prefijado a 1.0. Req.
+	Pago    []Pago   `xml:"pago10:Pago"`  // Elemento requerido para incorporar la información de la recepción de pagos. Puede repetirse, uno por cada pago recibido.
+}
+
+// Pago Nodo requerido para incorporar la información de un pago recibido.
+type Pago struct {
+	XMLName          xml.Name           `xml:"pago10:Pago"`
+	FechaPago        string             `xml:"FechaPago,attr"`                 // Atributo requerido para expresar la fecha y hora en la que el beneficiario recibe el pago. Se expresa en la forma aaaa-mm-ddThh:mm:ss. Req.
+	FormaDePagoP     string             `xml:"FormaDePagoP,attr"`              // Atributo requerido para expresar la clave de la forma en que se realiza el pago. No puede ser 99 (Por definir). catCFDI:c_FormaPago Req.
+	MonedaP          string             `xml:"MonedaP,attr"`                   // Atributo requerido para identificar la clave de la moneda utilizada para realizar el pago. No puede ser XXX. catCFDI:c_Moneda Req.
+	TipoCambioP      *Decimal           `xml:"TipoCambioP,attr,omitempty"`     // Atributo condicional para expresar el tipo de cambio de la moneda a la fecha en que se realizó el pago. Es requerido cuando MonedaP es distinta de MXN. Opc.
+	Monto            Decimal            `xml:"Monto,attr"`                     // Atributo requerido para expresar el importe del pago, con los decimales de MonedaP. Debe ser mayor que cero. Req.
+	NumOperacion     string             `xml:"NumOperacion,attr,omitempty"`    // Atributo condicional para expresar el número de cheque, número de autorización, número de referencia, clave de rastreo en caso de ser SPEI, línea de captura o algún número de referencia análogo que identifique la operación. Opc.
+	RfcEmisorCtaOrd  string             `xml:"RfcEmisorCtaOrd,attr,omitempty"` // Atributo condicional para expresar la clave RFC de la entidad emisora de la cuenta origen. XEXX010101000 si es un banco extranjero. Opc.
+	NomBancoOrdExt   string             `xml:"NomBancoOrdExt,attr,omitempty"`  // Atributo condicional para expresar el nombre del banco ordenante, es requerido en caso de ser extranjero. Opc.
+	CtaOrdenante     string             `xml:"CtaOrdenante,attr,omitempty"`    // Atributo condicional para incorporar el número de la cuenta con la que se realizó el pago. Opc.
+	RfcEmisorCtaBen  string             `xml:"RfcEmisorCtaBen,attr,omitempty"` // Atributo condicional para expresar la clave RFC de la entidad operadora de la cuenta destino. Opc.
+	CtaBeneficiario  string             `xml:"CtaBeneficiario,attr,omitempty"` // Atributo condicional para incorporar el número de cuenta en donde se recibió el pago. Opc.
+	TipoCadPago      string             `xml:"TipoCadPago,attr,omitempty"`     // Atributo condicional para identificar la clave del tipo de cadena de pago que genera la entidad receptora del pago, p.ej. 01 para SPEI. catPagos:c_TipoCadenaPago Opc.
+	CertPago         string             `xml:"CertPago,attr,omitempty"`        // Atributo condicional que sirve para incorporar el certificado que ampara al pago, como una cadena de texto en formato base 64. Es requerido si existe TipoCadPago. Opc.
+	CadPago          string             `xml:"CadPago,attr,omitempty"`         // Atributo condicional para expresar la cadena original del comprobante de pago generado por la entidad emisora de la cuenta beneficiaria. Es requerido si existe TipoCadPago. Opc.
+	SelloPago        string             `xml:"SelloPago,attr,omitempty"`       // Atributo condicional para integrar el sello digital que se asocie al pago, como una cadena de texto en formato base 64. Es requerido si existe TipoCadPago. Opc.
+	DoctoRelacionado []DoctoRelacionado `xml:"pago10:DoctoRelacionado,omitempty"`
+	Impuestos        []PagoImpuestos    `xml:"pago10:Impuestos,omitempty"` // Nodo condicional que el SAT no admite en la práctica: su uso está restringido por la guía de llenado.
+}
+
+// DoctoRelacionado Nodo condicional para expresar la lista de documentos relacionados con el pago, uno por cada comprobante de ingreso que se paga total o parcialmente.
+type DoctoRelacionado struct {
+	XMLName          xml.Name `xml:"pago10:DoctoRelacionado"`
+	IdDocumento      string   `xml:"IdDocumento,attr"`                // Atributo requerido para expresar el UUID del comprobante que se paga. Req.
+	Serie            string   `xml:"Serie,attr,omitempty"`            // Atributo opcional para precisar la serie del comprobante que se paga. Opc.
+	Folio            string   `xml:"Folio,attr,omitempty"`            // Atributo opcional para precisar el folio del comprobante que se paga. Opc.
+	MonedaDR         string   `xml:"MonedaDR,attr"`                   // Atributo requerido para identificar la clave de la moneda del documento relacionado. No puede ser XXX. catCFDI:c_Moneda Req.
+	TipoCambioDR     *Decimal `xml:"TipoCambioDR,attr,omitempty"`     // Atributo condicional para expresar el tipo de cambio conforme con la moneda registrada en el documento relacionado: cuántas unidades de MonedaDR equivalen a una de MonedaP. Es requerido cuando MonedaDR es distinta de MonedaP. Opc.
+	MetodoDePagoDR   string   `xml:"MetodoDePagoDR,attr"`             // Atributo requerido para expresar la clave del método de pago que se registró en el documento relacionado. catCFDI:c_MetodoPago Req.
+	NumParcialidad   int      `xml:"NumParcialidad,attr,omitempty"`   // Atributo condicional para expresar el número de parcialidad que corresponde al pago. Es requerido cuando MetodoDePagoDR es PPD. Opc.
+	ImpSaldoAnt      *Decimal `xml:"ImpSaldoAnt,attr,omitempty"`      // Atributo condicional para expresar el monto del saldo insoluto de la parcialidad anterior, en MonedaDR. Es requerido cuando MetodoDePagoDR es PPD. Opc.
+	ImpPagado        *Decimal `xml:"ImpPagado,attr,omitempty"`        // Atributo condicional para expresar el importe pagado para el documento relacionado, en MonedaDR. Es requerido cuando hay más de un documento relacionado o TipoCambioDR. Opc.
+	ImpSaldoInsoluto *Decimal `xml:"ImpSaldoInsoluto,attr,omitempty"` // Atributo condicional para expresar la diferencia entre ImpSaldoAnt e ImpPagado. Es requerido cuando MetodoDePagoDR es PPD. Opc.
+}
+
+// PagoImpuestos Nodo condicional para expresar el resumen de los impuestos aplicables a un pago.
+type PagoImpuestos struct {
+	XMLName                   xml.Name         `xml:"pago10:Impuestos"`
+	TotalImpuestosRetenidos   *Decimal         `xml:"TotalImpuestosRetenidos,attr,omitempty"`   // Atributo condicional para expresar el total de los impuestos retenidos que se desprenden del pago. Opc.
+	TotalImpuestosTrasladados *Decimal         `xml:"TotalImpuestosTrasladados,attr,omitempty"` // Atributo condicional para expresar el total de los impuestos trasladados que se desprenden del pago. Opc.
+	Retenciones               *PagoRetenciones `xml:"pago10:Retenciones,omitempty"`
+	Traslados                 *PagoTraslados   `xml:"pago10:Traslados,omitempty"`
+}
+
+// PagoRetenciones Nodo condicional para capturar los impuestos retenidos aplicables.
+type PagoRetenciones struct {
+	XMLName     xml.Name        `xml:"pago10:Retenciones"`
+	Retenciones []PagoRetencion `xml:"pago10:Retencion"`
+}
+
+// PagoRetencion Nodo requerido para registrar la información detallada de una retención de impuesto específico.
+type PagoRetencion struct {
+	XMLName  xml.Name `xml:"pago10:Retencion"`
+	Impuesto string   `xml:"Impuesto,attr"` // Atributo requerido para señalar la clave del tipo de impuesto retenido. catCFDI:c_Impuesto Req.
+	Importe  Decimal  `xml:"Importe,attr"`  // Atributo requerido para señalar el importe o monto del impuesto retenido. Req.
+}
+
+// PagoTraslados Nodo condicional para capturar los impuestos trasladados aplicables.
+type PagoTraslados struct {
+	XMLName   xml.Name       `xml:"pago10:Traslados"`
+	Traslados []PagoTraslado `xml:"pago10:Traslado"`
+}
+
+// PagoTraslado Nodo requerido para la información detallada de un traslado de impuesto específico.
+type PagoTraslado struct {
+	XMLName    xml.Name `xml:"pago10:Traslado"`
+	Impuesto   string   `xml:"Impuesto,attr"`   // Atributo requerido para señalar la clave del tipo de impuesto trasladado. catCFDI:c_Impuesto Req.
+	TipoFactor string   `xml:"TipoFactor,attr"` // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto. catCFDI:c_TipoFactor Req.
+	TasaOCuota Decimal  `xml:"TasaOCuota,attr"` // Atributo requerido para señalar el valor de la tasa o cuota del impuesto que se traslada. Req.
+	Importe    Decimal  `xml:"Importe,attr"`    // Atributo requerido para señalar el importe del impuesto trasladado. Req.
+}
+
+// EscribirCadena Agrega la sección del complemento a la cadena original, en el orden de Pagos10.xslt del SAT.
+func (p Pagos) EscribirCadena(c *Cadena) {
+	c.Requerido(p.Version)
+	for _, pago := range p.Pago {
+		c.Requerido(pago.FechaPago)
+		c.Requerido(pago.FormaDePagoP)
+		c.Requerido(pago.MonedaP)
+		c.OpcionalDecimal(pago.TipoCambioP)
+		c.RequeridoDecimal(pago.Monto)
+		c.Opcional(pago.NumOperacion)
+		c.Opcional(pago.RfcEmisorCtaOrd)
+		c.Opcional(pago.NomBancoOrdExt)
+		c.Opcional(pago.CtaOrdenante)
+		c.Opcional(pago.RfcEmisorCtaBen)
+		c.Opcional(pago.CtaBeneficiario)
+		c.Opcional(pago.TipoCadPago)
+		c.Opcional(pago.CertPago)
+		c.Opcional(pago.CadPago)
+		c.Opcional(pago.SelloPago)
+		for _, d := range pago.DoctoRelacionado {
+			c.Requerido(d.IdDocumento)
+			c.Opcional(d.Serie)
+			c.Opcional(d.Folio)
+			c.Requerido(d.MonedaDR)
+			c.OpcionalDecimal(d.TipoCambioDR)
+			c.Requerido(d.MetodoDePagoDR)
+			if d.NumParcialidad != 0 {
+				c.Requerido(fmt.Sprint(d.NumParcialidad))
+			}
+			c.OpcionalDecimal(d.ImpSaldoAnt)
+			c.OpcionalDecimal(d.ImpPagado)
+			c.OpcionalDecimal(d.ImpSaldoInsoluto)
+		}
+		for _, impuestos := range pago.Impuestos {
+			c.OpcionalDecimal(impuestos.TotalImpuestosRetenidos)
+			c.OpcionalDecimal(impuestos.TotalImpuestosTrasladados)
+			if impuestos.Retenciones != nil {
+				for _, r := range impuestos.Retenciones.Retenciones {
+					c.Requerido(r.Impuesto)
+					c.RequeridoDecimal(r.Importe)
+				}
+			}
+			if impuestos.Traslados != nil {
+				for _, t := range impuestos.Traslados.Traslados {
+					c.Requerido(t.Impuesto)
+					c.Requerido(t.TipoFactor)
+					c.RequeridoDecimal(t.TasaOCuota)
+					c.RequeridoDecimal(t.Importe)
+				}
+			}
+		}
+	}
+}
+
+// Pagos Devuelve el complemento para recepción de pagos del comprobante, o nil si no lo tiene.
+func (c Comprobante) Pagos() *Pagos {
+	if c.Complemento == nil {
+		return nil
+	}
+	for _, v := range c.Complemento.Complementos {
+		switch p := v.(type) {
+		case *Pagos:
+			return p
+		case Pagos:
+			return &p
+		}
+	}
+	return nil
+}
+
+// ComprobantePago Devuelve el borrador del comprobante de tipo P por los pagos dados. De plantilla se toman Version, Emisor, Receptor y LugarExpedicion; el comprobante lleva SubTotal y Total 0, Moneda MonedaPago, UsoCFDI UsoCFDIPago y un solo concepto ClaveProdServPago con importe 0, sin FormaPago, MetodoPago ni impuestos. Queda sin Fecha ni sello.
+func ComprobantePago(plantilla Comprobante, pagos ...Pago) Comprobante {
+	c := Comprobante{
+		Version:           plantilla.Version,
+		SubTotal:          NewDecimal(0, 0),
+		Moneda:            MonedaPago,
+		Total:             NewDecimal(0, 0),
+		TipoDeComprobante: "P",
+		LugarExpedicion:   plantilla.LugarExpedicion,
+		Emisor:            plantilla.Emisor,
+		Receptor:          plantilla.Receptor,
+	}
+	c.Receptor.UsoCFDI = UsoCFDIPago
+	c.Conceptos.Conceptos = []CFDIConcepto{{
+		ClaveProdServ: ClaveProdServPago,
+		Cantidad:      NewDecimal(1, 0),
+		ClaveUnidad:   ClaveUnidadPago,
+		Descripcion:   DescripcionPago,
+		ValorUnitario: NewDecimal(0, 0),
+		Importe:       NewDecimal(0, 0),
+	}}
+	c.AgregarComplemento(&Pagos{Version: VersionPagos, Pago: pagos})
+	return c
+}
+
+// Parcialidad Devuelve el documento relacionado con que un pago de pagado, en la moneda de c, salda la parcialidad numero del comprobante de ingreso timbrado c con MetodoPago PPD, cuyo saldo antes del pago es saldoAnterior. ImpSaldoInsoluto es la diferencia y los importes se redondean a los decimales de la moneda. Si la moneda del pago es distinta de la de c, se debe asignar TipoCambioDR.
+func (c Comprobante) Parcialidad(numero int, saldoAnterior, pagado Decimal) (DoctoRelacionado, error) {
+	if c.TipoDeComprobante != "I" || c.MetodoPago != "PPD" {
+		return DoctoRelacionado{}, ErrNoEsPPD
+	}
+	t := c.Timbre()
+	if t == nil {
+		return DoctoRelacionado{}, ErrSinTimbre
+	}
+	decimales := DecimalesMoneda(c.Moneda)
+	saldoAnterior, pagado = saldoAnterior.Round(decimales), pagado.Round(decimales)
+	if pagado.Sign() <= 0 || pagado.Cmp(saldoAnterior) > 0 {
+		return DoctoRelacionado{}, &ErrorCalculo{Ruta: "/cfdi:Comprobante/cfdi:Complemento/pago10:Pagos/pago10:Pago/pago10:DoctoRelacionado/@ImpPagado", Err: fmt.Errorf("%w: %s de %s", ErrPagoExcedeSaldo, pagado, saldoAnterior)}
+	}
+	insoluto := saldoAnterior.Sub(pagado)
+	return DoctoRelacionado{
+		IdDocumento:      t.UUID,
+		Serie:            c.Serie,
+		Folio:            c.Folio,
+		MonedaDR:         c.Moneda,
+		MetodoDePagoDR:   c.MetodoPago,
+		NumParcialidad:   numero,
+		ImpSaldoAnt:      &saldoAnterior,
+		ImpPagado:        &pagado,
+		ImpSaldoInsoluto: &insoluto,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package xmlstructures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pagoPrueba Lee testdata/pago.xml, un comprobante de tipo P con un pago en MXN a dos documentos, uno en USD.
+func pagoPrueba(t *testing.T) Comprobante {
+	t.Helper()
+	datos, err := os.ReadFile(filepath.Join("testdata", "pago.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Comprobante
+	if err := Unmarshal(datos, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// decimalPrueba Interpreta s o termina la prueba.
+func decimalPrueba(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// comprobantePagoPrueba Devuelve un comprobante de tipo P por el pago en USD de la segunda parcialidad de la factura PPD de testdata/ingreso.xml.
+func comprobantePagoPrueba(t *testing.T) Comprobante {
+	t.Helper()
+	factura := facturaTimbrada(t)
+	factura.MetodoPago, factura.FormaPago = "PPD", "99"
+	docto, err := factura.Parcialidad(2, decimalPrueba(t, "1498.84"), decimalPrueba(t, "1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tipoCambio := decimalPrueba(t, "18.4528")
+	docto.TipoCambioDR = &tipoCambio
+	pago := Pago{FechaPago: "2026-10-17T09:00:00", FormaDePagoP: "03", MonedaP: "USD", TipoCambioP: &tipoCambio, Monto: decimalPrueba(t, "54.19"), NumOperacion: "SPEI 0042"}
+	pago.DoctoRelacionado = []DoctoRelacionado{docto}
+	c := ComprobantePago(factura, pago)
+	c.Serie, c.Folio, c.Fecha = "P", "10", "2026-10-17T12:30:00"
+	return c
+}
+
+func TestPagosRoundTrip(t *testing.T) {
+	c := comprobantePagoPrueba(t)
+	datos, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, esperado := range []string{`xmlns:pago10="` + NamespacePagos + `"`, NamespacePagos + " " + EsquemaPagos, `<pago10:Pagos Version="1.0">`, `TipoCambioDR="18.4528"`, `ImpSaldoInsoluto="498.84"`} {
+		if !strings.Contains(string(datos), esperado) {
+			t.Errorf("el XML no contiene %s:\n%s", esperado, datos)
+		}
+	}
+	var leido Comprobante
+	if err := Unmarshal(datos, &leido); err != nil {
+		t.Fatal(err)
+	}
+	if leido.Pagos() == nil || len(leido.Pagos().Pago) != 1 || len(leido.Pagos().Pago[0].DoctoRelacionado) != 1 {
+		t.Fatalf("complemento leído %+v", leido.Pagos())
+	}
+	if d := leido.Pagos().Pago[0].DoctoRelacionado[0]; d.NumParcialidad != 2 || d.ImpSaldoAnt.String() != "1498.84" || d.TipoCambioDR.String() != "18.4528" {
+		t.Errorf("documento relacionado leído %+v", d)
+	}
+	otra, err := Marshal(leido)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(otra) != string(datos) {
+		t.Errorf("el XML cambió al leerlo y escribirlo de nuevo:\n%s", diferencia(datos, otra))
+	}
+}
+
+// TestPagosCadena Compara la cadena original del comprobante de comprobantePagoPrueba con la que produce testdata/xslt/cadenaoriginal_3_3.xslt (con Pagos10.xslt) procesada con libxslt sobre el XML de Marshal. El comprobante no está sellado: NoCertificado es requerido y, vacío, deja su separador.
+func TestPagosCadena(t *testing.T) {
+	cadena, err := comprobantePagoPrueba(t).CadenaOriginal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const esperada = "||3.3|P|10|2026-10-17T12:30:00||0|XXX|0|P|45079|EKU9003173C9|ESCUELA KEMPER URGATE|601|URE180429TM6|UNIVERSIDAD ROBOTICA ESPAÑOLA|P01|84111506|1|ACT|Pago|0|0|1.0|2026-10-17T09:00:00|03|USD|18.4528|54.19|SPEI 0042|ED1752FE-E865-4FF2-BFE1-0F552E770DC9|F|1001|MXN|18.4528|PPD|2|1498.84|1000.00|498.84||"
+	if cadena != esperada {
+		t.Errorf("se esperaba\n  %s\nse obtuvo\n  %s", esperada, cadena)
+	}
+}
+
+func TestValidatePagos(t *testing.T) {
+	if errores := Validate(pagoPrueba(t)); errores != nil {
+		t.Fatalf("testdata/pago.xml reportó %v", errores)
+	}
+	const raiz = "/cfdi:Comprobante"
+	const rutaPago = raiz + "/cfdi:Complemento/pago10:Pagos/pago10:Pago[1]"
+	uno := NewDecimal(1, 0)
+	casos := []struct {
+		nombre   string
+		cambio   func(c *Comprobante)
+		esperado []string
+	}{
+		{"TipoCambio con Moneda XXX", func(c *Comprobante) { c.TipoCambio = &uno }, []string{"CRP108 " + raiz + "/@TipoCambio"}},
+		{"Moneda MXN con TipoCambio", func(c *Comprobante) { c.Moneda, c.TipoCambio = "MXN", &uno }, []string{"CRP103 " + raiz + "/@Moneda", "CRP108 " + raiz + "/@TipoCambio"}},
+		{"sin complemento", func(c *Comprobante) { c.Complemento = nil }, []string{"XMLS003 " + raiz + "/cfdi:Complemento"}},
+		{"FormaDePagoP 99", func(c *Comprobante) { c.Pagos().Pago[0].FormaDePagoP = "99" }, []string{"CRP201 " + rutaPago + "/@FormaDePagoP"}},
+		{"FormaDePagoP fuera del catálogo", func(c *Comprobante) { c.Pagos().Pago[0].FormaDePagoP = "ZZ" }, []string{"XMLS004 " + rutaPago + "/@FormaDePagoP"}},
+		{"MonedaP XXX", func(c *Comprobante) { c.Pagos().Pago[0].MonedaP = "XXX" }, []string{"CRP202 " + rutaPago + "/@MonedaP", "CRP203 " + rutaPago + "/@TipoCambioP"}},
+		{"MonedaP fuera del catálogo", func(c *Comprobante) { c.Pagos().Pago[0].MonedaP = "MX" }, []string{"XMLS005 " + rutaPago + "/@MonedaP"}},
+		{"saldo insoluto", func(c *Comprobante) {
+			d := decimalPrueba(t, "601.00")
+			c.Pagos().Pago[0].DoctoRelacionado[0].ImpSaldoInsoluto = &d
+		}, []string{"CRP225 " + rutaPago + "/pago10:DoctoRelacionado[1]/@ImpSaldoInsoluto"}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			c := pagoPrueba(t)
+			caso.cambio(&c)
+			m := codigos(Validate(c))
+			for _, esperado := range caso.esperado {
+				if !m[esperado] {
+					t.Errorf("no se reportó %s; se obtuvo %v", esperado, m)
+				}
+			}
+			for _, codigo := range []string{"XMLS003", "XMLS004", "XMLS005"} {
+				for clave := range m {
+					if strings.HasPrefix(clave, codigo) && !contiene(caso.esperado, clave) {
+						t.Errorf("se reportó %s sin corresponder", clave)
+					}
+				}
+			}
+		})
+	}
+}
+
+// contiene Indica si lista contiene s.
+func contiene(lista []string, s string) bool {
+	for _, v := range lista {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParcialidad(t *testing.T) {
+	factura := facturaTimbrada(t)
+	if _, err := factura.Parcialidad(1, factura.Total, factura.Total); err != ErrNoEsPPD {
+		t.Errorf("factura PUE: %v", err)
+	}
+	factura.MetodoPago = "PPD"
+	d, err := factura.Parcialidad(1, factura.Total, decimalPrueba(t, "500.004"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ImpPagado.String() != "500.00" || d.ImpSaldoInsoluto.String() != "1498.84" || d.IdDocumento != factura.Timbre().UUID || d.NumParcialidad != 1 {
+		t.Errorf("documento relacionado %+v", d)
+	}
+	if _, err := factura.Parcialidad(2, decimalPrueba(t, "10.00"), decimalPrueba(t, "10.01")); !errors.Is(err, ErrPagoExcedeSaldo) {
+		t.Errorf("pago mayor que el saldo: %v", err)
+	}
+}
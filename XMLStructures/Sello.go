@@ -0,0 +1,286 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Sello digital con el Certificado de Sello Digital (CSD)
+*
+*
+****************************************************************************************************************************************/
+
+// Errores devueltos al cargar un CSD, siempre envueltos en un *ErrorCSD.
+var (
+	ErrContrasena         = errors.New("contraseña incorrecta o llave privada dañada")
+	ErrAlgoritmoLlave     = errors.New("algoritmo de cifrado de la llave privada no soportado")
+	ErrLlaveNoRSA         = errors.New("la llave privada no es RSA")
+	ErrLlaveNoCorresponde = errors.New("la llave privada no corresponde al certificado")
+	ErrNoCertificado      = errors.New("el número de serie del certificado no es un número de certificado del SAT de 20 dígitos")
+)
+
+// ErrorCSD Error producido al cargar un certificado de sello digital o su llave privada. Indica el archivo que no pudo leerse.
+type ErrorCSD struct {
+	Archivo string // Ruta del archivo, o "certificado" o "llave privada" cuando se leyó desde memoria.
+	Err     error  // Error original.
+}
+
+func (e *ErrorCSD) Error() string {
+	return fmt.Sprintf("xmlstructures: %s: %v", e.Archivo, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorCSD) Unwrap() error { return e.Err }
+
+// CSD Certificado de Sello Digital emitido por el SAT junto con su llave privada descifrada. Se usa para sellar comprobantes.
+type CSD struct {
+	Certificado   *x509.Certificate // Certificado (.cer) del contribuyente.
+	Llave         *rsa.PrivateKey   // Llave privada (.key) ya descifrada.
+	NoCertificado string            // Número de certificado de 20 dígitos, obtenido del número de serie.
+}
+
+// LeerCSD Interpreta un certificado (.cer) y su llave privada (.key) en DER, tal como los entrega el SAT, o en PEM. La llave es un PKCS#8 cifrado con contrasena (PBES2 con PBKDF2 y 3DES o AES); si no está cifrada contrasena se ignora. Verifica que la llave corresponda al certificado.
+func LeerCSD(cer, key, contrasena []byte) (*CSD, error) {
+	return leerCSD(cer, key, contrasena, "certificado", "llave privada")
+}
+
+// CargarCSD Igual que LeerCSD pero lee el certificado y la llave de los archivos rutaCer y rutaKey.
+func CargarCSD(rutaCer, rutaKey string, contrasena []byte) (*CSD, error) {
+	cer, err := os.ReadFile(rutaCer)
+	if err != nil {
+		return nil, &ErrorCSD{Archivo: rutaCer, Err: err}
+	}
+	key, err := os.ReadFile(rutaKey)
+	if err != nil {
+		return nil, &ErrorCSD{Archivo: rutaKey, Err: err}
+	}
+	return leerCSD(cer, key, contrasena, rutaCer, rutaKey)
+}
+
+// leerCSD Implementa LeerCSD; nombreCer y nombreKey identifican cada archivo en los errores.
+func leerCSD(cer, key, contrasena []byte, nombreCer, nombreKey string) (*CSD, error) {
+	certificado, err := x509.ParseCertificate(desdePEM(cer, "CERTIFICATE"))
+	if err != nil {
+		return nil, &ErrorCSD{Archivo: nombreCer, Err: err}
+	}
+	noCertificado, err := NumeroCertificado(certificado)
+	if err != nil {
+		return nil, &ErrorCSD{Archivo: nombreCer, Err: err}
+	}
+	llave, err := leerLlavePrivada(desdePEM(key, "ENCRYPTED PRIVATE KEY", "PRIVATE KEY"), contrasena)
+	if err != nil {
+		return nil, &ErrorCSD{Archivo: nombreKey, Err: err}
+	}
+	publica, ok := certificado.PublicKey.(*rsa.PublicKey)
+	if !ok || publica.N.Cmp(llave.N) != 0 || publica.E != llave.E {
+		return nil, &ErrorCSD{Archivo: nombreKey, Err: ErrLlaveNoCorresponde}
+	}
+	return &CSD{Certificado: certificado, Llave: llave, NoCertificado: noCertificado}, nil
+}
+
+// NumeroCertificado Devuelve el número de certificado del SAT, que es el número de serie del certificado interpretado como texto ASCII, p.ej. 30001000000300023708.
+func NumeroCertificado(certificado *x509.Certificate) (string, error) {
+	serie := certificado.SerialNumber.Bytes()
+	if len(serie) != 20 {
+		return "", ErrNoCertificado
+	}
+	for _, b := range serie {
+		if b < '0' || b > '9' {
+			return "", ErrNoCertificado
+		}
+	}
+	return string(serie), nil
+}
+
+// CertificadoBase64 Devuelve el certificado en base 64, como se escribe en el atributo Certificado del comprobante.
+func (s *CSD) CertificadoBase64() string {
+	return base64.StdEncoding.EncodeToString(s.Certificado.Raw)
+}
+
+// Firmar Devuelve en base 64 la firma RSA-SHA256 (PKCS #1 v1.5) de cadena.
+func (s *CSD) Firmar(cadena []byte) (string, error) {
+	suma := sha256.Sum256(cadena)
+	firma, err := rsa.SignPKCS1v15(rand.Reader, s.Llave, crypto.SHA256, suma[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(firma), nil
+}
+
+// Sellar Llena NoCertificado, Certificado y Sello del comprobante. El sello es la firma de la cadena original, que ya incluye el NoCertificado. Si la cadena original no puede generarse devuelve el error de CadenaOriginal y c queda sin cambios.
+func (s *CSD) Sellar(c *Comprobante) error {
+	sellado := *c
+	sellado.NoCertificado = s.NoCertificado
+	sellado.Certificado = s.CertificadoBase64()
+	cadena, err := sellado.CadenaOriginal()
+	if err != nil {
+		return err
+	}
+	if sellado.Sello, err = s.Firmar([]byte(cadena)); err != nil {
+		return err
+	}
+	*c = sellado
+	return nil
+}
+
+// desdePEM Devuelve el contenido DER de datos si vienen en PEM con alguno de los tipos indicados, o datos sin cambios si no.
+func desdePEM(datos []byte, tipos ...string) []byte {
+	bloque, _ := pem.Decode(datos)
+	if bloque == nil {
+		return datos
+	}
+	for _, tipo := range tipos {
+		if bloque.Type == tipo {
+			return bloque.Bytes
+		}
+	}
+	return datos
+}
+
+/****************************************************************************************************************************************
+*
+*
+* Llaves privadas PKCS#8 cifradas (RFC 8018)
+*
+*
+****************************************************************************************************************************************/
+
+// Identificadores de los algoritmos admitidos para cifrar la llave privada.
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// llaveCifrada EncryptedPrivateKeyInfo de PKCS#8.
+type llaveCifrada struct {
+	Algoritmo asn1RawAlgoritmo
+	Datos     []byte
+}
+
+// asn1RawAlgoritmo AlgorithmIdentifier con los parámetros sin interpretar.
+type asn1RawAlgoritmo struct {
+	Algoritmo  asn1.ObjectIdentifier
+	Parametros asn1.RawValue `asn1:"optional"`
+}
+
+// parametrosPBES2 PBES2-params.
+type parametrosPBES2 struct {
+	Derivacion asn1RawAlgoritmo
+	Cifrado    asn1RawAlgoritmo
+}
+
+// parametrosPBKDF2 PBKDF2-params. La función pseudoaleatoria por omisión es HMAC-SHA1.
+type parametrosPBKDF2 struct {
+	Sal         []byte
+	Iteraciones int
+	Longitud    int              `asn1:"optional"`
+	Funcion     asn1RawAlgoritmo `asn1:"optional"`
+}
+
+// leerLlavePrivada Descifra, si hace falta, e interpreta una llave privada PKCS#8 RSA.
+func leerLlavePrivada(der, contrasena []byte) (*rsa.PrivateKey, error) {
+	var cifrada llaveCifrada
+	if resto, err := asn1.Unmarshal(der, &cifrada); err == nil && len(resto) == 0 && len(cifrada.Algoritmo.Algoritmo) > 0 {
+		if der, err = descifrarPBES2(cifrada, contrasena); err != nil {
+			return nil, err
+		}
+	}
+	llave, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, ErrContrasena
+	}
+	rsaLlave, ok := llave.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrLlaveNoRSA
+	}
+	return rsaLlave, nil
+}
+
+// descifrarPBES2 Descifra los datos de una llave cifrada con PBES2; la llave del cifrado se deriva con crypto/pbkdf2.
+func descifrarPBES2(cifrada llaveCifrada, contrasena []byte) ([]byte, error) {
+	if !cifrada.Algoritmo.Algoritmo.Equal(oidPBES2) {
+		return nil, fmt.Errorf("%w: %v", ErrAlgoritmoLlave, cifrada.Algoritmo.Algoritmo)
+	}
+	var pbes2 parametrosPBES2
+	if _, err := asn1.Unmarshal(cifrada.Algoritmo.Parametros.FullBytes, &pbes2); err != nil {
+		return nil, err
+	}
+	if !pbes2.Derivacion.Algoritmo.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("%w: %v", ErrAlgoritmoLlave, pbes2.Derivacion.Algoritmo)
+	}
+	var kdf parametrosPBKDF2
+	if _, err := asn1.Unmarshal(pbes2.Derivacion.Parametros.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+	funcion := sha1.New
+	switch {
+	case len(kdf.Funcion.Algoritmo) == 0, kdf.Funcion.Algoritmo.Equal(oidHMACWithSHA1):
+	case kdf.Funcion.Algoritmo.Equal(oidHMACWithSHA256):
+		funcion = sha256.New
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrAlgoritmoLlave, kdf.Funcion.Algoritmo)
+	}
+
+	var nuevoBloque func([]byte) (cipher.Block, error)
+	longitud := 0
+	switch c := pbes2.Cifrado.Algoritmo; {
+	case c.Equal(oidDESEDE3CBC):
+		nuevoBloque, longitud = des.NewTripleDESCipher, 24
+	case c.Equal(oidAES128CBC):
+		nuevoBloque, longitud = aes.NewCipher, 16
+	case c.Equal(oidAES192CBC):
+		nuevoBloque, longitud = aes.NewCipher, 24
+	case c.Equal(oidAES256CBC):
+		nuevoBloque, longitud = aes.NewCipher, 32
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrAlgoritmoLlave, c)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(pbes2.Cifrado.Parametros.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	llave, err := pbkdf2.Key(funcion, string(contrasena), kdf.Sal, kdf.Iteraciones, longitud)
+	if err != nil {
+		return nil, err
+	}
+	bloque, err := nuevoBloque(llave)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != bloque.BlockSize() || len(cifrada.Datos) == 0 || len(cifrada.Datos)%bloque.BlockSize() != 0 {
+		return nil, ErrContrasena
+	}
+	claro := make([]byte, len(cifrada.Datos))
+	cipher.NewCBCDecrypter(bloque, iv).CryptBlocks(claro, cifrada.Datos)
+	// Relleno PKCS #7: una contraseña incorrecta casi siempre produce un relleno inválido.
+	relleno := int(claro[len(claro)-1])
+	if relleno == 0 || relleno > bloque.BlockSize() || !bytes.Equal(claro[len(claro)-relleno:], bytes.Repeat([]byte{byte(relleno)}, relleno)) {
+		return nil, ErrContrasena
+	}
+	return claro[:len(claro)-relleno], nil
+}
@@ -0,0 +1,86 @@
+package xmlstructures
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Archivos de testdata/csd: un CSD de prueba generado con OpenSSL para AAA010101AAA con número de serie 30001000000300023708. La llave se cifró con la contraseña 12345678a en los dos esquemas que se encuentran en los .key del SAT: PBES2 con HMAC-SHA1 y 3DES (des3.key) y con HMAC-SHA256 y AES-256 (aes.key). otra.key es una llave sin cifrar que no corresponde al certificado. sello.b64 es la firma de cadena.txt hecha con openssl dgst -sha256 -sign.
+const (
+	contrasenaCSD    = "12345678a"
+	noCertificadoCSD = "30001000000300023708"
+)
+
+// csdPrueba Carga el CSD de prueba con la llave indicada.
+func csdPrueba(t *testing.T, llave string) *CSD {
+	t.Helper()
+	csd, err := CargarCSD(filepath.Join("testdata", "csd", "csd.cer"), filepath.Join("testdata", "csd", llave), []byte(contrasenaCSD))
+	if err != nil {
+		t.Fatalf("CargarCSD: %v", err)
+	}
+	return csd
+}
+
+func TestCargarCSD(t *testing.T) {
+	for _, llave := range []string{"des3.key", "aes.key"} {
+		t.Run(llave, func(t *testing.T) {
+			csd := csdPrueba(t, llave)
+			if csd.NoCertificado != noCertificadoCSD {
+				t.Errorf("NoCertificado %s, se esperaba %s", csd.NoCertificado, noCertificadoCSD)
+			}
+			cadena, err := os.ReadFile(filepath.Join("testdata", "csd", "cadena.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			esperado, err := os.ReadFile(filepath.Join("testdata", "csd", "sello.b64"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sello, err := csd.Firmar(cadena); err != nil || sello != string(esperado) {
+				t.Errorf("Firmar difiere de OpenSSL (err %v):\n  se esperaba: %s\n  se obtuvo:   %s", err, esperado, sello)
+			}
+		})
+	}
+}
+
+func TestCargarCSDErrores(t *testing.T) {
+	cer := filepath.Join("testdata", "csd", "csd.cer")
+	_, err := CargarCSD(cer, filepath.Join("testdata", "csd", "des3.key"), []byte("otra"))
+	var errCSD *ErrorCSD
+	if !errors.Is(err, ErrContrasena) || !errors.As(err, &errCSD) || errCSD.Archivo != filepath.Join("testdata", "csd", "des3.key") {
+		t.Errorf("contraseña incorrecta: se esperaba ErrContrasena en la llave, se obtuvo %v", err)
+	}
+	if _, err := CargarCSD(cer, filepath.Join("testdata", "csd", "otra.key"), nil); !errors.Is(err, ErrLlaveNoCorresponde) {
+		t.Errorf("llave de otro certificado: se esperaba ErrLlaveNoCorresponde, se obtuvo %v", err)
+	}
+}
+
+func TestSellar(t *testing.T) {
+	csd := csdPrueba(t, "des3.key")
+	c := comprobantePrueba(3)
+	c.Emisor.RFC = "AAA010101AAA"
+	if err := csd.Sellar(&c); err != nil {
+		t.Fatalf("Sellar: %v", err)
+	}
+	if c.NoCertificado != noCertificadoCSD || c.Certificado != csd.CertificadoBase64() {
+		t.Errorf("NoCertificado/Certificado no se llenaron: %s", c.NoCertificado)
+	}
+	cadena, err := c.CadenaOriginal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	firma, err := base64.StdEncoding.DecodeString(c.Sello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suma := sha256.Sum256([]byte(cadena))
+	if err := rsa.VerifyPKCS1v15(csd.Certificado.PublicKey.(*rsa.PublicKey), crypto.SHA256, suma[:], firma); err != nil {
+		t.Errorf("el Sello no verifica con el certificado: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package xmlstructures
+
+/****************************************************************************************************************************************
+*
+*
+* Sustitución de comprobantes
+*
+*
+****************************************************************************************************************************************/
+
+// TipoRelacionSustitucion Clave de c_TipoRelacion con que el comprobante que sustituye a otro cancelado lo relaciona.
+const TipoRelacionSustitucion = "04"
+
+// Sustitucion Devuelve un borrador del comprobante que sustituye a c: una copia independiente con los mismos datos, relacionada con el UUID de c por TipoRelacionSustitucion y sin Fecha, sello, certificado ni Timbre Fiscal Digital. Los demás complementos y la addenda se conservan. Como la versión 3.3 admite un solo tipo de relación, la relación que tuviera c se reemplaza.
+//
+// El borrador se corrige, se le asigna la Fecha de expedición y se sella y timbra como cualquier comprobante; pac.Cancelador.Sustituir hace además la cancelación del original. Devuelve ErrSinTimbre si c no ha sido timbrado.
+func (c Comprobante) Sustitucion() (Comprobante, error) {
+	t := c.Timbre()
+	if t == nil {
+		return Comprobante{}, ErrSinTimbre
+	}
+	// La copia se hace a través del XML porque los complementos pueden ser de cualquier tipo registrado.
+	datos, err := Marshal(c)
+	if err != nil {
+		return Comprobante{}, err
+	}
+	var s Comprobante
+	if err := Unmarshal(datos, &s); err != nil {
+		return Comprobante{}, err
+	}
+	s.Fecha = ""
+	s.Sello = ""
+	s.NoCertificado = ""
+	s.Certificado = ""
+	s.Relacionados = &CFDIRelacionados{TipoRelacion: TipoRelacionSustitucion, CfdiRelacionado: []CFDIRelacionado{{UUID: t.UUID}}}
+	if s.Complemento != nil {
+		var complementos []interface{}
+		for _, v := range s.Complemento.Complementos {
+			switch v.(type) {
+			case *CFDITimbre, CFDITimbre:
+			default:
+				complementos = append(complementos, v)
+			}
+		}
+		s.Complemento.Complementos = complementos
+		if len(complementos) == 0 {
+			s.Complemento = nil
+		}
+	}
+	return s, nil
+}
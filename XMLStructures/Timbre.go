@@ -0,0 +1,144 @@
+package xmlstructures
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Timbre Fiscal Digital 1.1
+*
+*
+****************************************************************************************************************************************/
+
+// Errores devueltos al verificar un timbre, siempre envueltos en un *ErrorVerificacion.
+var (
+	ErrSinTimbre                 = errors.New("el comprobante no ha sido timbrado")
+	ErrCertificadoSATDesconocido = errors.New("el certificado del SAT no está en el almacén")
+	ErrNoEsCertificadoSAT        = errors.New("el número de serie no corresponde a un certificado del SAT")
+)
+
+// rutaTimbre Ruta del Timbre Fiscal Digital dentro del comprobante, para los errores.
+const rutaTimbre = "/cfdi:Comprobante/cfdi:Complemento/tfd:TimbreFiscalDigital"
+
+// CadenaOriginal Devuelve la cadena original del timbre según cadenaoriginal_TFD_1_1.xslt del SAT. No incluye SelloSAT, que es la firma de esta cadena.
+func (t CFDITimbre) CadenaOriginal() string {
+	cad := &Cadena{}
+	cad.Requerido(t.Version)
+	cad.Requerido(t.UUID)
+	cad.Requerido(t.FechaTimbrado)
+	cad.Requerido(t.RFCProvCertif)
+	cad.Opcional(t.Leyenda)
+	cad.Requerido(t.SelloCFD)
+	cad.Requerido(t.NoCertificadoSAT)
+	return cad.String()
+}
+
+// AlmacenCertificados Certificados del SAT con que se verifican los timbres, indexados por número de certificado. El SAT los publica para su descarga; el valor cero es un almacén vacío listo para usarse y puede compartirse entre goroutines.
+type AlmacenCertificados struct {
+	mu           sync.RWMutex
+	certificados map[string]*x509.Certificate
+}
+
+// Agregar Agrega un certificado al almacén. Devuelve ErrNoEsCertificadoSAT si su número de serie no tiene el formato del SAT.
+func (a *AlmacenCertificados) Agregar(certificado *x509.Certificate) error {
+	numero, err := NumeroCertificado(certificado)
+	if err != nil {
+		return ErrNoEsCertificadoSAT
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.certificados == nil {
+		a.certificados = map[string]*x509.Certificate{}
+	}
+	a.certificados[numero] = certificado
+	return nil
+}
+
+// CargarDirectorio Agrega al almacén todos los archivos .cer (DER o PEM) de directorio.
+func (a *AlmacenCertificados) CargarDirectorio(directorio string) error {
+	archivos, err := filepath.Glob(filepath.Join(directorio, "*.cer"))
+	if err != nil {
+		return err
+	}
+	for _, archivo := range archivos {
+		datos, err := os.ReadFile(archivo)
+		if err != nil {
+			return err
+		}
+		certificado, err := x509.ParseCertificate(desdePEM(datos, "CERTIFICATE"))
+		if err != nil {
+			return &ErrorCSD{Archivo: archivo, Err: err}
+		}
+		if err := a.Agregar(certificado); err != nil {
+			return &ErrorCSD{Archivo: archivo, Err: err}
+		}
+	}
+	return nil
+}
+
+// Buscar Devuelve el certificado con el número noCertificado.
+func (a *AlmacenCertificados) Buscar(noCertificado string) (*x509.Certificate, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	certificado, ok := a.certificados[noCertificado]
+	return certificado, ok
+}
+
+// ReporteTimbre Resultado de verificar el Timbre Fiscal Digital de un comprobante. Cada campo booleano corresponde a una revisión; Valido indica si se cumplieron todas.
+type ReporteTimbre struct {
+	CadenaOriginal string            // Cadena original del timbre.
+	Certificado    *x509.Certificate // Certificado del SAT con número NoCertificadoSAT.
+	SelloSATValido bool              // El SelloSAT es la firma RSA-SHA256 de la cadena original del timbre con la llave del certificado del SAT.
+	SelloCFDValido bool              // El SelloCFD del timbre es el Sello del comprobante.
+	VigenteEnFecha bool              // La FechaTimbrado está dentro del periodo de validez del certificado del SAT.
+}
+
+// Valido Indica si el timbre pasó todas las revisiones.
+func (r *ReporteTimbre) Valido() bool {
+	return r.SelloSATValido && r.SelloCFDValido && r.VigenteEnFecha
+}
+
+// Problemas Devuelve una descripción de cada revisión que no se cumplió, en el orden de los campos del reporte.
+func (r *ReporteTimbre) Problemas() []string {
+	var problemas []string
+	if !r.SelloSATValido {
+		problemas = append(problemas, "el sello del SAT no corresponde a la cadena original del timbre")
+	}
+	if !r.SelloCFDValido {
+		problemas = append(problemas, "el SelloCFD del timbre no es el sello del comprobante")
+	}
+	if !r.VigenteEnFecha {
+		problemas = append(problemas, "la fecha de timbrado está fuera de la vigencia del certificado del SAT")
+	}
+	return problemas
+}
+
+// VerificarTimbre Verifica el Timbre Fiscal Digital del comprobante con el certificado del SAT de número NoCertificadoSAT tomado del almacén. Para verificar también el sello del emisor se usa VerificarSello.
+//
+// Devuelve un *ErrorVerificacion si el comprobante no tiene timbre o el certificado no está en el almacén; el resultado de las revisiones se informa en el reporte.
+func (a *AlmacenCertificados) VerificarTimbre(c Comprobante) (*ReporteTimbre, error) {
+	t := c.Timbre()
+	if t == nil {
+		return nil, &ErrorVerificacion{Nodo: rutaTimbre, Err: ErrSinTimbre}
+	}
+	certificado, ok := a.Buscar(t.NoCertificadoSAT)
+	if !ok {
+		return nil, &ErrorVerificacion{Nodo: rutaTimbre + "/@NoCertificadoSAT", Err: fmt.Errorf("%w: %s", ErrCertificadoSATDesconocido, t.NoCertificadoSAT)}
+	}
+	r := &ReporteTimbre{CadenaOriginal: t.CadenaOriginal(), Certificado: certificado}
+	r.SelloSATValido = verificarFirma(certificado, []byte(r.CadenaOriginal), t.SelloSAT)
+	r.SelloCFDValido = strings.Join(strings.Fields(t.SelloCFD), "") == strings.Join(strings.Fields(c.Sello), "")
+	if fecha, err := time.ParseInLocation("2006-01-02T15:04:05", t.FechaTimbrado, zonaCentro); err == nil {
+		r.VigenteEnFecha = !fecha.Before(certificado.NotBefore) && !fecha.After(certificado.NotAfter)
+	}
+	return r, nil
+}
@@ -0,0 +1,183 @@
+package xmlstructures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// selloPrueba Valor de ejemplo para los atributos de sello en las cadenas del timbre.
+const selloPrueba = "OlWn2H4jkFVOEPWZyb+FdvnJ3XEOvIZCK2UqF8s6LM8Ci0pyB3BcSp3UkuLw9IPkZ7VUpfT2HHNxGAJBvfxLY48Z0iSmDqU1tvKVJeKzsdRaHgLu/Fy6p6yNrq6sI7EcoGp7slDQuQJu2ytSPGyWU9nJrNqKXJcdXBpg3c7IRt8rOajP38z3qY3bqyKWoGhXjhdi2l4NNzTXxCkQdA+RBFLj0Swjn+oaCfEQQ43DEKb2HVxzomYkfASgKq7etkt6t7T45PqfTgYOQtphl0XDLfUjzmLJiYmY7Lts3SpZLrV4zHsw8kKwYFu1MXMtzxr7aJe6hGz5jiApPfG3IqFHrg=="
+
+func TestTimbreCadenaOriginal(t *testing.T) {
+	timbre := CFDITimbre{
+		Version:          "1.1",
+		UUID:             "ED1752FE-E865-4FF2-BFE1-0F552E770DC9",
+		FechaTimbrado:    "2026-10-16T22:01:00",
+		RFCProvCertif:    "SAT970701NN3",
+		SelloCFD:         selloPrueba,
+		NoCertificadoSAT: "30001000000300023708",
+	}
+	casos := []struct {
+		nombre   string
+		leyenda  string
+		esperada string
+	}{
+		{"sin Leyenda", "", "||1.1|ED1752FE-E865-4FF2-BFE1-0F552E770DC9|2026-10-16T22:01:00|SAT970701NN3|" + selloPrueba + "|30001000000300023708||"},
+		{"con Leyenda", "  Comprobante   de prueba ", "||1.1|ED1752FE-E865-4FF2-BFE1-0F552E770DC9|2026-10-16T22:01:00|SAT970701NN3|Comprobante de prueba|" + selloPrueba + "|30001000000300023708||"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			timbre.Leyenda = caso.leyenda
+			if cadena := timbre.CadenaOriginal(); cadena != caso.esperada {
+				t.Errorf("se esperaba\n  %s\nse obtuvo\n  %s", caso.esperada, cadena)
+			}
+		})
+	}
+}
+
+// almacenPrueba Carga en un AlmacenCertificados el certificado de prueba, que hace las veces del certificado del SAT, desde un directorio temporal con los archivos adicionales indicados.
+func almacenPrueba(t *testing.T, archivos map[string][]byte) (*AlmacenCertificados, string, error) {
+	t.Helper()
+	directorio := t.TempDir()
+	cer, err := os.ReadFile(filepath.Join("testdata", "csd", "csd.cer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivos["sat.cer"] = cer
+	for nombre, datos := range archivos {
+		if err := os.WriteFile(filepath.Join(directorio, nombre), datos, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	almacen := &AlmacenCertificados{}
+	return almacen, directorio, almacen.CargarDirectorio(directorio)
+}
+
+// timbradoPrueba Devuelve el comprobante de firmadoPrueba con un timbre sellado con el CSD de prueba después de aplicarle cambio.
+func timbradoPrueba(t *testing.T, cambio func(timbre *CFDITimbre)) Comprobante {
+	t.Helper()
+	c := firmadoPrueba(t)
+	timbre := &CFDITimbre{
+		Version:          "1.1",
+		UUID:             "ED1752FE-E865-4FF2-BFE1-0F552E770DC9",
+		FechaTimbrado:    "2026-10-16T22:01:00",
+		RFCProvCertif:    "SAT970701NN3",
+		SelloCFD:         c.Sello,
+		NoCertificadoSAT: noCertificadoCSD,
+	}
+	if cambio != nil {
+		cambio(timbre)
+	}
+	sello, err := csdPrueba(t, "des3.key").Firmar([]byte(timbre.CadenaOriginal()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timbre.SelloSAT = sello
+	c.AgregarComplemento(timbre)
+	return c
+}
+
+func TestVerificarTimbre(t *testing.T) {
+	almacen, _, err := almacenPrueba(t, map[string][]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	casos := []struct {
+		nombre                  string
+		comprobante             Comprobante
+		selloSAT, selloCFD, vig bool
+	}{
+		{"válido", timbradoPrueba(t, nil), true, true, true},
+		{"con Leyenda", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.Leyenda = "Comprobante de prueba" }), true, true, true},
+		{"inicio de la vigencia", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.FechaTimbrado = "2026-10-16T21:52:20" }), true, true, true},
+		{"antes de la vigencia", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.FechaTimbrado = "2026-10-16T21:52:19" }), true, true, false},
+		{"después de la vigencia", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.FechaTimbrado = "2036-10-13T21:52:21" }), true, true, false},
+		{"SelloCFD de otro comprobante", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.SelloCFD = selloPrueba }), true, false, true},
+		{"SelloSAT alterado", func() Comprobante {
+			c := timbradoPrueba(t, nil)
+			timbre := c.Timbre()
+			i := len(timbre.SelloSAT) / 2
+			cambio := "A"
+			if timbre.SelloSAT[i] == 'A' {
+				cambio = "B"
+			}
+			timbre.SelloSAT = timbre.SelloSAT[:i] + cambio + timbre.SelloSAT[i+1:]
+			return c
+		}(), false, true, true},
+		{"cadena alterada", func() Comprobante {
+			c := timbradoPrueba(t, nil)
+			c.Timbre().UUID = "ED1752FE-E865-4FF2-BFE1-0F552E770DC8"
+			return c
+		}(), false, true, true},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			r, err := almacen.VerificarTimbre(caso.comprobante)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.SelloSATValido != caso.selloSAT || r.SelloCFDValido != caso.selloCFD || r.VigenteEnFecha != caso.vig {
+				t.Errorf("SelloSAT %v, SelloCFD %v, VigenteEnFecha %v: %v", r.SelloSATValido, r.SelloCFDValido, r.VigenteEnFecha, r.Problemas())
+			}
+			if valido := caso.selloSAT && caso.selloCFD && caso.vig; r.Valido() != valido || len(r.Problemas()) == 0 != valido {
+				t.Errorf("Valido %v con los problemas %v", r.Valido(), r.Problemas())
+			}
+			if r.CadenaOriginal != caso.comprobante.Timbre().CadenaOriginal() || r.Certificado == nil {
+				t.Errorf("reporte %+v", r)
+			}
+		})
+	}
+}
+
+func TestVerificarTimbreErrores(t *testing.T) {
+	almacen, _, err := almacenPrueba(t, map[string][]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	casos := []struct {
+		nombre      string
+		comprobante Comprobante
+		nodo        string
+		esperado    error
+	}{
+		{"sin timbre", firmadoPrueba(t), rutaTimbre, ErrSinTimbre},
+		{"certificado desconocido", timbradoPrueba(t, func(timbre *CFDITimbre) { timbre.NoCertificadoSAT = "00001000000504465028" }), rutaTimbre + "/@NoCertificadoSAT", ErrCertificadoSATDesconocido},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			r, err := almacen.VerificarTimbre(caso.comprobante)
+			var e *ErrorVerificacion
+			if r != nil || !errors.As(err, &e) || !errors.Is(err, caso.esperado) {
+				t.Fatalf("se esperaba un *ErrorVerificacion con %v, se obtuvo %v, %v", caso.esperado, r, err)
+			}
+			if e.Nodo != caso.nodo {
+				t.Errorf("nodo %s, se esperaba %s", e.Nodo, caso.nodo)
+			}
+		})
+	}
+	if _, err := (&AlmacenCertificados{}).VerificarTimbre(timbradoPrueba(t, nil)); !errors.Is(err, ErrCertificadoSATDesconocido) || !strings.Contains(err.Error(), noCertificadoCSD) {
+		t.Errorf("un almacén vacío devolvió %v", err)
+	}
+}
+
+func TestCargarDirectorio(t *testing.T) {
+	almacen, _, err := almacenPrueba(t, map[string][]byte{"notas.txt": []byte("no es un certificado")})
+	if err != nil {
+		t.Fatalf("un archivo que no es .cer impidió cargar el directorio: %v", err)
+	}
+	if certificado, ok := almacen.Buscar(noCertificadoCSD); !ok || certificado == nil {
+		t.Error("no se cargó el certificado del directorio")
+	}
+
+	_, directorio, err := almacenPrueba(t, map[string][]byte{"roto.cer": []byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n")})
+	var e *ErrorCSD
+	if !errors.As(err, &e) {
+		t.Fatalf("se esperaba un *ErrorCSD, se obtuvo %v", err)
+	}
+	if e.Archivo != filepath.Join(directorio, "roto.cer") {
+		t.Errorf("archivo %s", e.Archivo)
+	}
+}
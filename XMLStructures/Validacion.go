@@ -0,0 +1,747 @@
+package xmlstructures
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"./Catalogos"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Validación semántica (matriz de errores del Anexo 20)
+*
+*
+****************************************************************************************************************************************/
+
+// ErrorValidacion Incumplimiento de una regla de la matriz de errores del Anexo 20.
+type ErrorValidacion struct {
+	Codigo  string // Clave oficial del error, p.ej. CFDI33118. Las reglas que no tienen clave en la matriz usan claves propias del paquete con prefijo XMLS, p.ej. XMLS001.
+	XPath   string // Nodo o atributo que incumple la regla, p.ej. /cfdi:Comprobante/@Total.
+	Mensaje string // Descripción del error.
+}
+
+func (e ErrorValidacion) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Codigo, e.XPath, e.Mensaje)
+}
+
+// mensajesValidacion Descripción de cada clave de error que revisa Validate.
+var mensajesValidacion = map[string]string{
+	"CFDI33101": "El campo Fecha no cumple con el patrón requerido.",
+	"CFDI33103": "Si existe el complemento para recepción de pagos el campo FormaPago no debe existir.",
+	"CFDI33104": "El campo FormaPago no contiene un valor del catálogo c_FormaPago.",
+	"CFDI33106": "El valor de este campo SubTotal excede la cantidad de decimales que soporta la moneda.",
+	"CFDI33107": "El TipoDeComprobante es I, E o N, el importe registrado en el campo no es igual a la suma de los importes de los conceptos registrados.",
+	"CFDI33108": "El TipoDeComprobante es T o P y el importe no es igual a 0, o cero con decimales.",
+	"CFDI33109": "El valor registrado en el campo Descuento no es menor o igual que el campo Subtotal.",
+	"CFDI33110": "El valor del campo Descuento excede la cantidad de decimales que soporta la moneda.",
+	"CFDI33111": "El valor del campo Descuento no corresponde a la suma de los descuentos de los conceptos.",
+	"CFDI33112": "El campo Moneda no contiene un valor del catálogo c_Moneda.",
+	"CFDI33113": "El campo TipoCambio no tiene el valor \"1\" y la moneda indicada es MXN.",
+	"CFDI33114": "El campo TipoCambio se debe registrar cuando el campo Moneda tiene un valor distinto de MXN y XXX.",
+	"CFDI33115": "El campo TipoCambio no se debe registrar cuando el campo Moneda tiene el valor XXX.",
+	"CFDI33116": "El campo TipoCambio no cumple con el patrón requerido.",
+	"CFDI33117": "El valor del campo Total excede la cantidad de decimales que soporta la moneda.",
+	"CFDI33118": "El campo Total no corresponde con la suma del subtotal, menos los descuentos aplicables, más las contribuciones recibidas (impuestos trasladados) menos los impuestos retenidos.",
+	"CFDI33120": "El campo TipoDeComprobante no contiene un valor del catálogo c_TipoDeComprobante.",
+	"CFDI33121": "El campo MetodoPago no contiene un valor del catálogo c_MetodoPago.",
+	"CFDI33123": "Se debe omitir el campo MetodoPago cuando el TipoDeComprobante es T o P.",
+	"CFDI33125": "El campo LugarExpedicion, no contiene un valor del catálogo c_CodigoPostal.",
+	"CFDI33129": "El campo TipoRelacion no contiene un valor del catálogo c_TipoRelacion.",
+	"CFDI33130": "El campo RegimenFiscal, no contiene un valor del catálogo c_RegimenFiscal.",
+	"CFDI33131": "La clave del campo RegimenFiscal debe corresponder con el tipo de persona (física o moral).",
+	"CFDI33133": "El campo ResidenciaFiscal, no contiene un valor del catálogo c_Pais.",
+	"CFDI33140": "El campo UsoCFDI, no contiene un valor del catálogo c_UsoCFDI.",
+	"CFDI33141": "La clave del campo UsoCFDI debe corresponder con el tipo de persona (física o moral).",
+	"CFDI33142": "El campo ClaveProdServ, no contiene un valor del catálogo c_ClaveProdServ.",
+	"CFDI33145": "El campo ClaveUnidad no contiene un valor del catálogo c_ClaveUnidad.",
+	"CFDI33147": "El valor del campo ValorUnitario debe ser mayor que cero (0) cuando el tipo de comprobante es Ingreso, Egreso o Nómina.",
+	"CFDI33149": "El valor del campo Importe no se encuentra entre el límite inferior y superior permitido.",
+	"CFDI33150": "El valor del campo Descuento debe tener hasta la cantidad de decimales que tenga registrado el atributo Importe del concepto.",
+	"CFDI33151": "El valor del campo Descuento es mayor que el campo Importe.",
+	"CFDI33152": "En caso de utilizar el nodo Impuestos en un concepto, se deben incluir impuestos de traslado y/o retenciones.",
+	"CFDI33154": "El valor del campo Base que corresponde a Traslado debe ser mayor que cero.",
+	"CFDI33157": "Si el valor registrado en el campo TipoFactor que corresponde a Traslado es Exento no se deben registrar los campos TasaOCuota ni Importe.",
+	"CFDI33158": "Si el valor registrado en el campo TipoFactor que corresponde a Traslado es Tasa o Cuota, es obligatorio registrar los campos TasaOCuota e Importe.",
+	"CFDI33159": "El valor del campo TasaOCuota que corresponde a Traslado no contiene un valor del catálogo c_TasaOCuota o se encuentra fuera de rango.",
+	"CFDI33161": "El valor del campo Importe o que corresponde a Traslado no se encuentra entre el límite inferior y superior permitido.",
+	"CFDI33163": "El valor del campo Base que corresponde a Retención debe ser mayor que cero.",
+	"CFDI33166": "Si el valor registrado en el campo TipoFactor que corresponde a Retención debe ser distinto de Exento.",
+	"CFDI33167": "El valor del campo Importe que corresponde a Retención no se encuentra entre el límite inferior y superior permitido.",
+	"CFDI33179": "Cuando el TipoDeComprobante sea T o P, el elemento Impuestos no debe existir.",
+	"CFDI33180": "El valor del campo TotalImpuestosRetenidos debe ser igual a la suma de los importes registrados en el elemento hijo Retencion.",
+	"CFDI33182": "El valor del campo TotalImpuestosTrasladados no es igual a la suma de los importes registrados en el elemento hijo Traslado.",
+	"CFDI33185": "Debe existir el campo Impuesto de Retención en los conceptos.",
+	"CFDI33186": "El valor del campo Importe correspondiente a Retención no es igual a la suma de los importes de los impuestos retenidos registrados en los conceptos donde el impuesto sea igual al campo impuesto de este elemento.",
+	"CFDI33187": "Debe existir en los conceptos el Traslado con la misma combinación de Impuesto, TipoFactor y TasaOCuota.",
+	"CFDI33189": "El valor del campo Importe correspondiente a Traslado no es igual a la suma de los importes de los impuestos trasladados registrados en los conceptos donde el impuesto del concepto sea igual al campo impuesto de este elemento y la TasaOCuota del concepto sea igual al campo TasaOCuota de este elemento.",
+	"CFDI33190": "El valor del campo Importe correspondiente a Traslado o Retención excede la cantidad de decimales que soporta la moneda.",
+	"CRP101":    "El valor del campo TipoDeComprobante debe ser \"P\".",
+	"CRP103":    "El valor del campo Moneda debe ser \"XXX\".",
+	"CRP106":    "El campo CondicionesDePago no se debe registrar en el CFDI.",
+	"CRP107":    "El campo Descuento no se debe registrar en el CFDI.",
+	"CRP108":    "El campo TipoCambio no se debe registrar en el CFDI.",
+	"CRP109":    "El valor del campo Total debe ser cero \"0\".",
+	"CRP110":    "El valor del campo UsoCFDI debe ser \"P01\".",
+	"CRP111":    "El valor del campo ClaveProdServ debe ser \"84111506\".",
+	"CRP112":    "No se debe registrar el campo NoIdentificacion.",
+	"CRP113":    "El valor del campo Cantidad debe ser \"1\".",
+	"CRP114":    "El valor del campo ClaveUnidad debe ser \"ACT\".",
+	"CRP115":    "No se debe registrar el campo Unidad.",
+	"CRP116":    "El valor del campo Descripcion debe ser \"Pago\".",
+	"CRP117":    "El valor del campo ValorUnitario debe ser cero \"0\".",
+	"CRP118":    "El valor del campo Importe debe ser cero \"0\".",
+	"CRP119":    "No se debe registrar el campo Descuento en el concepto.",
+	"CRP120":    "Sólo debe existir un concepto y no se deben registrar nodos hijos en él.",
+	"CRP201":    "El valor del campo FormaDePagoP debe ser distinto de \"99\".",
+	"CRP202":    "El campo MonedaP debe ser distinto de \"XXX\".",
+	"CRP203":    "El campo TipoCambioP se debe registrar cuando el campo MonedaP tiene un valor distinto de MXN.",
+	"CRP204":    "El campo TipoCambioP no se debe registrar cuando el campo MonedaP tiene el valor MXN.",
+	"CRP206":    "La suma de los valores registrados en el campo ImpPagado de los apartados DoctoRelacionado no es menor o igual que el valor del campo Monto.",
+	"CRP207":    "El valor del campo Monto no es mayor que cero \"0\".",
+	"CRP208":    "El valor del campo Monto tiene más decimales que los soportados por la moneda.",
+	"CRP217":    "El valor del campo MonedaDR debe ser distinto de \"XXX\".",
+	"CRP218":    "El campo TipoCambioDR se debe registrar cuando el campo MonedaDR tiene un valor distinto del campo MonedaP.",
+	"CRP219":    "El campo TipoCambioDR no se debe registrar cuando el campo MonedaDR tiene el mismo valor que el campo MonedaP.",
+	"CRP220":    "El campo TipoCambioDR debe ser \"1\" cuando el campo MonedaDR tiene el valor MXN y el campo MonedaP un valor distinto.",
+	"CRP221":    "El campo ImpSaldoAnt debe ser mayor a cero y tener a lo más los decimales de la moneda del documento relacionado.",
+	"CRP223":    "El campo ImpPagado debe ser mayor a cero y tener a lo más los decimales de la moneda del documento relacionado.",
+	"CRP225":    "El campo ImpSaldoInsoluto debe ser mayor o igual a cero y calcularse como ImpSaldoAnt menos ImpPagado.",
+	"CRP227":    "El campo CertPago se debe registrar si existe el campo TipoCadPago.",
+	"CRP228":    "El campo CertPago no se debe registrar si no existe el campo TipoCadPago.",
+	"CRP229":    "El campo CadPago se debe registrar si existe el campo TipoCadPago.",
+	"CRP230":    "El campo CadPago no se debe registrar si no existe el campo TipoCadPago.",
+	"CRP231":    "El campo SelloPago se debe registrar si existe el campo TipoCadPago.",
+	"CRP232":    "El campo SelloPago no se debe registrar si no existe el campo TipoCadPago.",
+	"CRP233":    "El campo NumParcialidad se debe registrar cuando el campo MetodoDePagoDR tiene el valor PPD.",
+	"CRP234":    "El campo ImpSaldoAnt se debe registrar cuando el campo MetodoDePagoDR tiene el valor PPD.",
+	"CRP235":    "El campo ImpPagado se debe registrar cuando existe más de un documento relacionado o existe el campo TipoCambioDR.",
+	"CRP236":    "El campo ImpSaldoInsoluto se debe registrar cuando el campo MetodoDePagoDR tiene el valor PPD.",
+	"CRP237":    "No debe existir el apartado de Impuestos.",
+	"XMLS001":   "El valor excede los 18 dígitos enteros o los 6 decimales que admite el esquema.",
+	"XMLS002":   "Cuando el campo MetodoPago tiene el valor PPD, el campo FormaPago debe tener el valor 99 (Por definir), según la guía de llenado del Anexo 20.",
+	"XMLS003":   "El comprobante de tipo P debe incluir el complemento para recepción de pagos.",
+	"XMLS004":   "El campo FormaDePagoP no contiene un valor del catálogo c_FormaPago.",
+	"XMLS005":   "El campo MonedaP no contiene un valor del catálogo c_Moneda.",
+	"XMLS006":   "Cuando los conceptos tienen traslados o retenciones con importe, el elemento Impuestos del comprobante debe existir.",
+	"XMLS007":   "El impuesto del concepto debe estar agrupado en el elemento Impuestos del comprobante, en una Retención con el mismo Impuesto o en un Traslado con la misma combinación de Impuesto, TipoFactor y TasaOCuota.",
+	"XMLS008":   "Debe haber sólo una Retención por Impuesto y un Traslado por combinación de Impuesto, TipoFactor y TasaOCuota en el elemento Impuestos del comprobante.",
+	"XMLS009":   "El valor del campo TotalImpuestosRetenidos o TotalImpuestosTrasladados excede la cantidad de decimales que soporta la moneda.",
+}
+
+// patronFecha Patrón tdCFDI:t_FechaH de la fecha de expedición.
+var patronFecha = regexp.MustCompile(`^(20[1-9][0-9])-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])T(([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9])$`)
+
+// Validate Revisa c contra las reglas de la matriz de errores del Anexo 20 que pueden comprobarse sin consultar al SAT: importes y sus decimales, totales, moneda y tipo de cambio, claves de los catálogos del paquete catalogos y su vigencia en la fecha de expedición, el tipo de persona de UsoCFDI y RegimenFiscal, la congruencia entre los impuestos de los conceptos y los del comprobante y, en los comprobantes de tipo P, las reglas del complemento para recepción de pagos. Devuelve un error por cada regla incumplida, en orden de documento; nil si no hay errores.
+func Validate(c Comprobante) []ErrorValidacion {
+	v := &validador{c: c, decimales: DecimalesMoneda(c.Moneda)}
+	v.fecha, _ = time.Parse("2006-01-02T15:04:05", c.Fecha)
+	v.comprobante()
+	v.emisorReceptor()
+	v.conceptos()
+	v.impuestos()
+	v.pagos()
+	return v.errores
+}
+
+// validador Acumula los errores encontrados al revisar un comprobante.
+type validador struct {
+	c         Comprobante
+	decimales int32     // Decimales de la moneda del comprobante.
+	fecha     time.Time // Fecha de expedición, para revisar la vigencia de las claves; cero si no es válida.
+	errores   []ErrorValidacion
+}
+
+// agregar Registra un incumplimiento de la regla codigo en xpath.
+func (v *validador) agregar(codigo, xpath string) {
+	v.errores = append(v.errores, ErrorValidacion{Codigo: codigo, XPath: xpath, Mensaje: mensajesValidacion[codigo]})
+}
+
+// admite Indica si clave puede usarse en la fecha del comprobante según el catálogo nombre. En los catálogos incompletos sólo se rechazan las claves fuera de vigencia.
+func (v *validador) admite(nombre, clave string) bool {
+	c, ok := catalogos.Obtener(nombre)
+	return !ok || c.Admite(clave, v.fecha)
+}
+
+// catalogoCompleto Indica si el catálogo nombre contiene todas las claves del SAT.
+func catalogoCompleto(nombre string) bool {
+	c, ok := catalogos.Obtener(nombre)
+	return ok && c.Completo
+}
+
+// esIngresoEgresoNomina Indica si el comprobante es de tipo I, E o N.
+func (v *validador) esIngresoEgresoNomina() bool {
+	t := v.c.TipoDeComprobante
+	return t == "I" || t == "E" || t == "N"
+}
+
+// esTrasladoPago Indica si el comprobante es de tipo T o P.
+func (v *validador) esTrasladoPago() bool {
+	return v.c.TipoDeComprobante == "T" || v.c.TipoDeComprobante == "P"
+}
+
+// comprobante Revisa los atributos del nodo raíz.
+func (v *validador) comprobante() {
+	const raiz = "/cfdi:Comprobante"
+	c := v.c
+	if !patronFecha.MatchString(c.Fecha) {
+		v.agregar("CFDI33101", raiz+"/@Fecha")
+	}
+	if c.FormaPago != "" {
+		switch {
+		case c.TipoDeComprobante == "P":
+			v.agregar("CFDI33103", raiz+"/@FormaPago")
+		case !v.admite(catalogos.FormaPago, c.FormaPago):
+			v.agregar("CFDI33104", raiz+"/@FormaPago")
+		case c.MetodoPago == "PPD" && c.FormaPago != "99":
+			v.agregar("XMLS002", raiz+"/@FormaPago")
+		}
+	}
+	if c.SubTotal.Escala > v.decimales {
+		v.agregar("CFDI33106", raiz+"/@SubTotal")
+	}
+	if v.esIngresoEgresoNomina() {
+		suma := NewDecimal(0, 0)
+		for _, concepto := range c.Conceptos.Conceptos {
+			suma = suma.Add(concepto.Importe)
+		}
+		if suma.Round(v.decimales).Cmp(c.SubTotal) != 0 {
+			v.agregar("CFDI33107", raiz+"/@SubTotal")
+		}
+	}
+	if v.esTrasladoPago() && !c.SubTotal.IsZero() {
+		v.agregar("CFDI33108", raiz+"/@SubTotal")
+	}
+	descuento := NewDecimal(0, 0)
+	if c.Descuento != nil {
+		descuento = *c.Descuento
+		if descuento.Cmp(c.SubTotal) > 0 {
+			v.agregar("CFDI33109", raiz+"/@Descuento")
+		}
+		if descuento.Escala > v.decimales {
+			v.agregar("CFDI33110", raiz+"/@Descuento")
+		}
+	}
+	sumaDescuentos, hayDescuentos := NewDecimal(0, 0), false
+	for _, concepto := range c.Conceptos.Conceptos {
+		if concepto.Descuento != nil {
+			sumaDescuentos = sumaDescuentos.Add(*concepto.Descuento)
+			hayDescuentos = true
+		}
+	}
+	if hayDescuentos && (c.Descuento == nil || sumaDescuentos.Round(v.decimales).Cmp(descuento) != 0) {
+		v.agregar("CFDI33111", raiz+"/@Descuento")
+	}
+	if len(c.Moneda) != 3 || !v.admite(catalogos.Moneda, c.Moneda) {
+		v.agregar("CFDI33112", raiz+"/@Moneda")
+	}
+	switch {
+	case c.Moneda == "MXN" && c.TipoCambio != nil && c.TipoCambio.Cmp(NewDecimal(1, 0)) != 0:
+		v.agregar("CFDI33113", raiz+"/@TipoCambio")
+	case c.Moneda == "XXX" && c.TipoCambio != nil:
+		v.agregar("CFDI33115", raiz+"/@TipoCambio")
+	case c.Moneda != "MXN" && c.Moneda != "XXX" && c.TipoCambio == nil:
+		v.agregar("CFDI33114", raiz+"/@TipoCambio")
+	}
+	if c.TipoCambio != nil && (c.TipoCambio.Escala > DecimalesTipoCambio || c.TipoCambio.Sign() <= 0) {
+		v.agregar("CFDI33116", raiz+"/@TipoCambio")
+	}
+	if c.Total.Escala > v.decimales {
+		v.agregar("CFDI33117", raiz+"/@Total")
+	}
+	total := c.SubTotal.Sub(descuento)
+	if c.Impuestos != nil {
+		if c.Impuestos.TotalImpuestosTrasladados != nil {
+			total = total.Add(*c.Impuestos.TotalImpuestosTrasladados)
+		}
+		if c.Impuestos.TotalImpuestosRetenidos != nil {
+			total = total.Sub(*c.Impuestos.TotalImpuestosRetenidos)
+		}
+	}
+	if total.Round(v.decimales).Cmp(c.Total) != 0 {
+		v.agregar("CFDI33118", raiz+"/@Total")
+	}
+	if !v.admite(catalogos.TipoDeComprobante, c.TipoDeComprobante) {
+		v.agregar("CFDI33120", raiz+"/@TipoDeComprobante")
+	}
+	if c.MetodoPago != "" {
+		switch {
+		case v.esTrasladoPago():
+			v.agregar("CFDI33123", raiz+"/@MetodoPago")
+		case !v.admite(catalogos.MetodoPago, c.MetodoPago):
+			v.agregar("CFDI33121", raiz+"/@MetodoPago")
+		}
+	}
+	if !v.admite(catalogos.CodigoPostal, c.LugarExpedicion) {
+		v.agregar("CFDI33125", raiz+"/@LugarExpedicion")
+	}
+	if c.Relacionados != nil && !v.admite(catalogos.TipoRelacion, c.Relacionados.TipoRelacion) {
+		v.agregar("CFDI33129", raiz+"/cfdi:CfdiRelacionados/@TipoRelacion")
+	}
+}
+
+// emisorReceptor Revisa las claves de catálogo del emisor y del receptor.
+func (v *validador) emisorReceptor() {
+	emisor, receptor := v.c.Emisor, v.c.Receptor
+	switch {
+	case !v.admite(catalogos.RegimenFiscal, emisor.RegimenFiscal):
+		v.agregar("CFDI33130", "/cfdi:Comprobante/cfdi:Emisor/@RegimenFiscal")
+	case catalogoCompleto(catalogos.RegimenFiscal) && !catalogos.RegimenFiscalPermitido(emisor.RegimenFiscal, emisor.RFC):
+		v.agregar("CFDI33131", "/cfdi:Comprobante/cfdi:Emisor/@RegimenFiscal")
+	}
+	if receptor.ResidenciaFiscal != "" && !v.admite(catalogos.Pais, receptor.ResidenciaFiscal) {
+		v.agregar("CFDI33133", "/cfdi:Comprobante/cfdi:Receptor/@ResidenciaFiscal")
+	}
+	switch {
+	case !v.admite(catalogos.UsoCFDI, receptor.UsoCFDI):
+		v.agregar("CFDI33140", "/cfdi:Comprobante/cfdi:Receptor/@UsoCFDI")
+	case catalogoCompleto(catalogos.UsoCFDI) && !catalogos.UsoCFDIPermitido(receptor.UsoCFDI, receptor.RFC):
+		v.agregar("CFDI33141", "/cfdi:Comprobante/cfdi:Receptor/@UsoCFDI")
+	}
+}
+
+// limiteEnteros Menor valor con 19 dígitos enteros, que ya no cumple tdCFDI:t_Importe.
+const limiteEnteros = 1000000000000000000
+
+// revisarEsquema Registra XMLS001 en xpath si d tiene más de seis decimales o más de 18 dígitos enteros, los límites de tdCFDI:t_Importe y de los demás decimales del esquema.
+func (v *validador) revisarEsquema(d Decimal, xpath string) {
+	limite := NewDecimal(limiteEnteros, 0)
+	if d.Escala > DecimalesImporte || d.Cmp(limite) >= 0 || d.Neg().Cmp(limite) >= 0 {
+		v.agregar("XMLS001", xpath)
+	}
+}
+
+// revisarEsquemaOpcional Igual que revisarEsquema para atributos opcionales; nil no se revisa.
+func (v *validador) revisarEsquemaOpcional(d *Decimal, xpath string) {
+	if d != nil {
+		v.revisarEsquema(*d, xpath)
+	}
+}
+
+// conceptos Revisa los importes y los impuestos de cada concepto.
+func (v *validador) conceptos() {
+	for i, concepto := range v.c.Conceptos.Conceptos {
+		ruta := fmt.Sprintf("/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[%d]", i+1)
+		v.revisarEsquema(concepto.Cantidad, ruta+"/@Cantidad")
+		v.revisarEsquema(concepto.ValorUnitario, ruta+"/@ValorUnitario")
+		v.revisarEsquema(concepto.Importe, ruta+"/@Importe")
+		v.revisarEsquemaOpcional(concepto.Descuento, ruta+"/@Descuento")
+		for j, parte := range concepto.Parte {
+			rutaParte := fmt.Sprintf("%s/cfdi:Parte[%d]", ruta, j+1)
+			v.revisarEsquema(parte.Cantidad, rutaParte+"/@Cantidad")
+			v.revisarEsquemaOpcional(parte.ValorUnitario, rutaParte+"/@ValorUnitario")
+			v.revisarEsquemaOpcional(parte.Importe, rutaParte+"/@Importe")
+		}
+		if !v.admite(catalogos.ClaveProdServ, concepto.ClaveProdServ) {
+			v.agregar("CFDI33142", ruta+"/@ClaveProdServ")
+		}
+		if !v.admite(catalogos.ClaveUnidad, concepto.ClaveUnidad) {
+			v.agregar("CFDI33145", ruta+"/@ClaveUnidad")
+		}
+		if v.esIngresoEgresoNomina() && concepto.ValorUnitario.Sign() <= 0 {
+			v.agregar("CFDI33147", ruta+"/@ValorUnitario")
+		}
+		inferior, superior := LimitesImporte(concepto.Cantidad, concepto.ValorUnitario, concepto.Importe.Escala)
+		if concepto.Importe.Cmp(inferior) < 0 || concepto.Importe.Cmp(superior) > 0 {
+			v.agregar("CFDI33149", ruta+"/@Importe")
+		}
+		if concepto.Descuento != nil {
+			if concepto.Descuento.Escala > concepto.Importe.Escala {
+				v.agregar("CFDI33150", ruta+"/@Descuento")
+			}
+			if concepto.Descuento.Cmp(concepto.Importe) > 0 {
+				v.agregar("CFDI33151", ruta+"/@Descuento")
+			}
+		}
+		if concepto.Impuestos == nil {
+			continue
+		}
+		traslados, retenciones := concepto.Impuestos.Traslados, concepto.Impuestos.Retenciones
+		if (traslados == nil || len(traslados.Traslados) == 0) && (retenciones == nil || len(retenciones.Retenciones) == 0) {
+			v.agregar("CFDI33152", ruta+"/cfdi:Impuestos")
+			continue
+		}
+		if traslados != nil {
+			for j, t := range traslados.Traslados {
+				rutaTraslado := fmt.Sprintf("%s/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[%d]", ruta, j+1)
+				v.revisarEsquema(t.Base, rutaTraslado+"/@Base")
+				v.revisarEsquemaOpcional(t.TasaOCuota, rutaTraslado+"/@TasaOCuota")
+				v.revisarEsquemaOpcional(t.Importe, rutaTraslado+"/@Importe")
+				if t.Base.Sign() <= 0 {
+					v.agregar("CFDI33154", rutaTraslado+"/@Base")
+				}
+				if t.TipoFactor == TipoFactorExento {
+					if t.TasaOCuota != nil || t.Importe != nil {
+						v.agregar("CFDI33157", rutaTraslado)
+					}
+					continue
+				}
+				if t.TasaOCuota == nil || t.Importe == nil {
+					v.agregar("CFDI33158", rutaTraslado)
+					continue
+				}
+				if catalogoCompleto(catalogos.TasaOCuota) && !catalogos.TasaOCuotaValida(t.Impuesto, t.TipoFactor, true, t.TasaOCuota.String(), v.fecha) {
+					v.agregar("CFDI33159", rutaTraslado+"/@TasaOCuota")
+				}
+				inferior, superior := LimitesImpuesto(t.Base, *t.TasaOCuota, t.Importe.Escala)
+				if t.Importe.Cmp(inferior) < 0 || t.Importe.Cmp(superior) > 0 {
+					v.agregar("CFDI33161", rutaTraslado+"/@Importe")
+				}
+			}
+		}
+		if retenciones != nil {
+			for j, r := range retenciones.Retenciones {
+				rutaRetencion := fmt.Sprintf("%s/cfdi:Impuestos/cfdi:Retenciones/cfdi:Retencion[%d]", ruta, j+1)
+				v.revisarEsquema(r.Base, rutaRetencion+"/@Base")
+				v.revisarEsquema(r.TasaOCuota, rutaRetencion+"/@TasaOCuota")
+				v.revisarEsquema(r.Importe, rutaRetencion+"/@Importe")
+				if r.Base.Sign() <= 0 {
+					v.agregar("CFDI33163", rutaRetencion+"/@Base")
+				}
+				if r.TipoFactor == TipoFactorExento {
+					v.agregar("CFDI33166", rutaRetencion+"/@TipoFactor")
+					continue
+				}
+				inferior, superior := LimitesImpuesto(r.Base, r.TasaOCuota, r.Importe.Escala)
+				if r.Importe.Cmp(inferior) < 0 || r.Importe.Cmp(superior) > 0 {
+					v.agregar("CFDI33167", rutaRetencion+"/@Importe")
+				}
+			}
+		}
+	}
+}
+
+// impuestos Revisa el nodo Impuestos del comprobante contra los impuestos de los conceptos en ambos sentidos: cada Retención y Traslado del comprobante debe aparecer una sola vez y sumar los de los conceptos, y cada impuesto de los conceptos debe estar agrupado en el comprobante.
+func (v *validador) impuestos() {
+	const ruta = "/cfdi:Comprobante/cfdi:Impuestos"
+	impuestos := v.c.Impuestos
+	if v.esTrasladoPago() {
+		if impuestos != nil {
+			v.agregar("CFDI33179", ruta)
+		}
+		return
+	}
+	var traslados []CFDITraslado
+	var retenciones []CFDIRetencion
+	var rutasTraslados, rutasRetenciones []string // Ruta del primer impuesto de los conceptos que forma cada grupo.
+	for i, concepto := range v.c.Conceptos.Conceptos {
+		if concepto.Impuestos == nil {
+			continue
+		}
+		rutaConcepto := fmt.Sprintf("/cfdi:Comprobante/cfdi:Conceptos/cfdi:Concepto[%d]/cfdi:Impuestos", i+1)
+		if concepto.Impuestos.Traslados != nil {
+			for j, t := range concepto.Impuestos.Traslados.Traslados {
+				if t.TasaOCuota == nil || t.Importe == nil {
+					continue
+				}
+				grupos := len(traslados)
+				traslados = acumularTraslado(traslados, CFDITraslado{Impuesto: t.Impuesto, TipoFactor: t.TipoFactor, TasaOCuota: *t.TasaOCuota, Importe: *t.Importe})
+				if len(traslados) > grupos {
+					rutasTraslados = append(rutasTraslados, fmt.Sprintf("%s/cfdi:Traslados/cfdi:Traslado[%d]", rutaConcepto, j+1))
+				}
+			}
+		}
+		if concepto.Impuestos.Retenciones != nil {
+			for j, r := range concepto.Impuestos.Retenciones.Retenciones {
+				grupos := len(retenciones)
+				retenciones = acumularRetencion(retenciones, CFDIRetencion{Impuesto: r.Impuesto, Importe: r.Importe})
+				if len(retenciones) > grupos {
+					rutasRetenciones = append(rutasRetenciones, fmt.Sprintf("%s/cfdi:Retenciones/cfdi:Retencion[%d]", rutaConcepto, j+1))
+				}
+			}
+		}
+	}
+	if impuestos == nil {
+		if len(traslados) > 0 || len(retenciones) > 0 {
+			v.agregar("XMLS006", ruta)
+		}
+		return
+	}
+
+	var agrupadas []CFDIRetencion
+	if impuestos.Retenciones != nil {
+		agrupadas = impuestos.Retenciones.Retenciones
+	}
+	suma := NewDecimal(0, 0)
+	for i, r := range agrupadas {
+		rutaRetencion := fmt.Sprintf("%s/cfdi:Retenciones/cfdi:Retencion[%d]", ruta, i+1)
+		suma = suma.Add(r.Importe)
+		if r.Importe.Escala > v.decimales {
+			v.agregar("CFDI33190", rutaRetencion+"/@Importe")
+		}
+		if _, repetida := buscarRetencion(agrupadas[:i], r); repetida {
+			v.agregar("XMLS008", rutaRetencion)
+			continue
+		}
+		esperado, ok := buscarRetencion(retenciones, r)
+		switch {
+		case !ok:
+			v.agregar("CFDI33185", rutaRetencion+"/@Impuesto")
+		case esperado.Round(v.decimales).Cmp(r.Importe) != 0:
+			v.agregar("CFDI33186", rutaRetencion+"/@Importe")
+		}
+	}
+	for i, r := range retenciones {
+		if _, ok := buscarRetencion(agrupadas, r); !ok {
+			v.agregar("XMLS007", rutasRetenciones[i])
+		}
+	}
+	v.revisarTotalImpuestos(impuestos.TotalImpuestosRetenidos, suma, len(agrupadas) > 0, "CFDI33180", ruta+"/@TotalImpuestosRetenidos")
+
+	var agrupados []CFDITraslado
+	if impuestos.Traslados != nil {
+		agrupados = impuestos.Traslados.Traslados
+	}
+	suma = NewDecimal(0, 0)
+	for i, t := range agrupados {
+		rutaTraslado := fmt.Sprintf("%s/cfdi:Traslados/cfdi:Traslado[%d]", ruta, i+1)
+		suma = suma.Add(t.Importe)
+		if t.Importe.Escala > v.decimales {
+			v.agregar("CFDI33190", rutaTraslado+"/@Importe")
+		}
+		if _, repetido := buscarTraslado(agrupados[:i], t); repetido {
+			v.agregar("XMLS008", rutaTraslado)
+			continue
+		}
+		esperado, ok := buscarTraslado(traslados, t)
+		switch {
+		case !ok:
+			v.agregar("CFDI33187", rutaTraslado)
+		case esperado.Round(v.decimales).Cmp(t.Importe) != 0:
+			v.agregar("CFDI33189", rutaTraslado+"/@Importe")
+		}
+	}
+	for i, t := range traslados {
+		if _, ok := buscarTraslado(agrupados, t); !ok {
+			v.agregar("XMLS007", rutasTraslados[i])
+		}
+	}
+	v.revisarTotalImpuestos(impuestos.TotalImpuestosTrasladados, suma, len(agrupados) > 0, "CFDI33182", ruta+"/@TotalImpuestosTrasladados")
+}
+
+// revisarTotalImpuestos Registra codigo en xpath si total no es igual a suma, o si falta y el nodo tiene hijos; y XMLS009 si total tiene más decimales que la moneda.
+func (v *validador) revisarTotalImpuestos(total *Decimal, suma Decimal, hayHijos bool, codigo, xpath string) {
+	if total == nil {
+		if hayHijos {
+			v.agregar(codigo, xpath)
+		}
+		return
+	}
+	if total.Escala > v.decimales {
+		v.agregar("XMLS009", xpath)
+	}
+	if suma.Cmp(*total) != 0 {
+		v.agregar(codigo, xpath)
+	}
+}
+
+// buscarTraslado Devuelve el importe acumulado en lista para la combinación de Impuesto, TipoFactor y TasaOCuota de t.
+func buscarTraslado(lista []CFDITraslado, t CFDITraslado) (Decimal, bool) {
+	for _, l := range lista {
+		if l.Impuesto == t.Impuesto && l.TipoFactor == t.TipoFactor && l.TasaOCuota.Cmp(t.TasaOCuota) == 0 {
+			return l.Importe, true
+		}
+	}
+	return Decimal{}, false
+}
+
+// buscarRetencion Devuelve el importe acumulado en lista para el Impuesto de r.
+func buscarRetencion(lista []CFDIRetencion, r CFDIRetencion) (Decimal, bool) {
+	for _, l := range lista {
+		if l.Impuesto == r.Impuesto {
+			return l.Importe, true
+		}
+	}
+	return Decimal{}, false
+}
+
+// pagos Revisa los datos fijos del comprobante de tipo P y su complemento para recepción de pagos según la matriz de errores del complemento. Las reglas que ya revisan las claves CFDI33, como SubTotal, FormaPago, MetodoPago e Impuestos del comprobante, no se repiten.
+func (v *validador) pagos() {
+	const raiz = "/cfdi:Comprobante"
+	c := v.c
+	pagos := c.Pagos()
+	if c.TipoDeComprobante != "P" {
+		if pagos != nil {
+			v.agregar("CRP101", raiz+"/@TipoDeComprobante")
+		}
+		return
+	}
+	if pagos == nil {
+		v.agregar("XMLS003", raiz+"/cfdi:Complemento")
+	}
+	if c.Moneda != MonedaPago {
+		v.agregar("CRP103", raiz+"/@Moneda")
+	}
+	if c.TipoCambio != nil {
+		v.agregar("CRP108", raiz+"/@TipoCambio")
+	}
+	if c.CondicionesDePago != "" {
+		v.agregar("CRP106", raiz+"/@CondicionesDePago")
+	}
+	if c.Descuento != nil {
+		v.agregar("CRP107", raiz+"/@Descuento")
+	}
+	if !c.Total.IsZero() {
+		v.agregar("CRP109", raiz+"/@Total")
+	}
+	if c.Receptor.UsoCFDI != UsoCFDIPago {
+		v.agregar("CRP110", raiz+"/cfdi:Receptor/@UsoCFDI")
+	}
+	if len(c.Conceptos.Conceptos) != 1 {
+		v.agregar("CRP120", raiz+"/cfdi:Conceptos")
+	}
+	for i, concepto := range c.Conceptos.Conceptos {
+		ruta := fmt.Sprintf("%s/cfdi:Conceptos/cfdi:Concepto[%d]", raiz, i+1)
+		if concepto.ClaveProdServ != ClaveProdServPago {
+			v.agregar("CRP111", ruta+"/@ClaveProdServ")
+		}
+		if concepto.NoIdentificacion != "" {
+			v.agregar("CRP112", ruta+"/@NoIdentificacion")
+		}
+		if concepto.Cantidad.Cmp(NewDecimal(1, 0)) != 0 {
+			v.agregar("CRP113", ruta+"/@Cantidad")
+		}
+		if concepto.ClaveUnidad != ClaveUnidadPago {
+			v.agregar("CRP114", ruta+"/@ClaveUnidad")
+		}
+		if concepto.Unidad != "" {
+			v.agregar("CRP115", ruta+"/@Unidad")
+		}
+		if concepto.Descripcion != DescripcionPago {
+			v.agregar("CRP116", ruta+"/@Descripcion")
+		}
+		if !concepto.ValorUnitario.IsZero() {
+			v.agregar("CRP117", ruta+"/@ValorUnitario")
+		}
+		if !concepto.Importe.IsZero() {
+			v.agregar("CRP118", ruta+"/@Importe")
+		}
+		if concepto.Descuento != nil {
+			v.agregar("CRP119", ruta+"/@Descuento")
+		}
+		if concepto.Impuestos != nil || len(concepto.InformacionAduanera) > 0 || concepto.CuentaPredial != nil || concepto.ComplementoConcepto != nil || len(concepto.Parte) > 0 {
+			v.agregar("CRP120", ruta)
+		}
+	}
+	if pagos == nil {
+		return
+	}
+	for i, pago := range pagos.Pago {
+		v.pago(pago, fmt.Sprintf("%s/cfdi:Complemento/pago10:Pagos/pago10:Pago[%d]", raiz, i+1))
+	}
+}
+
+// pago Revisa un nodo pago10:Pago y sus documentos relacionados.
+func (v *validador) pago(pago Pago, ruta string) {
+	switch {
+	case !v.admite(catalogos.FormaPago, pago.FormaDePagoP):
+		v.agregar("XMLS004", ruta+"/@FormaDePagoP")
+	case pago.FormaDePagoP == "99":
+		v.agregar("CRP201", ruta+"/@FormaDePagoP")
+	}
+	switch {
+	case pago.MonedaP == MonedaPago:
+		v.agregar("CRP202", ruta+"/@MonedaP")
+	case len(pago.MonedaP) != 3 || !v.admite(catalogos.Moneda, pago.MonedaP):
+		v.agregar("XMLS005", ruta+"/@MonedaP")
+	}
+	switch {
+	case pago.MonedaP != "MXN" && pago.TipoCambioP == nil:
+		v.agregar("CRP203", ruta+"/@TipoCambioP")
+	case pago.MonedaP == "MXN" && pago.TipoCambioP != nil:
+		v.agregar("CRP204", ruta+"/@TipoCambioP")
+	}
+	decimalesP := DecimalesMoneda(pago.MonedaP)
+	if pago.Monto.Sign() <= 0 {
+		v.agregar("CRP207", ruta+"/@Monto")
+	}
+	if pago.Monto.Escala > decimalesP {
+		v.agregar("CRP208", ruta+"/@Monto")
+	}
+	switch {
+	case pago.TipoCadPago != "":
+		if pago.CertPago == "" {
+			v.agregar("CRP227", ruta+"/@CertPago")
+		}
+		if pago.CadPago == "" {
+			v.agregar("CRP229", ruta+"/@CadPago")
+		}
+		if pago.SelloPago == "" {
+			v.agregar("CRP231", ruta+"/@SelloPago")
+		}
+	default:
+		if pago.CertPago != "" {
+			v.agregar("CRP228", ruta+"/@CertPago")
+		}
+		if pago.CadPago != "" {
+			v.agregar("CRP230", ruta+"/@CadPago")
+		}
+		if pago.SelloPago != "" {
+			v.agregar("CRP232", ruta+"/@SelloPago")
+		}
+	}
+
+	suma := NewDecimal(0, 0)
+	for j, d := range pago.DoctoRelacionado {
+		rutaDocto := fmt.Sprintf("%s/pago10:DoctoRelacionado[%d]", ruta, j+1)
+		if d.MonedaDR == MonedaPago {
+			v.agregar("CRP217", rutaDocto+"/@MonedaDR")
+		}
+		switch {
+		case d.MonedaDR != pago.MonedaP && d.TipoCambioDR == nil:
+			v.agregar("CRP218", rutaDocto+"/@TipoCambioDR")
+		case d.MonedaDR == pago.MonedaP && d.TipoCambioDR != nil:
+			v.agregar("CRP219", rutaDocto+"/@TipoCambioDR")
+		case d.MonedaDR == "MXN" && pago.MonedaP != "MXN" && d.TipoCambioDR.Cmp(NewDecimal(1, 0)) != 0:
+			v.agregar("CRP220", rutaDocto+"/@TipoCambioDR")
+		}
+		if d.MetodoDePagoDR == "PPD" {
+			if d.NumParcialidad <= 0 {
+				v.agregar("CRP233", rutaDocto+"/@NumParcialidad")
+			}
+			if d.ImpSaldoAnt == nil {
+				v.agregar("CRP234", rutaDocto+"/@ImpSaldoAnt")
+			}
+			if d.ImpSaldoInsoluto == nil {
+				v.agregar("CRP236", rutaDocto+"/@ImpSaldoInsoluto")
+			}
+		}
+		decimalesDR := DecimalesMoneda(d.MonedaDR)
+		if d.ImpSaldoAnt != nil && (d.ImpSaldoAnt.Sign() <= 0 || d.ImpSaldoAnt.Escala > decimalesDR) {
+			v.agregar("CRP221", rutaDocto+"/@ImpSaldoAnt")
+		}
+		// Con un solo documento en la misma moneda ImpPagado puede omitirse y equivale al Monto.
+		pagado, conocido := pago.Monto, true
+		switch {
+		case d.ImpPagado != nil:
+			pagado = *d.ImpPagado
+			if pagado.Sign() <= 0 || pagado.Escala > decimalesDR {
+				v.agregar("CRP223", rutaDocto+"/@ImpPagado")
+			}
+		case len(pago.DoctoRelacionado) > 1 || d.TipoCambioDR != nil:
+			v.agregar("CRP235", rutaDocto+"/@ImpPagado")
+			conocido = false
+		}
+		if d.ImpSaldoInsoluto != nil {
+			insoluto := *d.ImpSaldoInsoluto
+			if insoluto.Sign() < 0 || insoluto.Escala > decimalesDR || (conocido && d.ImpSaldoAnt != nil && d.ImpSaldoAnt.Sub(pagado).Cmp(insoluto) != 0) {
+				v.agregar("CRP225", rutaDocto+"/@ImpSaldoInsoluto")
+			}
+		}
+		if !conocido {
+			continue
+		}
+		if d.TipoCambioDR != nil && d.TipoCambioDR.Sign() > 0 {
+			pagado, _ = pagado.Quo(*d.TipoCambioDR, DecimalesImporte)
+		}
+		suma = suma.Add(pagado)
+	}
+	if suma.Round(decimalesP).Cmp(pago.Monto) > 0 {
+		v.agregar("CRP206", ruta+"/@Monto")
+	}
+	if len(pago.Impuestos) > 0 {
+		v.agregar("CRP237", ruta+"/pago10:Impuestos")
+	}
+}
@@ -0,0 +1,126 @@
+package xmlstructures
+
+import (
+	"strings"
+	"testing"
+)
+
+// codigos Devuelve el conjunto de pares "código xpath" reportados por Validate.
+func codigos(errores []ErrorValidacion) map[string]bool {
+	m := map[string]bool{}
+	for _, e := range errores {
+		m[e.Codigo+" "+e.XPath] = true
+	}
+	return m
+}
+
+// codigosImpuestos Códigos del validador de impuestos del comprobante; ningún caso debe reportar uno que no espere.
+var codigosImpuestos = []string{"CFDI33179", "CFDI33180", "CFDI33182", "CFDI33185", "CFDI33186", "CFDI33187", "CFDI33189", "CFDI33190", "XMLS006", "XMLS007", "XMLS008", "XMLS009"}
+
+func TestValidate(t *testing.T) {
+	if errores := Validate(comprobantePrueba(2)); errores != nil {
+		t.Fatalf("el comprobante de prueba reportó %v", errores)
+	}
+	const raiz = "/cfdi:Comprobante"
+	const rutaImpuestos = raiz + "/cfdi:Impuestos"
+	const rutaConcepto = raiz + "/cfdi:Conceptos/cfdi:Concepto"
+	centavo := dec("0.01")
+	casos := []struct {
+		nombre   string
+		cambio   func(c *Comprobante)
+		esperado []string
+	}{
+		{"PPD con FormaPago 99", func(c *Comprobante) { c.MetodoPago, c.FormaPago = "PPD", "99" }, nil},
+		{"PPD con FormaPago 01", func(c *Comprobante) { c.MetodoPago = "PPD" }, []string{"XMLS002 " + raiz + "/@FormaPago"}},
+		{"FormaPago fuera del catálogo", func(c *Comprobante) { c.FormaPago = "ZZ" }, []string{"CFDI33104 " + raiz + "/@FormaPago"}},
+		{"Moneda fuera del catálogo", func(c *Comprobante) { c.Moneda = "MXP" }, []string{"CFDI33112 " + raiz + "/@Moneda"}},
+		{"ResidenciaFiscal fuera del catálogo", func(c *Comprobante) { c.Receptor.ResidenciaFiscal = "XYZ" }, []string{"CFDI33133 " + raiz + "/cfdi:Receptor/@ResidenciaFiscal"}},
+		{"TasaOCuota fuera del catálogo", func(c *Comprobante) {
+			c.Conceptos.Conceptos[0].Impuestos.Traslados.Traslados[0].TasaOCuota = pdec("0.150000")
+			if err := c.CalcularTotales(); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"CFDI33159 " + rutaConcepto + "[1]/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[1]/@TasaOCuota"}},
+		{"traslado con Impuestos", func(c *Comprobante) { c.TipoDeComprobante = "T" }, []string{"CFDI33179 " + rutaImpuestos}},
+		{"sin Impuestos", func(c *Comprobante) { c.Impuestos = nil }, []string{"XMLS006 " + rutaImpuestos}},
+		{"traslado de conceptos sin agrupar", func(c *Comprobante) {
+			c.Impuestos.Traslados, c.Impuestos.TotalImpuestosTrasladados = nil, nil
+		}, []string{"XMLS007 " + rutaConcepto + "[1]/cfdi:Impuestos/cfdi:Traslados/cfdi:Traslado[1]"}},
+		{"retención de conceptos sin agrupar", func(c *Comprobante) {
+			c.Impuestos.Retenciones, c.Impuestos.TotalImpuestosRetenidos = nil, nil
+		}, []string{"XMLS007 " + rutaConcepto + "[2]/cfdi:Impuestos/cfdi:Retenciones/cfdi:Retencion[1]"}},
+		{"traslado repetido", func(c *Comprobante) {
+			t := c.Impuestos.Traslados.Traslados[0]
+			t.Importe = dec("0.00")
+			c.Impuestos.Traslados.Traslados = append(c.Impuestos.Traslados.Traslados, t)
+		}, []string{"XMLS008 " + rutaImpuestos + "/cfdi:Traslados/cfdi:Traslado[2]"}},
+		{"retención repetida", func(c *Comprobante) {
+			r := c.Impuestos.Retenciones.Retenciones[0]
+			r.Importe = dec("0.00")
+			c.Impuestos.Retenciones.Retenciones = append(c.Impuestos.Retenciones.Retenciones, r)
+		}, []string{"XMLS008 " + rutaImpuestos + "/cfdi:Retenciones/cfdi:Retencion[2]"}},
+		{"traslado sin conceptos", func(c *Comprobante) {
+			c.Impuestos.Traslados.Traslados = append(c.Impuestos.Traslados.Traslados, CFDITraslado{Impuesto: "003", TipoFactor: "Tasa", TasaOCuota: dec("0.080000"), Importe: dec("0.00")})
+		}, []string{"CFDI33187 " + rutaImpuestos + "/cfdi:Traslados/cfdi:Traslado[2]"}},
+		{"retención sin conceptos", func(c *Comprobante) {
+			c.Impuestos.Retenciones.Retenciones = append(c.Impuestos.Retenciones.Retenciones, CFDIRetencion{Impuesto: "002", Importe: dec("0.00")})
+		}, []string{"CFDI33185 " + rutaImpuestos + "/cfdi:Retenciones/cfdi:Retencion[2]/@Impuesto"}},
+		{"Importe de traslado", func(c *Comprobante) {
+			t := &c.Impuestos.Traslados.Traslados[0]
+			t.Importe = t.Importe.Add(centavo)
+			total := c.Impuestos.TotalImpuestosTrasladados.Add(centavo)
+			c.Impuestos.TotalImpuestosTrasladados = &total
+		}, []string{"CFDI33189 " + rutaImpuestos + "/cfdi:Traslados/cfdi:Traslado[1]/@Importe"}},
+		{"Importe de retención", func(c *Comprobante) {
+			r := &c.Impuestos.Retenciones.Retenciones[0]
+			r.Importe = r.Importe.Add(centavo)
+			total := c.Impuestos.TotalImpuestosRetenidos.Add(centavo)
+			c.Impuestos.TotalImpuestosRetenidos = &total
+		}, []string{"CFDI33186 " + rutaImpuestos + "/cfdi:Retenciones/cfdi:Retencion[1]/@Importe"}},
+		{"decimales del Importe", func(c *Comprobante) {
+			t := &c.Impuestos.Traslados.Traslados[0]
+			t.Importe = dec(t.Importe.String() + "0")
+		}, []string{"CFDI33190 " + rutaImpuestos + "/cfdi:Traslados/cfdi:Traslado[1]/@Importe"}},
+		{"TotalImpuestosTrasladados distinto", func(c *Comprobante) {
+			total := c.Impuestos.TotalImpuestosTrasladados.Add(centavo)
+			c.Impuestos.TotalImpuestosTrasladados = &total
+		}, []string{"CFDI33182 " + rutaImpuestos + "/@TotalImpuestosTrasladados"}},
+		{"sin TotalImpuestosTrasladados", func(c *Comprobante) { c.Impuestos.TotalImpuestosTrasladados = nil }, []string{"CFDI33182 " + rutaImpuestos + "/@TotalImpuestosTrasladados"}},
+		{"TotalImpuestosRetenidos distinto", func(c *Comprobante) {
+			total := c.Impuestos.TotalImpuestosRetenidos.Sub(centavo)
+			c.Impuestos.TotalImpuestosRetenidos = &total
+		}, []string{"CFDI33180 " + rutaImpuestos + "/@TotalImpuestosRetenidos"}},
+		{"sin TotalImpuestosRetenidos", func(c *Comprobante) { c.Impuestos.TotalImpuestosRetenidos = nil }, []string{"CFDI33180 " + rutaImpuestos + "/@TotalImpuestosRetenidos"}},
+		{"decimales de TotalImpuestosRetenidos", func(c *Comprobante) {
+			c.Impuestos.TotalImpuestosRetenidos = pdec(c.Impuestos.TotalImpuestosRetenidos.String() + "0")
+		}, []string{"XMLS009 " + rutaImpuestos + "/@TotalImpuestosRetenidos"}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			c := comprobantePrueba(2)
+			caso.cambio(&c)
+			errores := Validate(c)
+			m := codigos(errores)
+			for _, esperado := range caso.esperado {
+				if !m[esperado] {
+					t.Errorf("no se reportó %s; se obtuvo %v", esperado, m)
+				}
+			}
+			if caso.esperado == nil && errores != nil {
+				t.Errorf("se reportó %v", errores)
+			}
+			for clave := range m {
+				for _, codigo := range append([]string{"XMLS002"}, codigosImpuestos...) {
+					if strings.HasPrefix(clave, codigo+" ") && !contiene(caso.esperado, clave) {
+						t.Errorf("se reportó %s sin corresponder", clave)
+					}
+				}
+			}
+			for _, e := range errores {
+				if e.Mensaje != mensajesValidacion[e.Codigo] || e.Mensaje == "" {
+					t.Errorf("mensaje de %s: %q", e.Codigo, e.Mensaje)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package xmlstructures
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"./Catalogos"
+)
+
+/****************************************************************************************************************************************
+*
+*
+* Verificación del sello y del certificado de comprobantes recibidos
+*
+*
+****************************************************************************************************************************************/
+
+// ErrSinCertificado Indica que el comprobante no incluye el certificado con el que fue sellado.
+var ErrSinCertificado = errors.New("el comprobante no incluye el certificado")
+
+// ErrorVerificacion Error que impide verificar un comprobante. Indica el nodo o atributo que no pudo interpretarse.
+type ErrorVerificacion struct {
+	Nodo string // Ruta del nodo o atributo, p.ej. /cfdi:Comprobante/@Certificado.
+	Err  error  // Error original.
+}
+
+func (e *ErrorVerificacion) Error() string {
+	return fmt.Sprintf("xmlstructures: %s: %v", e.Nodo, e.Err)
+}
+
+// Unwrap Devuelve el error original.
+func (e *ErrorVerificacion) Unwrap() error { return e.Err }
+
+// oidRFC Atributo x500UniqueIdentifier del sujeto, en el que el SAT registra el RFC del titular del certificado.
+var oidRFC = asn1.ObjectIdentifier{2, 5, 4, 45}
+
+// zonaCentro Zona horaria del centro de México, en la que se expresan la FechaTimbrado y, cuando el LugarExpedicion no tiene huso horario en c_CodigoPostal, la Fecha del comprobante. Incluye el horario de verano que rigió hasta 2022.
+var zonaCentro = func() *time.Location {
+	zona, err := time.LoadLocation("America/Mexico_City")
+	if err != nil {
+		return time.FixedZone("CST", -6*60*60)
+	}
+	return zona
+}()
+
+// Desfases extremos de las zonas horarias de México: Quintana Roo (UTC-5) y Baja California en invierno (UTC-8). Acotan la hora real de una Fecha cuyo LugarExpedicion no tiene huso horario.
+const (
+	desfaseMinimo = -5 * time.Hour
+	desfaseMaximo = -8 * time.Hour
+)
+
+// ReporteVerificacion Resultado de verificar la autenticidad de un comprobante recibido. Cada campo booleano corresponde a una revisión; Valido indica si se cumplieron todas.
+type ReporteVerificacion struct {
+	CadenaOriginal      string            // Cadena original calculada a partir del comprobante.
+	Certificado         *x509.Certificate // Certificado incluido en el atributo Certificado.
+	NoCertificado       string            // Número de certificado obtenido del número de serie; vacío si no tiene el formato del SAT.
+	RFC                 string            // RFC del titular del certificado.
+	SelloValido         bool              // El Sello es la firma RSA-SHA256 de la cadena original con la llave del certificado.
+	NoCertificadoValido bool              // El NoCertificado del comprobante es el número de serie del certificado.
+	EsCSD               bool              // El certificado es un Certificado de Sello Digital y no una e.firma (FIEL), que no puede usarse para sellar comprobantes.
+	VigenteEnFecha      bool              // La Fecha del comprobante está dentro del periodo de validez del certificado.
+	ZonaExpedicion      *time.Location    // Zona horaria del LugarExpedicion según c_CodigoPostal, con la que se interpretó la Fecha.
+	ZonaAproximada      bool              // El LugarExpedicion no tiene huso horario en c_CodigoPostal: ZonaExpedicion es la del centro y VigenteEnFecha se aceptó si la Fecha cae en la vigencia con alguno de los desfases de México, de UTC-5 a UTC-8.
+	RFCValido           bool              // El RFC del certificado es el Emisor.RFC del comprobante.
+}
+
+// Valido Indica si el comprobante pasó todas las revisiones.
+func (r *ReporteVerificacion) Valido() bool {
+	return r.SelloValido && r.NoCertificadoValido && r.EsCSD && r.VigenteEnFecha && r.RFCValido
+}
+
+// Problemas Devuelve una descripción de cada revisión que no se cumplió, en el orden de los campos del reporte.
+func (r *ReporteVerificacion) Problemas() []string {
+	var problemas []string
+	if !r.SelloValido {
+		problemas = append(problemas, "el sello no corresponde a la cadena original y al certificado")
+	}
+	if !r.NoCertificadoValido {
+		problemas = append(problemas, fmt.Sprintf("el NoCertificado no es el número de serie del certificado (%s)", r.NoCertificado))
+	}
+	if !r.EsCSD {
+		problemas = append(problemas, "el certificado no es un certificado de sello digital")
+	}
+	if !r.VigenteEnFecha {
+		problemas = append(problemas, fmt.Sprintf("la fecha de expedición está fuera de la vigencia del certificado (%s a %s)",
+			r.Certificado.NotBefore.In(r.ZonaExpedicion).Format("2006-01-02T15:04:05"), r.Certificado.NotAfter.In(r.ZonaExpedicion).Format("2006-01-02T15:04:05")))
+	}
+	if !r.RFCValido {
+		problemas = append(problemas, fmt.Sprintf("el RFC del certificado (%s) no es el del emisor", r.RFC))
+	}
+	return problemas
+}
+
+// VerificarSello Verifica la autenticidad de un comprobante leído con Unmarshal: recalcula su cadena original con los valores tal como vienen en el documento, verifica el Sello con el Certificado incluido y revisa que el certificado corresponda al NoCertificado, sea un CSD, estuviera vigente en la Fecha de expedición y pertenezca al emisor. La Fecha se interpreta en la zona horaria del LugarExpedicion según c_CodigoPostal; si el código postal no tiene huso horario se acepta con la tolerancia descrita en ReporteVerificacion.ZonaAproximada. No revisa la cadena de confianza del certificado ni su revocación.
+//
+// Devuelve un *ErrorVerificacion sólo si el certificado falta o no puede interpretarse, o si la cadena original no puede generarse; el resultado de las revisiones se informa en el reporte.
+func VerificarSello(c Comprobante) (*ReporteVerificacion, error) {
+	if strings.TrimSpace(c.Certificado) == "" {
+		return nil, &ErrorVerificacion{Nodo: "/cfdi:Comprobante/@Certificado", Err: ErrSinCertificado}
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(c.Certificado), ""))
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/cfdi:Comprobante/@Certificado", Err: err}
+	}
+	certificado, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, &ErrorVerificacion{Nodo: "/cfdi:Comprobante/@Certificado", Err: err}
+	}
+	cadena, err := cadenaOriginal(c)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReporteVerificacion{
+		CadenaOriginal: cadena,
+		Certificado:    certificado,
+		RFC:            RFCCertificado(certificado),
+		EsCSD:          EsCSD(certificado),
+	}
+	r.SelloValido = verificarFirma(certificado, []byte(cadena), c.Sello)
+	r.NoCertificado, _ = NumeroCertificado(certificado)
+	r.NoCertificadoValido = r.NoCertificado != "" && r.NoCertificado == c.NoCertificado
+	r.ZonaExpedicion, r.VigenteEnFecha, r.ZonaAproximada = vigenteEnExpedicion(certificado, c.Fecha, c.LugarExpedicion)
+	r.RFCValido = r.RFC != "" && strings.EqualFold(r.RFC, strings.TrimSpace(c.Emisor.RFC))
+	return r, nil
+}
+
+// vigenteEnExpedicion Devuelve la zona horaria de lugar e indica si fecha, expresada en esa zona, está dentro de la vigencia del certificado. Si lugar no tiene huso horario en c_CodigoPostal devuelve la zona del centro con aproximada en true, y la fecha es vigente si lo es con algún desfase entre UTC-5 y UTC-8.
+func vigenteEnExpedicion(certificado *x509.Certificate, fecha, lugar string) (zona *time.Location, vigente, aproximada bool) {
+	zona, ok := catalogos.ZonaHoraria(strings.TrimSpace(lugar))
+	if !ok {
+		zona, aproximada = zonaCentro, true
+	}
+	local, err := time.ParseInLocation("2006-01-02T15:04:05", fecha, time.UTC)
+	if err != nil {
+		return zona, false, aproximada
+	}
+	if aproximada {
+		// La hora real está entre local-desfaseMinimo y local-desfaseMaximo.
+		return zona, !local.Add(-desfaseMaximo).Before(certificado.NotBefore) && !local.Add(-desfaseMinimo).After(certificado.NotAfter), true
+	}
+	instante := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, zona)
+	return zona, !instante.Before(certificado.NotBefore) && !instante.After(certificado.NotAfter), false
+}
+
+// verificarFirma Indica si sello, en base 64, es la firma RSA-SHA256 de cadena con la llave pública de certificado.
+func verificarFirma(certificado *x509.Certificate, cadena []byte, sello string) bool {
+	publica, ok := certificado.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return false
+	}
+	firma, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sello), ""))
+	if err != nil {
+		return false
+	}
+	suma := sha256.Sum256(cadena)
+	return rsa.VerifyPKCS1v15(publica, crypto.SHA256, suma[:], firma) == nil
+}
+
+// RFCCertificado Devuelve el RFC del titular del certificado. En los certificados de personas morales el atributo contiene además el RFC del representante legal, separado por /, que se descarta.
+func RFCCertificado(certificado *x509.Certificate) string {
+	for _, atributo := range certificado.Subject.Names {
+		if !atributo.Type.Equal(oidRFC) {
+			continue
+		}
+		valor, ok := atributo.Value.(string)
+		if !ok {
+			return ""
+		}
+		if i := strings.Index(valor, "/"); i >= 0 {
+			valor = valor[:i]
+		}
+		return strings.TrimSpace(valor)
+	}
+	return ""
+}
+
+// EsCSD Indica si el certificado es un Certificado de Sello Digital. El SAT emite los CSD sólo con los usos firma digital y no repudio, mientras que la e.firma (FIEL) agrega cifrado de datos, acuerdo de llaves y usos extendidos de protección de correo y autenticación de cliente.
+func EsCSD(certificado *x509.Certificate) bool {
+	if certificado.KeyUsage&(x509.KeyUsageDataEncipherment|x509.KeyUsageKeyAgreement) != 0 || len(certificado.ExtKeyUsage) > 0 {
+		return false
+	}
+	return certificado.KeyUsage&x509.KeyUsageDigitalSignature != 0
+}
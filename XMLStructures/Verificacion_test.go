@@ -0,0 +1,76 @@
+package xmlstructures
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// firmadoPrueba Lee testdata/csd/firmado.xml: la nómina de testdata/nomina.xml sellada con el CSD de prueba, expedida en Tijuana (22000) el 2026-10-16 a las 21:00, ocho minutos después de que inicia la vigencia del certificado en la hora local (UTC-7). El sello se comprobó también con testdata/xslt y openssl dgst -verify. Cada cambio reemplaza un texto del documento antes de leerlo.
+func firmadoPrueba(t *testing.T, cambios ...string) Comprobante {
+	t.Helper()
+	datos, err := os.ReadFile(filepath.Join("testdata", "csd", "firmado.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	texto := string(datos)
+	for i := 0; i+1 < len(cambios); i += 2 {
+		if !strings.Contains(texto, cambios[i]) {
+			t.Fatalf("firmado.xml no contiene %s", cambios[i])
+		}
+		texto = strings.Replace(texto, cambios[i], cambios[i+1], 1)
+	}
+	var c Comprobante
+	if err := Unmarshal([]byte(texto), &c); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	return c
+}
+
+func TestVerificarSello(t *testing.T) {
+	r, err := VerificarSello(firmadoPrueba(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Valido() {
+		t.Errorf("el comprobante firmado no es válido: %v", r.Problemas())
+	}
+	if r.ZonaAproximada || r.ZonaExpedicion.String() != "America/Tijuana" {
+		t.Errorf("zona de expedición %v (aproximada %v), se esperaba America/Tijuana", r.ZonaExpedicion, r.ZonaAproximada)
+	}
+	if r.NoCertificado != noCertificadoCSD || r.RFC != "AAA010101AAA" {
+		t.Errorf("NoCertificado %s y RFC %s", r.NoCertificado, r.RFC)
+	}
+}
+
+func TestVerificarSelloComplementoAlterado(t *testing.T) {
+	r, err := VerificarSello(firmadoPrueba(t, `TotalGravado="8250.00"`, `TotalGravado="8250.01"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SelloValido {
+		t.Error("se aceptó el sello con un atributo del complemento de nómina alterado")
+	}
+}
+
+func TestVerificarSelloZonaExpedicion(t *testing.T) {
+	casos := []struct {
+		lugar      string
+		vigente    bool
+		aproximada bool
+	}{
+		{"22000", true, false},  // 21:00 en Tijuana son las 04:00 UTC.
+		{"01000", false, false}, // 21:00 en la Ciudad de México son las 03:00 UTC, antes de la vigencia.
+		{"99999", true, true},   // Sin huso horario: con UTC-8 serían las 05:00 UTC.
+	}
+	for _, caso := range casos {
+		r, err := VerificarSello(firmadoPrueba(t, `LugarExpedicion="22000"`, `LugarExpedicion="`+caso.lugar+`"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.VigenteEnFecha != caso.vigente || r.ZonaAproximada != caso.aproximada {
+			t.Errorf("LugarExpedicion %s: vigente %v y aproximada %v, se esperaba %v y %v", caso.lugar, r.VigenteEnFecha, r.ZonaAproximada, caso.vigente, caso.aproximada)
+		}
+	}
+}
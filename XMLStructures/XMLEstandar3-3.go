@@ -2,7 +2,18 @@ package xmlstructures
 
 import (
 	"encoding/xml"
-	"fmt"
+	"strings"
+)
+
+// Espacios de nombres y ubicaciones de esquema que declara un CFDI 3.3.
+const (
+	NamespaceCFDI = "http://www.sat.gob.mx/cfd/3"                                                             // Espacio de nombres del prefijo cfdi.
+	NamespaceXSI  = "http://www.w3.org/2001/XMLSchema-instance"                                               // Espacio de nombres del prefijo xsi.
+	NamespaceTFD  = "http://www.sat.gob.mx/TimbreFiscalDigital"                                               // Espacio de nombres del prefijo tfd.
+	EsquemaCFDI   = "http://www.sat.gob.mx/sitio_internet/cfd/3/cfdv33.xsd"                                   // Ubicación del esquema del comprobante 3.3.
+	EsquemaTFD    = "http://www.sat.gob.mx/sitio_internet/cfd/TimbreFiscalDigital/TimbreFiscalDigitalv11.xsd" // Ubicación del esquema del Timbre Fiscal Digital 1.1.
+	VersionCFDI   = "3.3"                                                                                     // Versión del estándar; Marshal la escribe cuando Version viene vacía.
+	VersionTFD    = "1.1"                                                                                     // Versión del Timbre Fiscal Digital que acompaña al CFDI 3.3.
 )
 
 /****************************************************************************************************************************************
@@ -15,31 +26,32 @@ import (
 
 // Comprobante Comprobante Estándar de Comprobante Fiscal Digital por Internet XML
 type Comprobante struct {
-	XMLName           xml.Name         `xml:"cfdi:Comprobante"`
-	Version           string           `xml:"Version,attr"`           // Atributo requerido con valor prefijado a 3.3 que indica la versión del estándar bajo el que se encuentra expresado el comprobante. Default: "3.3" Req.
-	Serie             string           `xml:"Serie,attr"`             // Atributo opcional para precisar la serie para control interno del contribuyente. Este atributo acepta una cadena de caracteres. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü| Ü){1,25} Opc.
-	Folio             string           `xml:"Folio,attr"`             // Atributo opcional para control interno del contribuyente que expresa el folio del comprobante, acepta una cadena de caracteres. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü |Ü){1,40} Opc.
-	Fecha             string           `xml:"Fecha,attr"`             // Atributo requerido para la expresión de la fecha y hora de expedición del Comprobante Fiscal Digital por Internet. Se expresa en la forma AAAA- MM-DDThh:mm:ss y debe corresponder con la hora local donde se expide el comprobante. tdCFDI:t_FechaH Req.
-	Sello             string           `xml:"Sello,attr"`             // Atributo requerido para contener el sello digital del comprobante fiscal, al que hacen referencia las reglas de resolución miscelánea vigente. El sello debe ser expresado como una cadena de texto en formato Base 64. Req.
-	FormaPago         string           `xml:"FormaPago,attr"`         // Atributo condicional para expresar la clave de la forma de pago de los bienes o servicios amparados por el comprobante. Si no se conoce la forma de pago este atributo se debe omitir. catCFDI:c_FormaPago Opc.
-	NoCertificado     string           `xml:"NoCertificado,attr"`     // Atributo requerido para expresar el número de serie del certificado de sello digital que ampara al comprobante, de acuerdo con el acuse correspondiente a 20 posiciones otorgado por el sistema del SAT. Pattern [0-9]{20} Req.
-	Certificado       string           `xml:"Certificado,attr"`       // Atributo requerido que sirve para incorporar el certificado de sello digital que ampara al comprobante, como texto en formato base 64. Req.
-	CondicionesDePago string           `xml:"CondicionesDePago,attr"` // Atributo condicional para expresar las condiciones comerciales aplicables para el pago del comprobante fiscal digital por Internet. Este atributo puede ser condicionado mediante atributos o complementos. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü |Ü){1,1000} Opc.
-	SubTotal          float64          `xml:"SubTotal,attr"`          // Atributo requerido para representar la suma de los importes de los conceptos antes de descuentos e impuesto. No se permiten valores negativos. Req.
-	Descuento         float64          `xml:"Descuento,attr"`         // Atributo condicional para representar el importe total de los descuentos aplicables antes de impuestos. No se permiten valores negativos. Se debe registrar cuando existan conceptos con descuento. Opc.
-	Moneda            string           `xml:"Moneda,attr"`            // Atributo requerido para identificar la clave de la moneda utilizada para expresar los montos, cuando se usa moneda nacional se registra MXN. Conforme con la especificación ISO 4217. catCFDI:c_Moneda Req.
-	TipoCambio        string           `xml:"TipoCambio,attr"`        // Atributo condicional para representar el tipo de cambio conforme con la moneda usada. Es requerido cuando la clave de moneda es distinta de MXN y de XXX. Opc.
-	Total             float64          `xml:"Total,attr"`             // Atributo requerido para representar la suma del subtotal, menos los descuentos aplicables, más las contribuciones recibidas. Req.
-	TipoDeComprobante string           `xml:"TipoDeComprobante,attr"` // Atributo requerido para expresar la clave del efecto del comprobante fiscal para el contribuyente emisor. Req
-	MetodoPago        string           `xml:"MetodoPago,attr"`        // Atributo condicional para precisar la clave del método de pago que aplica para este comprobante fiscal digital por Internet, conforme al Artículo 29-A fracción VII incisos a y b del CFF.Opc.
-	LugarExpedicion   string           `xml:"LugarExpedicion,attr"`   // Atributo requerido para incorporar el código postal del lugar de expedición del comprobante (domicilio de la matriz o de la sucursal). Req.
-	Confirmacion      string           `xml:"Confirmacion,attr"`      // Atributo condicional para registrar la clave de confirmación que entregue el PAC para expedir el comprobante con importes grandes, con un tipo de cambio fuera del rango establecido o con ambos casos. Es requerido cuando se registra un tipo de cambio o un total fuera del rango establecido. Pattern [0-9a-zA-Z]{5}. Opc.
-	Relacionados      CFDIRelacionados `xml:"cfdi:CfdiRelacionados"`
-	Emisor            CFDIEmisor       `xml:"cfdi:Emisor"`
-	Receptor          CFDIReceptor     `xml:"cfdi:Receptor"`
-	Conceptos         CFDIConceptos    `xml:"cfdi:Conceptos"`
-	Impuestos         CFDIImpuestos    `xml:"cfdi:Impuestos"`
-	Complemento       CFDIComplemento  `xml:"cfdi:Complemento"`
+	XMLName           xml.Name          `xml:"cfdi:Comprobante"`
+	Version           string            `xml:"Version,attr"`                     // Atributo requerido con valor prefijado a 3.3 que indica la versión del estándar bajo el que se encuentra expresado el comprobante. Default: "3.3" Req.
+	Serie             string            `xml:"Serie,attr,omitempty"`             // Atributo opcional para precisar la serie para control interno del contribuyente. Este atributo acepta una cadena de caracteres. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü| Ü){1,25} Opc.
+	Folio             string            `xml:"Folio,attr,omitempty"`             // Atributo opcional para control interno del contribuyente que expresa el folio del comprobante, acepta una cadena de caracteres. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü |Ü){1,40} Opc.
+	Fecha             string            `xml:"Fecha,attr"`                       // Atributo requerido para la expresión de la fecha y hora de expedición del Comprobante Fiscal Digital por Internet. Se expresa en la forma AAAA- MM-DDThh:mm:ss y debe corresponder con la hora local donde se expide el comprobante. tdCFDI:t_FechaH Req.
+	Sello             string            `xml:"Sello,attr"`                       // Atributo requerido para contener el sello digital del comprobante fiscal, al que hacen referencia las reglas de resolución miscelánea vigente. El sello debe ser expresado como una cadena de texto en formato Base 64. Req.
+	FormaPago         string            `xml:"FormaPago,attr,omitempty"`         // Atributo condicional para expresar la clave de la forma de pago de los bienes o servicios amparados por el comprobante. Si no se conoce la forma de pago este atributo se debe omitir. catCFDI:c_FormaPago Opc.
+	NoCertificado     string            `xml:"NoCertificado,attr"`               // Atributo requerido para expresar el número de serie del certificado de sello digital que ampara al comprobante, de acuerdo con el acuse correspondiente a 20 posiciones otorgado por el sistema del SAT. Pattern [0-9]{20} Req.
+	Certificado       string            `xml:"Certificado,attr"`                 // Atributo requerido que sirve para incorporar el certificado de sello digital que ampara al comprobante, como texto en formato base 64. Req.
+	CondicionesDePago string            `xml:"CondicionesDePago,attr,omitempty"` // Atributo condicional para expresar las condiciones comerciales aplicables para el pago del comprobante fiscal digital por Internet. Este atributo puede ser condicionado mediante atributos o complementos. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü |Ü){1,1000} Opc.
+	SubTotal          Decimal           `xml:"SubTotal,attr"`                    // Atributo requerido para representar la suma de los importes de los conceptos antes de descuentos e impuesto. No se permiten valores negativos. Req.
+	Descuento         *Decimal          `xml:"Descuento,attr,omitempty"`         // Atributo condicional para representar el importe total de los descuentos aplicables antes de impuestos. No se permiten valores negativos. Se debe registrar cuando existan conceptos con descuento. Opc.
+	Moneda            string            `xml:"Moneda,attr"`                      // Atributo requerido para identificar la clave de la moneda utilizada para expresar los montos, cuando se usa moneda nacional se registra MXN. Conforme con la especificación ISO 4217. catCFDI:c_Moneda Req.
+	TipoCambio        *Decimal          `xml:"TipoCambio,attr,omitempty"`        // Atributo condicional para representar el tipo de cambio conforme con la moneda usada. Es requerido cuando la clave de moneda es distinta de MXN y de XXX. Opc.
+	Total             Decimal           `xml:"Total,attr"`                       // Atributo requerido para representar la suma del subtotal, menos los descuentos aplicables, más las contribuciones recibidas. Req.
+	TipoDeComprobante string            `xml:"TipoDeComprobante,attr"`           // Atributo requerido para expresar la clave del efecto del comprobante fiscal para el contribuyente emisor. Req
+	MetodoPago        string            `xml:"MetodoPago,attr,omitempty"`        // Atributo condicional para precisar la clave del método de pago que aplica para este comprobante fiscal digital por Internet, conforme al Artículo 29-A fracción VII incisos a y b del CFF.Opc.
+	LugarExpedicion   string            `xml:"LugarExpedicion,attr"`             // Atributo requerido para incorporar el código postal del lugar de expedición del comprobante (domicilio de la matriz o de la sucursal). Req.
+	Confirmacion      string            `xml:"Confirmacion,attr,omitempty"`      // Atributo condicional para registrar la clave de confirmación que entregue el PAC para expedir el comprobante con importes grandes, con un tipo de cambio fuera del rango establecido o con ambos casos. Es requerido cuando se registra un tipo de cambio o un total fuera del rango establecido. Pattern [0-9a-zA-Z]{5}. Opc.
+	Relacionados      *CFDIRelacionados `xml:"cfdi:CfdiRelacionados,omitempty"`
+	Emisor            CFDIEmisor        `xml:"cfdi:Emisor"`
+	Receptor          CFDIReceptor      `xml:"cfdi:Receptor"`
+	Conceptos         CFDIConceptos     `xml:"cfdi:Conceptos"`
+	Impuestos         *CFDIImpuestos    `xml:"cfdi:Impuestos,omitempty"`
+	Complemento       *CFDIComplemento  `xml:"cfdi:Complemento,omitempty"`
+	Addenda           *CFDIAddenda      `xml:"cfdi:Addenda,omitempty"`
 }
 
 /*****************************************************************************************************************************************
@@ -51,15 +63,15 @@ type Comprobante struct {
 
 // CFDIRelacionados Nodo opcional para precisar la información de los comprobantes relacionados.
 type CFDIRelacionados struct {
-	XMLName         xml.Name        `xml:"cfdi:CfdiRelacionados"`
-	TipoRelacion    string          `xml:"tipoRelacion,attr"`    // Atributo requerido para indicar la clave de la relación que existe entre éste que se esta generando y el o los CFDI previos. catCFDI:c_TipoRelacion Req.
-	CfdiRelacionado CFDIRelacionado `xml:"cfdi:CfdiRelacionado"` // Nodo requerido para precisar la información de los comprobantes relacionados.
+	XMLName         xml.Name          `xml:"cfdi:CfdiRelacionados"`
+	TipoRelacion    string            `xml:"TipoRelacion,attr"`    // Atributo requerido para indicar la clave de la relación que existe entre éste que se esta generando y el o los CFDI previos. catCFDI:c_TipoRelacion Req.
+	CfdiRelacionado []CFDIRelacionado `xml:"cfdi:CfdiRelacionado"` // Nodo requerido para precisar la información de los comprobantes relacionados.
 }
 
 // CFDIRelacionado Nodo opcional para precisar la información de los comprobantes relacionados.
 type CFDIRelacionado struct {
 	XMLName xml.Name `xml:"cfdi:CfdiRelacionado"`
-	UUID    string   `xml:"UUID,attr"` // Atributo opcional para registrar el folio fiscal (UUID) de un CFDI relacionado con el presente comprobante, por ejemplo: Si el CFDI relacionado es un comprobante de traslado que sirve para registrar el movimiento de la mercancía. Si este comprobante se usa como nota de crédito o nota de débito del comprobante relacionado. Si este comprobante es una devolución sobre el comprobante relacionado. Si éste sustituye a una factura cancelada. Opc.
+	UUID    string   `xml:"UUID,attr"` // Atributo requerido para registrar el folio fiscal (UUID) de un CFDI relacionado con el presente comprobante, por ejemplo: Si el CFDI relacionado es un comprobante de traslado que sirve para registrar el movimiento de la mercancía. Si este comprobante se usa como nota de crédito o nota de débito del comprobante relacionado. Si este comprobante es una devolución sobre el comprobante relacionado. Si éste sustituye a una factura cancelada. Opc.
 }
 
 /*****************************************************************************************************************************************
@@ -72,9 +84,9 @@ type CFDIRelacionado struct {
 // CFDIEmisor Nodo requerido para expresar la información del contribuyente emisor del comprobante.
 type CFDIEmisor struct {
 	XMLName       xml.Name `xml:"cfdi:Emisor"`
-	RFC           string   `xml:"Rfc,attr"`           // Atributo requerido para registrar la Clave del Registro Federal de Contribuyentes correspondiente al contribuyente emisor del comprobante. Pattern [a-zA-Z]{3-4}[0,9]{6}[a-zA-Z0-9]{3} Req.
-	Nombre        string   `xml:"Nombre,attr"`        // Atributo opcional para registrar el nombre, denominación o razón social del contribuyente emisor del comprobante. Pattern  ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,254} Opc.
-	RegimenFiscal string   `xml:"RegimenFiscal,attr"` // Atributo requerido para incorporar la clave del régimen del contribuyente emisor al que aplicará el efecto fiscal de este comprobante.
+	RFC           string   `xml:"Rfc,attr"`              // Atributo requerido para registrar la Clave del Registro Federal de Contribuyentes correspondiente al contribuyente emisor del comprobante. Pattern [a-zA-Z]{3-4}[0,9]{6}[a-zA-Z0-9]{3} Req.
+	Nombre        string   `xml:"Nombre,attr,omitempty"` // Atributo opcional para registrar el nombre, denominación o razón social del contribuyente emisor del comprobante. Pattern  ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,254} Opc.
+	RegimenFiscal string   `xml:"RegimenFiscal,attr"`    // Atributo requerido para incorporar la clave del régimen del contribuyente emisor al que aplicará el efecto fiscal de este comprobante.
 }
 
 /*****************************************************************************************************************************************
@@ -87,11 +99,11 @@ type CFDIEmisor struct {
 // CFDIReceptor Nodo requerido para precisar la información del contribuyente receptor del comprobante.
 type CFDIReceptor struct {
 	XMLName          xml.Name `xml:"cfdi:Receptor"`
-	RFC              string   `xml:"Rfc,attr"`              // Atributo requerido para precisar la Clave del Registro Federal de Contribuyentes correspondiente al contribuyente receptor del comprobante. Req.
-	Nombre           string   `xml:"Nombre,attr"`           // Atributo opcional para precisar el nombre, denominación o razón social del contribuyente receptor del comprobante. Opc.
-	ResidenciaFiscal string   `xml:"ResidenciaFiscal,attr"` // Atributo condicional para registrar la clave del país de residencia para efectos fiscales del receptor del comprobante, cuando se trate de un extranjero, y que es conforme con la especificación ISO 3166-1 alpha-3. Es requerido cuando se incluya el complemento de comercio exterior o se registre el atributo NumRegIdTrib. c_Pais. Opc.
-	NumRegIDTrib     string   `xml:"NumRegIdTrib,attr"`     // Atributo condicional para expresar el número de registro de identidad fiscal del receptor cuando sea residente en el extranjero. Es requerido cuando se incluya el complemento de comercio exterior. Opc.
-	UsoCFDI          string   `xml:"UsoCFDI,attr"`          // Atributo requerido para expresar la clave del uso que dará a esta factura el receptor del CFDI. c_UsoCFDI. Req.
+	RFC              string   `xml:"Rfc,attr"`                        // Atributo requerido para precisar la Clave del Registro Federal de Contribuyentes correspondiente al contribuyente receptor del comprobante. Req.
+	Nombre           string   `xml:"Nombre,attr,omitempty"`           // Atributo opcional para precisar el nombre, denominación o razón social del contribuyente receptor del comprobante. Opc.
+	ResidenciaFiscal string   `xml:"ResidenciaFiscal,attr,omitempty"` // Atributo condicional para registrar la clave del país de residencia para efectos fiscales del receptor del comprobante, cuando se trate de un extranjero, y que es conforme con la especificación ISO 3166-1 alpha-3. Es requerido cuando se incluya el complemento de comercio exterior o se registre el atributo NumRegIdTrib. c_Pais. Opc.
+	NumRegIDTrib     string   `xml:"NumRegIdTrib,attr,omitempty"`     // Atributo condicional para expresar el número de registro de identidad fiscal del receptor cuando sea residente en el extranjero. Es requerido cuando se incluya el complemento de comercio exterior. Opc.
+	UsoCFDI          string   `xml:"UsoCFDI,attr"`                    // Atributo requerido para expresar la clave del uso que dará a esta factura el receptor del CFDI. c_UsoCFDI. Req.
 }
 
 /*****************************************************************************************************************************************
@@ -103,62 +115,68 @@ type CFDIReceptor struct {
 
 // CFDIConceptos Nodo requerido para listar los conceptos cubiertos por el comprobante.
 type CFDIConceptos struct {
-	XMLName   xml.Name     `xml:"cfdi:Conceptos"`
-	Conceptos CFDIConcepto // Lista de conceptos
+	XMLName   xml.Name       `xml:"cfdi:Conceptos"`
+	Conceptos []CFDIConcepto `xml:"cfdi:Concepto"` // Lista de conceptos, uno por cada bien o servicio amparado; cada concepto lleva su propio nodo cfdi:Impuestos.
 }
 
 // CFDIConcepto Nodo requerido para registrar la información detallada de un bien o servicio amparado en el comprobante.
 type CFDIConcepto struct {
-	XMLName          xml.Name           `xml:"cfdi:Concepto"`
-	ClaveProdServ    string             `xml:"ClaveProdServ,attr"`    // Atributo requerido para expresar la clave del producto o del servicio amparado por el presente concepto. Es requerido y deben utilizar las claves del catálogo de productos y servicios, cuando los conceptos que registren por sus actividades correspondan con dichos conceptos. c_ClaveProdServ Req.
-	NoIdentificacion string             `xml:"NoIdentificacion,attr"` // Atributo opcional para expresar el número de parte, identificador del producto o del servicio, la clave de producto o servicio, SKU o equivalente, propia de la operación del emisor, amparado por el presente concepto. Opcionalmente se puede utilizar claves del estándar GTIN. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,100}. Opc.
-	Cantidad         float64            `xml:"Cantidad,attr"`         // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por el presente concepto. decimales (6) Req.
-	ClaveUnidad      string             `xml:"ClaveUnidad,attr"`      // Atributo requerido para precisar la clave de unidad de medida estandarizada aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. catCFDI:c_ClaveUnidad Req.
-	Unidad           string             `xml:"Unidad,attr"`           // Atributo opcional para precisar la unidad de medida propia de la operación del emisor, aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,20}.Opc.
-	Descripcion      string             `xml:"Descripcion,attr"`      // Atributo requerido para precisar la descripción del bien o servicio cubierto por el presente concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,1000} Opc.
-	ValorUnitario    float64            `xml:"ValorUnitario,attr"`    // Atributo requerido para precisar el valor o precio unitario del bien o servicio cubierto por el presente concepto. tdCFDI:t_Importe Req.
-	Importe          float64            `xml:"Importe,attr"`          //Atributo requerido para precisar el importe total de los bienes o servicios del presente concepto. Debe ser equivalente al resultado de multiplicar la cantidad por el valor unitario expresado en el concepto. No se permiten valores negativos. tdCFDI:t_Importe Req.
-	Descuento        float64            `xml:"Descuento,attr"`        // Atributo opcional para representar el importe de los descuentos aplicables al concepto. No se permiten valores negativos. tdCFDI:t_Importe Opc.
-	Impuestos        CFDIImpuestosInner `xml:"cfdi:Impuestos"`
+	XMLName             xml.Name                  `xml:"cfdi:Concepto"`
+	ClaveProdServ       string                    `xml:"ClaveProdServ,attr"`              // Atributo requerido para expresar la clave del producto o del servicio amparado por el presente concepto. Es requerido y deben utilizar las claves del catálogo de productos y servicios, cuando los conceptos que registren por sus actividades correspondan con dichos conceptos. c_ClaveProdServ Req.
+	NoIdentificacion    string                    `xml:"NoIdentificacion,attr,omitempty"` // Atributo opcional para expresar el número de parte, identificador del producto o del servicio, la clave de producto o servicio, SKU o equivalente, propia de la operación del emisor, amparado por el presente concepto. Opcionalmente se puede utilizar claves del estándar GTIN. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,100}. Opc.
+	Cantidad            Decimal                   `xml:"Cantidad,attr"`                   // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por el presente concepto. decimales (6) Req.
+	ClaveUnidad         string                    `xml:"ClaveUnidad,attr"`                // Atributo requerido para precisar la clave de unidad de medida estandarizada aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. catCFDI:c_ClaveUnidad Req.
+	Unidad              string                    `xml:"Unidad,attr,omitempty"`           // Atributo opcional para precisar la unidad de medida propia de la operación del emisor, aplicable para la cantidad expresada en el concepto. La unidad debe corresponder con la descripción del concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,20}.Opc.
+	Descripcion         string                    `xml:"Descripcion,attr"`                // Atributo requerido para precisar la descripción del bien o servicio cubierto por el presente concepto. Pattern ([A-Z]|[a-z]|[0-9]| |Ñ|ñ|!|&quot;|%|&amp;|&apos;| ́|- |:|;|&gt;|=|&lt;|@|_|,|\{|\}|`|~|á|é|í|ó|ú|Á|É|Í|Ó|Ú|ü|Ü){1,1000} Opc.
+	ValorUnitario       Decimal                   `xml:"ValorUnitario,attr"`              // Atributo requerido para precisar el valor o precio unitario del bien o servicio cubierto por el presente concepto. tdCFDI:t_Importe Req.
+	Importe             Decimal                   `xml:"Importe,attr"`                    //Atributo requerido para precisar el importe total de los bienes o servicios del presente concepto. Debe ser equivalente al resultado de multiplicar la cantidad por el valor unitario expresado en el concepto. No se permiten valores negativos. tdCFDI:t_Importe Req.
+	Descuento           *Decimal                  `xml:"Descuento,attr,omitempty"`        // Atributo opcional para representar el importe de los descuentos aplicables al concepto. No se permiten valores negativos. tdCFDI:t_Importe Opc.
+	Impuestos           *CFDIImpuestosInner       `xml:"cfdi:Impuestos,omitempty"`
+	InformacionAduanera []CFDIInformacionAduanera `xml:"cfdi:InformacionAduanera,omitempty"` // Nodo opcional que puede repetirse, uno por cada pedimento que ampara la importación del bien.
+	CuentaPredial       *CFDICuentaPredial        `xml:"cfdi:CuentaPredial,omitempty"`       // Nodo opcional que sólo puede aparecer una vez por concepto.
+	ComplementoConcepto *ComplementoConcepto      `xml:"cfdi:ComplementoConcepto,omitempty"`
+	Parte               []CFDIParte               `xml:"cfdi:Parte,omitempty"` // Nodo opcional que puede repetirse, uno por cada parte componente del bien o servicio.
 }
 
-// CFDIImpuestosInner Nodo opcional para capturar los impuestos aplicables al presente concepto. Cuando un concepto no registra un impuesto, implica que no es objeto del mismo.
+// CFDIImpuestosInner Nodo opcional para capturar los impuestos aplicables al presente concepto. Cuando un concepto no registra un impuesto, implica que no es objeto del mismo. El esquema exige Traslados antes que Retenciones.
 type CFDIImpuestosInner struct {
-	XMLName     xml.Name                      `xml:"cfdi:Impuestos"`
-	Traslados   CFDIImpuestosTrasladosInner   `xml:"cfdi:Traslados"`   //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
-	Retenciones CFDIImpuestosRetencionesInner `xml:"cfdi:Retenciones"` //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
+	XMLName     xml.Name                       `xml:"cfdi:Impuestos"`
+	Traslados   *CFDIImpuestosTrasladosInner   `xml:"cfdi:Traslados,omitempty"`   //Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
+	Retenciones *CFDIImpuestosRetencionesInner `xml:"cfdi:Retenciones,omitempty"` //Nodo opcional para asentar los impuestos retenidos aplicables al presente concepto.
 }
 
 // CFDIImpuestosTrasladosInner Nodo opcional para asentar los impuestos trasladados aplicables al presente concepto.
 type CFDIImpuestosTrasladosInner struct {
-	XMLName   xml.Name                   `xml:"cfdi:Traslados"`
-	Traslados CFDIImpuestosTrasladoInner `xml:"cfdi:Traslado"`
+	XMLName   xml.Name                     `xml:"cfdi:Traslados"`
+	Traslados []CFDIImpuestosTrasladoInner `xml:"cfdi:Traslado"` // Un traslado por cada impuesto aplicable al concepto (p.ej. IVA e IEPS).
 }
 
 // CFDIImpuestosTrasladoInner Nodo requerido para asentar la información detallada de un traslado de impuestos aplicable al presente concepto.
 type CFDIImpuestosTrasladoInner struct {
 	XMLName    xml.Name `xml:"cfdi:Traslado"`
-	Base       float64  `xml:"Base,attr"`       // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
-	Impuesto   string   `xml:"Impuesto,attr"`   // Atributo requerido para señalar la clave del tipo de impuesto trasladado aplicable al concepto.
-	TipoFactor string   `xml:"TipoFactor,attr"` // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64  `xml:"TasaOCuota,attr"` // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
-	Importe    float64  `xml:"Importe,attr"`    // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
+	Base       Decimal  `xml:"Base,attr"`                 // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
+	BaseFija   bool     `xml:"-"`                         // Indica a CalcularTotales que conserve Base en lugar de derivarla del importe del concepto menos su descuento, p.ej. cuando la base incluye otro impuesto. No forma parte del XML.
+	Impuesto   string   `xml:"Impuesto,attr"`             // Atributo requerido para señalar la clave del tipo de impuesto trasladado aplicable al concepto.
+	TipoFactor string   `xml:"TipoFactor,attr"`           // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
+	TasaOCuota *Decimal `xml:"TasaOCuota,attr,omitempty"` // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
+	Importe    *Decimal `xml:"Importe,attr,omitempty"`    // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
 }
 
 // CFDIImpuestosRetencionesInner Nodo opcional para asentar los impuestos retenidos aplicables al presente concepto.
 type CFDIImpuestosRetencionesInner struct {
-	XMLName     xml.Name                    `xml:"cfdi:Retenciones"`
-	Retenciones CFDIImpuestosRetencionInner `xml:"cfdi:Retencion"`
+	XMLName     xml.Name                      `xml:"cfdi:Retenciones"`
+	Retenciones []CFDIImpuestosRetencionInner `xml:"cfdi:Retencion"` // Una retención por cada impuesto retenido en el concepto (p.ej. ISR e IVA).
 }
 
 // CFDIImpuestosRetencionInner Nodo requerido para asentar la información detallada de una retención de impuestos aplicable al presente concepto.
 type CFDIImpuestosRetencionInner struct {
 	XMLName    xml.Name `xml:"cfdi:Retencion"`
-	Base       float64  `xml:"Base,attr"`       // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
+	Base       Decimal  `xml:"Base,attr"`       // Atributo requerido para señalar la base para el cálculo del impuesto, la determinación de la base se realiza de acuerdo con las disposiciones fiscales vigentes. No se permiten valores negativos.
+	BaseFija   bool     `xml:"-"`               // Indica a CalcularTotales que conserve Base en lugar de derivarla del importe del concepto menos su descuento. No forma parte del XML.
 	Impuesto   string   `xml:"Impuesto,attr"`   // Atributo requerido para señalar la clave del tipo de impuesto trasladado aplicable al concepto.
 	TipoFactor string   `xml:"TipoFactor,attr"` // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64  `xml:"TasaOCuota,attr"` // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
-	Importe    float64  `xml:"Importe,attr"`    // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
+	TasaOCuota Decimal  `xml:"TasaOCuota,attr"` // Atributo condicional para señalar el valor de la tasa o cuota del impuesto que se traslada para el presente concepto. Es requerido cuando el atributo TipoFactor tenga un valor que corresponda a Tasa o Cuota.
+	Importe    Decimal  `xml:"Importe,attr"`    // Atributo condicional para señalar el importe del impuesto trasladado que aplica al concepto. No se permiten valores negativos. Es requerido cuando TipoFactor sea Tasa o Cuota
 }
 
 // CFDIInformacionAduanera Nodo opcional para introducir la información aduanera aplicable cuando se trate de ventas de primera mano de mercancías importadas o se trate de operaciones de comercio exterior con bienes o servicios.
@@ -175,8 +193,21 @@ type CFDICuentaPredial struct {
 
 // ComplementoConcepto Nodo opcional donde se incluyen los nodos complementarios de extensión al concepto definidos por el SAT, de acuerdo con las disposiciones particulares para un sector o actividad específica.
 type ComplementoConcepto struct {
-	XMLName     xml.Name `xml:"cfdi:ComplementoConcepto"`
-	Complemento interface{}
+	XMLName      xml.Name      `xml:"cfdi:ComplementoConcepto"`
+	Complementos []interface{} // Complementos del concepto: valores de tipos registrados con RegistrarComplemento o *ComplementoDesconocido.
+}
+
+// CFDIParte Nodo opcional para expresar las partes o componentes que integran la totalidad del concepto expresado en el comprobante fiscal digital por Internet.
+type CFDIParte struct {
+	XMLName             xml.Name                  `xml:"cfdi:Parte"`
+	ClaveProdServ       string                    `xml:"ClaveProdServ,attr"`                 // Atributo requerido para expresar la clave del producto o del servicio amparado por la presente parte. c_ClaveProdServ Req.
+	NoIdentificacion    string                    `xml:"NoIdentificacion,attr,omitempty"`    // Atributo opcional para expresar el número de serie, número de parte del bien o identificador del producto o del servicio amparado por la presente parte. Opc.
+	Cantidad            Decimal                   `xml:"Cantidad,attr"`                      // Atributo requerido para precisar la cantidad de bienes o servicios del tipo particular definido por la presente parte. decimales (6) Req.
+	Unidad              string                    `xml:"Unidad,attr,omitempty"`              // Atributo opcional para precisar la unidad de medida propia de la operación del emisor, aplicable para la cantidad expresada en la parte. Opc.
+	Descripcion         string                    `xml:"Descripcion,attr"`                   // Atributo requerido para precisar la descripción del bien o servicio cubierto por la presente parte. Req.
+	ValorUnitario       *Decimal                  `xml:"ValorUnitario,attr,omitempty"`       // Atributo opcional para precisar el valor o precio unitario del bien o servicio cubierto por la presente parte. tdCFDI:t_Importe Opc.
+	Importe             *Decimal                  `xml:"Importe,attr,omitempty"`             // Atributo opcional para precisar el importe total de los bienes o servicios de la presente parte. Debe ser equivalente al resultado de multiplicar la cantidad por el valor unitario expresado en la parte. tdCFDI:t_Importe Opc.
+	InformacionAduanera []CFDIInformacionAduanera `xml:"cfdi:InformacionAduanera,omitempty"` // Nodo opcional que puede repetirse, uno por cada pedimento que ampara la importación de la parte.
 }
 
 // /*****************************************************************************************************************************************
@@ -186,41 +217,41 @@ type ComplementoConcepto struct {
 // *
 // ****************************************************************************************************************************************/
 
-// CFDIImpuestos Nodo condicional para expresar el resumen de los impuestos aplicables.
+// CFDIImpuestos Nodo condicional para expresar el resumen de los impuestos aplicables. A diferencia del nodo del concepto, el esquema exige Retenciones antes que Traslados.
 type CFDIImpuestos struct {
-	XMLName                   xml.Name `xml:"cfdi:Impuestos"`
-	TotalImpuestosRetenidos   float64  `xml:"TotalImpuestosRetenidos,attr"`   // Atributo condicional para expresar el total de los impuestos retenidos que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos retenidos
-	TotalImpuestosTrasladados float64  `xml:"TotalImpuestosTrasladados,attr"` // Atributo condicional para expresar el total de los impuestos trasladados que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos trasladados.
-	Retenciones               CFDIRetenciones
-	Traslados                 CFDITraslados
+	XMLName                   xml.Name         `xml:"cfdi:Impuestos"`
+	TotalImpuestosRetenidos   *Decimal         `xml:"TotalImpuestosRetenidos,attr,omitempty"`   // Atributo condicional para expresar el total de los impuestos retenidos que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos retenidos
+	TotalImpuestosTrasladados *Decimal         `xml:"TotalImpuestosTrasladados,attr,omitempty"` // Atributo condicional para expresar el total de los impuestos trasladados que se desprenden de los conceptos expresados en el comprobante fiscal digital por Internet. No se permiten valores negativos. Es requerido cuando en los conceptos se registren impuestos trasladados.
+	Retenciones               *CFDIRetenciones `xml:"cfdi:Retenciones,omitempty"`
+	Traslados                 *CFDITraslados   `xml:"cfdi:Traslados,omitempty"`
 }
 
 // CFDIRetenciones Nodo condicional para capturar los impuestos retenidos aplicables. Es requerido cuando en los conceptos se registre algún impuesto retenido.
 type CFDIRetenciones struct {
-	XMLName     xml.Name      `xml:"cfdi:Retenciones"`
-	Retenciones CFDIRetencion `xml:"cfdi:Retencion"`
+	XMLName     xml.Name        `xml:"cfdi:Retenciones"`
+	Retenciones []CFDIRetencion `xml:"cfdi:Retencion"` // Una retención por cada tipo de impuesto retenido.
 }
 
 // CFDIRetencion Nodo requerido para la información detallada de una retención de impuesto específico
 type CFDIRetencion struct {
 	XMLName  xml.Name `xml:"cfdi:Retencion"`
 	Impuesto string   `xml:"Impuesto,attr"` // Atributo requerido para señalar la clave del tipo de impuesto retenido
-	Importe  float64  `xml:"Importe,attr"`  // Atributo requerido para señalar el monto del impuesto retenido. No se permiten valores negativos.
+	Importe  Decimal  `xml:"Importe,attr"`  // Atributo requerido para señalar el monto del impuesto retenido. No se permiten valores negativos.
 }
 
 // CFDITraslados Nodo condicional para capturar los impuestos trasladados aplicables. Es requerido cuando en los conceptos se registre un impuesto trasladado.
 type CFDITraslados struct {
-	XMLName   xml.Name     `xml:"cfdi:Traslados"`
-	Traslados CFDITraslado `xml:"cfdi:Traslado"`
+	XMLName   xml.Name       `xml:"cfdi:Traslados"`
+	Traslados []CFDITraslado `xml:"cfdi:Traslado"` // Un traslado por cada combinación de Impuesto, TipoFactor y TasaOCuota.
 }
 
 // CFDITraslado Nodo requerido para la información detallada de un traslado de impuesto específico.
 type CFDITraslado struct {
 	XMLName    xml.Name `xml:"cfdi:Traslado"`
-	Impuesto   string   `xml:"cfdi:Impuesto,attr"`   // Atributo requerido para señalar la clave del tipo de impuesto trasladado.
-	TipoFactor string   `xml:"cfdi:TipoFactor,attr"` // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
-	TasaOCuota float64  `xml:"cfdi:TasaOCuota,attr"` // Atributo requerido para señalar el valor de la tasa o cuota del impuesto que se traslada por los conceptos amparados en el comprobante.
-	Importe    float64  `xml:"cfdi:Importe,attr"`    // Atributo requerido para señalar la suma del importe del impuesto trasladado, agrupado por impuesto, TipoFactor y TasaOCuota. No se permiten valores negativos.
+	Impuesto   string   `xml:"Impuesto,attr"`   // Atributo requerido para señalar la clave del tipo de impuesto trasladado.
+	TipoFactor string   `xml:"TipoFactor,attr"` // Atributo requerido para señalar la clave del tipo de factor que se aplica a la base del impuesto.
+	TasaOCuota Decimal  `xml:"TasaOCuota,attr"` // Atributo requerido para señalar el valor de la tasa o cuota del impuesto que se traslada por los conceptos amparados en el comprobante.
+	Importe    Decimal  `xml:"Importe,attr"`    // Atributo requerido para señalar la suma del importe del impuesto trasladado, agrupado por impuesto, TipoFactor y TasaOCuota. No se permiten valores negativos.
 }
 
 // /*
@@ -228,37 +259,124 @@ type CFDITraslado struct {
 
 // CFDIComplemento Nodo opcional donde se incluye el complemento Timbre Fiscal Digital de manera obligatoria y los nodos complementarios determinados por el SAT, de acuerdo con las disposiciones particulares para un sector o actividad específica.
 type CFDIComplemento struct {
-	XMLName xml.Name   `xml:"cfdi:Complemento"`
-	Timbre  CFDITimbre `xml:"tfd:TimbreFiscalDigital"`
+	XMLName      xml.Name      `xml:"cfdi:Complemento"`
+	Complementos []interface{} // Complementos del comprobante, incluido el *CFDITimbre: valores de tipos registrados con RegistrarComplemento o *ComplementoDesconocido.
 }
 
 // /*
 //  */
 
-// // CFDIAddenda Nodo opcional para recibir las extensiones al presente formato que sean de utilidad al contribuyente. Para las reglas de uso del mismo, referirse al formato origen.
-// type CFDIAddenda struct {
-// 	elemento interface{}
-// }
+// CFDIAddenda Nodo opcional para recibir las extensiones al presente formato que sean de utilidad al contribuyente. Para las reglas de uso del mismo, referirse al formato origen. No forma parte de la cadena original ni del sello.
+type CFDIAddenda struct {
+	XMLName    xml.Name      `xml:"cfdi:Addenda"`
+	Contenido  []byte        // XML crudo de la addenda. Al leer un comprobante se conserva tal como venía; al escribirlo, si no está vacío, se emite sin cambios y Elementos se ignora.
+	Namespaces []xml.Attr    // Declaraciones xmlns heredadas del nodo raíz o de cfdi:Addenda en el documento original, de las que puede depender Contenido. Se declaran en cfdi:Addenda al escribir.
+	Elementos  []interface{} // Valores tipados de la addenda. Al leer un comprobante se llena con los nodos cuyos tipos fueron registrados con RegistrarAddenda.
+}
 
-// CFDITimbre Estructura XML del timbre Fiscal Digital
+// CFDITimbre Complemento Timbre Fiscal Digital 1.1 que el proveedor de certificación (PAC) agrega al comprobante al timbrarlo.
 type CFDITimbre struct {
 	XMLName          xml.Name `xml:"tfd:TimbreFiscalDigital"`
-	Tfd              string   `xml:"xmlns:tfd,attr"`
-	SchemaLocation   string   `xml:"xsi:schemaLocation,attr"`
-	SelloSAT         string   `xml:"selloSAT,attr"`
-	NoCertificadoSAT string   `xml:"noCertificadoSAT,attr"`
-	SelloCFD         string   `xml:"selloCFD,attr"`
-	FechaTimbrado    string   `xml:"FechaTimbrado,attr"`
-	UUID             string   `xml:"UUID,attr"`
-	Version          string   `xml:"version,attr"`
+	Version          string   `xml:"Version,attr"`           // Atributo requerido con valor prefijado a 1.1 que indica la versión del estándar bajo el que se encuentra expresado el timbre. Req.
+	UUID             string   `xml:"UUID,attr"`              // Atributo requerido para expresar los 36 caracteres del folio fiscal (UUID) de la transacción de timbrado conforme al estándar RFC 4122. Req.
+	FechaTimbrado    string   `xml:"FechaTimbrado,attr"`     // Atributo requerido para expresar la fecha y hora, de la generación del timbre por la certificación digital del SAT. Se expresa en la forma AAAA-MM-DDThh:mm:ss. Req.
+	RFCProvCertif    string   `xml:"RfcProvCertif,attr"`     // Atributo requerido para expresar el RFC del proveedor de certificación de comprobantes fiscales digitales que genera el timbre fiscal digital. Req.
+	Leyenda          string   `xml:"Leyenda,attr,omitempty"` // Atributo opcional para registrar información que el SAT comunique a los usuarios del CFDI. Opc.
+	SelloCFD         string   `xml:"SelloCFD,attr"`          // Atributo requerido para contener el sello digital del comprobante fiscal o del comprobante de retenciones, que se ha timbrado. El sello debe ser expresado como una cadena de texto en formato Base 64. Req.
+	NoCertificadoSAT string   `xml:"NoCertificadoSAT,attr"`  // Atributo requerido para expresar el número de serie del certificado del SAT usado para generar el sello digital del Timbre Fiscal Digital. Req.
+	SelloSAT         string   `xml:"SelloSAT,attr"`          // Atributo requerido para contener el sello digital del Timbre Fiscal Digital, al que hacen referencia las reglas de la Resolución Miscelánea vigente. El sello debe ser expresado como una cadena de texto en formato Base 64. Req.
+}
+
+// MarshalXML Serializa el comprobante declarando en el nodo raíz los espacios de nombres cfdi y xsi, los de los complementos presentes, así como el atributo xsi:schemaLocation requeridos por el esquema. Una Version vacía se escribe como VersionCFDI. Los totales y las tasas con menos decimales que los de la moneda o del atributo se completan con ceros; los decimales sobrantes nunca se descartan, Validate los reporta.
+func (c Comprobante) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type comprobante Comprobante
+	c = ajustarDecimales(omitirNodosVacios(valoresPrefijados(c)))
+	namespaces, ubicaciones := namespacesComplementos(c)
+	start.Name = xml.Name{Local: "cfdi:Comprobante"}
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "xmlns:cfdi"}, Value: NamespaceCFDI},
+		xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: NamespaceXSI},
+	)
+	start.Attr = append(start.Attr, namespaces...)
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: strings.Join(append([]string{NamespaceCFDI, EsquemaCFDI}, ubicaciones...), " ")})
+	return e.EncodeElement(comprobante(c), start)
+}
+
+// valoresPrefijados Devuelve una copia del comprobante con los atributos de valor prefijado que vengan vacíos, como Version, llenos con el valor que exige el esquema.
+func valoresPrefijados(c Comprobante) Comprobante {
+	if c.Version == "" {
+		c.Version = VersionCFDI
+	}
+	return c
+}
+
+// omitirNodosVacios Devuelve una copia del comprobante sin los nodos opcionales que no tienen contenido, ya que el esquema no admite nodos opcionales vacíos.
+func omitirNodosVacios(c Comprobante) Comprobante {
+	if c.Relacionados != nil && len(c.Relacionados.CfdiRelacionado) == 0 {
+		c.Relacionados = nil
+	}
+	if c.Impuestos != nil {
+		impuestos := *c.Impuestos
+		if impuestos.Retenciones != nil && len(impuestos.Retenciones.Retenciones) == 0 {
+			impuestos.Retenciones = nil
+		}
+		if impuestos.Traslados != nil && len(impuestos.Traslados.Traslados) == 0 {
+			impuestos.Traslados = nil
+		}
+		c.Impuestos = &impuestos
+		if impuestos.Retenciones == nil && impuestos.Traslados == nil && impuestos.TotalImpuestosRetenidos == nil && impuestos.TotalImpuestosTrasladados == nil {
+			c.Impuestos = nil
+		}
+	}
+	if c.Complemento != nil && len(c.Complemento.Complementos) == 0 {
+		c.Complemento = nil
+	}
+	if c.Addenda != nil && len(c.Addenda.Contenido) == 0 && len(c.Addenda.Elementos) == 0 {
+		c.Addenda = nil
+	}
+	conceptos := make([]CFDIConcepto, len(c.Conceptos.Conceptos))
+	for i, concepto := range c.Conceptos.Conceptos {
+		if concepto.ComplementoConcepto != nil && len(concepto.ComplementoConcepto.Complementos) == 0 {
+			concepto.ComplementoConcepto = nil
+		}
+		if concepto.Impuestos != nil {
+			impuestos := *concepto.Impuestos
+			if impuestos.Traslados != nil && len(impuestos.Traslados.Traslados) == 0 {
+				impuestos.Traslados = nil
+			}
+			if impuestos.Retenciones != nil && len(impuestos.Retenciones.Retenciones) == 0 {
+				impuestos.Retenciones = nil
+			}
+			concepto.Impuestos = &impuestos
+			if impuestos.Traslados == nil && impuestos.Retenciones == nil {
+				concepto.Impuestos = nil
+			}
+		}
+		conceptos[i] = concepto
+	}
+	c.Conceptos.Conceptos = conceptos
+	return c
+}
+
+// MarshalXML Serializa el timbre declarando localmente el espacio de nombres tfd y su xsi:schemaLocation, de modo que sólo aparecen cuando el nodo está presente.
+func (t CFDITimbre) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type timbre CFDITimbre
+	start.Name = xml.Name{Local: "tfd:TimbreFiscalDigital"}
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "xmlns:tfd"}, Value: NamespaceTFD},
+		xml.Attr{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: NamespaceTFD + " " + EsquemaTFD},
+	)
+	return e.EncodeElement(timbre(t), start)
 }
 
-//MarshallData2XML Transformar Estructura a XML
+// MarshallData2XML Transformar Estructura a XML. Devuelve una cadena vacía si el comprobante no puede serializarse.
+//
+// Deprecated: usar Marshal, MarshalIndent o Encoder, que informan el error.
 func MarshallData2XML(comprobante Comprobante) string {
 
-	output, err := xml.MarshalIndent(comprobante, "  ", "    ")
+	output, err := MarshalIndent(comprobante, "  ", "    ")
 	if err != nil {
-		fmt.Printf("error: %v\n", err)
+		return ""
 	}
 	return string(output)
 
@@ -0,0 +1,159 @@
+package xmlstructures
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dec Interpreta s como Decimal; los textos de las pruebas siempre son válidos.
+func dec(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// pdec Igual que dec pero devuelve un puntero, para los atributos opcionales.
+func pdec(s string) *Decimal {
+	d := dec(s)
+	return &d
+}
+
+// comprobantePrueba Devuelve un comprobante de ingreso en MXN con n conceptos; cada uno traslada IVA al 16 % y, los de posición impar, retiene ISR al 10 %. Los importes se calculan con CalcularTotales.
+func comprobantePrueba(n int) Comprobante {
+	c := Comprobante{
+		Version:           VersionCFDI,
+		Serie:             "A",
+		Folio:             "100",
+		Fecha:             "2026-10-17T12:00:00",
+		FormaPago:         "01",
+		Moneda:            "MXN",
+		TipoDeComprobante: "I",
+		MetodoPago:        "PUE",
+		LugarExpedicion:   "01000",
+		Emisor:            CFDIEmisor{RFC: "EKU9003173C9", Nombre: "ESCUELA KEMPER URGATE", RegimenFiscal: "601"},
+		Receptor:          CFDIReceptor{RFC: "XAXX010101000", Nombre: "PUBLICO EN GENERAL", UsoCFDI: "G03"},
+	}
+	for i := 0; i < n; i++ {
+		concepto := CFDIConcepto{
+			ClaveProdServ: "01010101",
+			Cantidad:      NewDecimal(int64(i+1), 0),
+			ClaveUnidad:   "H87",
+			Descripcion:   fmt.Sprintf("Producto %d", i+1),
+			ValorUnitario: NewDecimal(int64(1000+i*25), 2),
+			Impuestos: &CFDIImpuestosInner{Traslados: &CFDIImpuestosTrasladosInner{Traslados: []CFDIImpuestosTrasladoInner{
+				{Impuesto: "002", TipoFactor: TipoFactorTasa, TasaOCuota: pdec("0.160000")},
+			}}},
+		}
+		if i%2 == 1 {
+			concepto.Impuestos.Retenciones = &CFDIImpuestosRetencionesInner{Retenciones: []CFDIImpuestosRetencionInner{
+				{Impuesto: "001", TipoFactor: TipoFactorTasa, TasaOCuota: dec("0.100000")},
+			}}
+		}
+		c.Conceptos.Conceptos = append(c.Conceptos.Conceptos, concepto)
+	}
+	if err := c.CalcularTotales(); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestRoundTripConceptos(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 25} {
+		t.Run(fmt.Sprintf("%d conceptos", n), func(t *testing.T) {
+			original := comprobantePrueba(n)
+			datos, err := Marshal(original)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if got := bytes.Count(datos, []byte("<cfdi:Concepto ")); got != n {
+				t.Fatalf("el XML tiene %d nodos cfdi:Concepto, se esperaban %d", got, n)
+			}
+			var leido Comprobante
+			if err := Unmarshal(datos, &leido); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(leido.Conceptos.Conceptos) != n {
+				t.Fatalf("se leyeron %d conceptos, se esperaban %d", len(leido.Conceptos.Conceptos), n)
+			}
+			for i, concepto := range leido.Conceptos.Conceptos {
+				esperado := original.Conceptos.Conceptos[i]
+				if concepto.Descripcion != esperado.Descripcion || concepto.Importe.Cmp(esperado.Importe) != 0 || concepto.Cantidad.Cmp(esperado.Cantidad) != 0 {
+					t.Errorf("concepto %d: se leyó %+v, se esperaba %+v", i+1, concepto, esperado)
+				}
+				traslados := concepto.Impuestos.Traslados.Traslados
+				if len(traslados) != 1 || traslados[0].Importe.Cmp(*esperado.Impuestos.Traslados.Traslados[0].Importe) != 0 {
+					t.Errorf("concepto %d: traslados %+v", i+1, traslados)
+				}
+				switch {
+				case i%2 == 1 && (concepto.Impuestos.Retenciones == nil || len(concepto.Impuestos.Retenciones.Retenciones) != 1):
+					t.Errorf("concepto %d: falta la retención", i+1)
+				case i%2 == 0 && concepto.Impuestos.Retenciones != nil:
+					t.Errorf("concepto %d: retención inesperada", i+1)
+				}
+			}
+			if leido.SubTotal.Cmp(original.SubTotal) != 0 || leido.Total.Cmp(original.Total) != 0 {
+				t.Errorf("SubTotal/Total leídos %s/%s, se esperaban %s/%s", leido.SubTotal, leido.Total, original.SubTotal, original.Total)
+			}
+			otra, err := Marshal(leido)
+			if err != nil {
+				t.Fatalf("Marshal del comprobante leído: %v", err)
+			}
+			if !bytes.Equal(datos, otra) {
+				t.Errorf("la segunda serialización difiere:\n%s\n%s", datos, otra)
+			}
+		})
+	}
+}
+
+func TestRoundTripImpuestosAgrupados(t *testing.T) {
+	c := comprobantePrueba(4)
+	datos, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leido Comprobante
+	if err := Unmarshal(datos, &leido); err != nil {
+		t.Fatal(err)
+	}
+	impuestos := leido.Impuestos
+	if impuestos == nil || impuestos.Traslados == nil || impuestos.Retenciones == nil {
+		t.Fatalf("faltan los impuestos del comprobante: %+v", impuestos)
+	}
+	if len(impuestos.Traslados.Traslados) != 1 || len(impuestos.Retenciones.Retenciones) != 1 {
+		t.Fatalf("se esperaba un traslado y una retención agrupados, se leyeron %d y %d", len(impuestos.Traslados.Traslados), len(impuestos.Retenciones.Retenciones))
+	}
+	if impuestos.TotalImpuestosTrasladados.Cmp(*c.Impuestos.TotalImpuestosTrasladados) != 0 || impuestos.TotalImpuestosRetenidos.Cmp(*c.Impuestos.TotalImpuestosRetenidos) != 0 {
+		t.Errorf("totales de impuestos %s/%s, se esperaban %s/%s", impuestos.TotalImpuestosTrasladados, impuestos.TotalImpuestosRetenidos, c.Impuestos.TotalImpuestosTrasladados, c.Impuestos.TotalImpuestosRetenidos)
+	}
+}
+
+func TestVersionPrefijada(t *testing.T) {
+	c := comprobantePrueba(1)
+	c.Version = ""
+	datos, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(datos), ` Version="3.3"`) {
+		t.Errorf("no se escribió Version=\"3.3\":\n%s", datos)
+	}
+	cadena, err := c.CadenaOriginal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cadena, "||3.3|") {
+		t.Errorf("la cadena original no comienza con la versión: %s", cadena)
+	}
+	var vacio Comprobante
+	datos, err = Marshal(vacio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(datos), `Version=""`) {
+		t.Errorf("un comprobante vacío escribe Version vacía:\n%s", datos)
+	}
+}
@@ -10,13 +10,16 @@ import (
 
 func main() {
 	var comprobante xmlstructures.Comprobante
-	estructura := xmlstructures.MarshallData2XML(comprobante)
-	fmt.Println(estructura)
+	estructura, err := xmlstructures.MarshalIndent(comprobante, "  ", "    ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(estructura))
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	pdf.SetFont("Arial", "", 11)
 	pdf.Cell(40, 10, "Hello, world")
-	err := pdf.OutputFileAndClose("hello.pdf")
+	err = pdf.OutputFileAndClose("hello.pdf")
 	if err != nil {
 		panic(err)
 	}